@@ -0,0 +1,18 @@
+// Package buildinfo holds version information stamped into the binary at
+// build time, e.g.
+//
+//	go build -ldflags "-X course/buildinfo.Version=v1.2.0 -X course/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// Unstamped builds (including tests) report the placeholder values.
+package buildinfo
+
+var (
+	// Version is the release version of the build
+	Version = "dev"
+
+	// Commit is the git commit the binary was built from
+	Commit = "unknown"
+
+	// BuildTime is when the binary was built, in RFC 3339 format
+	BuildTime = "unknown"
+)
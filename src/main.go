@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,27 +10,56 @@ import (
 	"syscall"
 
 	"course/models"
-	"course/vector"
 	"course/vector/index"
 	"course/vector/query"
 )
 
 func main() {
+	warmup := flag.Bool("warmup", false, "Preload vectors into memory before serving requests")
+	readOnly := flag.Bool("readonly", false, "Refuse writes, serving only reads")
+	defaults := query.DefaultServerConfig()
+	readTimeout := flag.Duration("read-timeout", defaults.ReadTimeout, "Maximum time to read a request, including its body")
+	writeTimeout := flag.Duration("write-timeout", defaults.WriteTimeout, "Maximum time to write a response")
+	idleTimeout := flag.Duration("idle-timeout", defaults.IdleTimeout, "Maximum time a keep-alive connection may sit idle")
+	enablePprof := flag.Bool("pprof", false, "Serve profiling data under /debug/pprof/")
+	maxBodyBytes := flag.Int64("max-body-bytes", defaults.MaxRequestBodyBytes, "Largest accepted request body in bytes (0 = unlimited)")
+	maxSearches := flag.Int("max-concurrent-searches", defaults.MaxConcurrentSearches, "Searches allowed to run at once before new ones are rejected with 503 (0 = unlimited)")
+	compactInterval := flag.Duration("compact-interval", 0, "How often to purge expired tombstones of deleted vectors (0 = only on POST /admin/compact)")
+	compactRetention := flag.Duration("compact-retention", query.DefaultCompactionRetention, "How long tombstones of deleted vectors are kept before compaction purges them")
+	flag.Parse()
+
 	fmt.Println("Starting Nexus-Mind Vector Store...")
 
 	// Create a sample collection with a linear index
 	collection := createSampleCollection()
 
+	// Optionally warm up the indexes so the first queries aren't slowed by a cold start
+	if *warmup {
+		if err := collection.Warmup(3); err != nil {
+			log.Fatalf("Failed to warm up collection: %v", err)
+		}
+		fmt.Println("Warmed up sample collection")
+	}
+
 	// Set up the HTTP API
 	api := query.NewAPI()
 	api.RegisterCollection(collection)
-
-	// Configure HTTP routes
-	mux := http.NewServeMux()
-	api.SetupRoutes(mux)
+	api.SetReadOnly(*readOnly)
+	api.SetCompactionRetention(*compactRetention)
+	stopCompaction := api.StartCompaction(*compactInterval)
+	defer stopCompaction()
 
 	// Start the HTTP server
 	port := "8080"
+	server := query.NewServer(":"+port, api, query.ServerConfig{
+		ReadTimeout:           *readTimeout,
+		WriteTimeout:          *writeTimeout,
+		IdleTimeout:           *idleTimeout,
+		MaxHeaderBytes:        defaults.MaxHeaderBytes,
+		MaxRequestBodyBytes:   *maxBodyBytes,
+		EnablePprof:           *enablePprof,
+		MaxConcurrentSearches: *maxSearches,
+	})
 	fmt.Printf("Starting HTTP server on port %s...\n", port)
 	
 	// Handle signals for graceful shutdown
@@ -37,7 +67,7 @@ func main() {
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		if err := http.ListenAndServe(":"+port, mux); err != nil && err != http.ErrServerClosed {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Error starting server: %v", err)
 		}
 	}()
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Compactor is implemented by indexes that keep deleted vectors as tombstones
+// and can purge them. Compact removes the tombstones of vectors deleted at
+// least retention ago and must not hold off searches for its whole duration.
+type Compactor interface {
+	Compact(retention time.Duration) CompactionStats
+}
+
+// CompactionStats reports what a compaction purged
+type CompactionStats struct {
+	TombstonesPurged int   `json:"tombstones_purged"` // Deleted vectors removed from memory
+	BytesReclaimed   int64 `json:"bytes_reclaimed"`   // Approximate memory freed
+}
+
+// Add accumulates other into s
+func (s *CompactionStats) Add(other CompactionStats) {
+	s.TombstonesPurged += other.TombstonesPurged
+	s.BytesReclaimed += other.BytesReclaimed
+}
+
+// Compact purges the tombstones of vectors deleted at least retention ago
+// from every index that supports it. The collection lock is only held to list
+// the indexes, so writes and searches proceed while the indexes compact.
+func (c *VectorCollection) Compact(retention time.Duration) (CompactionStats, error) {
+	if retention < 0 {
+		return CompactionStats{}, fmt.Errorf("retention cannot be negative, got %v: %w", retention, ErrInvalidArgument)
+	}
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.Indexes))
+	compactors := make(map[string]Compactor)
+	for name, index := range c.Indexes {
+		if compactor, ok := index.(Compactor); ok {
+			names = append(names, name)
+			compactors[name] = compactor
+		}
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	var stats CompactionStats
+	for _, name := range names {
+		stats.Add(compactors[name].Compact(retention))
+	}
+	return stats, nil
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// dedupNeighbors is the number of neighbors first fetched per vector when
+// looking for duplicates. It doubles while every neighbor found is a duplicate.
+const dedupNeighbors = 16
+
+// FindDuplicates groups live vectors whose similarity score (normalized to
+// [0,1] as in SearchResult.Score) is at least threshold. Each vector's
+// neighbors are found with a search of the collection's first index by name,
+// so the cost is one search per vector rather than a comparison of every
+// pair. Duplicates are transitive: if a matches b and b matches c, all three
+// form one cluster. Clusters hold at least two IDs, sorted, and are ordered by
+// their first ID.
+func (c *VectorCollection) FindDuplicates(threshold float32) ([][]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.findDuplicatesLocked(threshold)
+}
+
+// DeduplicateKeepFirst finds the clusters FindDuplicates would report and
+// deletes all but the first (lowest) ID of each. Clusters are found under the
+// read lock, so searches and reads continue meanwhile; the deletes then take
+// the write lock. A cluster any of whose vectors was written or deleted in
+// between is left alone. It returns the clusters it acted on, so the deleted
+// IDs are every ID after the first.
+func (c *VectorCollection) DeduplicateKeepFirst(threshold float32) ([][]string, error) {
+	c.mu.RLock()
+	versions := make(map[string]uint64, len(c.versions))
+	for id, version := range c.versions {
+		versions[id] = version
+	}
+	clusters, err := c.findDuplicatesLocked(threshold)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unchanged [][]string
+	var removed []string
+	for _, cluster := range clusters {
+		if c.changedSinceLocked(cluster, versions) {
+			continue
+		}
+		unchanged = append(unchanged, cluster)
+		removed = append(removed, cluster[1:]...)
+	}
+	if _, _, err := c.deleteBatchLocked(removed); err != nil {
+		return nil, err
+	}
+	return unchanged, nil
+}
+
+// changedSinceLocked reports whether any of the IDs no longer has the version
+// recorded for it. Callers must hold the lock.
+func (c *VectorCollection) changedSinceLocked(ids []string, versions map[string]uint64) bool {
+	for _, id := range ids {
+		if current, live := c.versions[id]; !live || current != versions[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// findDuplicatesLocked implements FindDuplicates. Callers must hold the lock.
+func (c *VectorCollection) findDuplicatesLocked(threshold float32) ([][]string, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("duplicate threshold must be in (0, 1], got %v: %w", threshold, ErrInvalidArgument)
+	}
+	if len(c.Indexes) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	index := c.Indexes[names[0]]
+
+	var vectors []*Vector
+	if err := c.scan(func(vector *Vector) bool {
+		vectors = append(vectors, vector)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	// Union-find over IDs, joining each vector with its duplicates
+	parent := make(map[string]string, len(vectors))
+	for _, vector := range vectors {
+		parent[vector.ID] = vector.ID
+	}
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+
+	params := NewSearchParams()
+	params.ScoreThreshold = threshold
+	for _, vector := range vectors {
+		for k := dedupNeighbors; ; k *= 2 {
+			results, err := index.Search(vector.Values, k, nil, params)
+			if err != nil {
+				return nil, fmt.Errorf("searching for duplicates of %s: %w", vector.ID, err)
+			}
+
+			matched := 0
+			for _, res := range results {
+				score := res.Score
+				if score == 0 {
+					score = DistanceToScore(res.Distance, c.DistanceFunc)
+				}
+				if score < threshold {
+					continue
+				}
+				matched++
+				if _, live := parent[res.ID]; live && res.ID != vector.ID {
+					parent[find(res.ID)] = find(vector.ID)
+				}
+			}
+
+			// Stop once some neighbor falls below the threshold or
+			// every vector has been seen
+			if matched < k || k >= len(vectors) {
+				break
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, vector := range vectors {
+		root := find(vector.ID)
+		groups[root] = append(groups[root], vector.ID)
+	}
+	var clusters [][]string
+	for _, ids := range groups {
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			clusters = append(clusters, ids)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters, nil
+}
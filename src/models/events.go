@@ -0,0 +1,74 @@
+package models
+
+import (
+	"log"
+	"time"
+)
+
+// Operations reported by VectorEvent
+const (
+	VectorInserted        = "insert"          // A vector was inserted or replaced
+	VectorDeleted         = "delete"          // A vector was deleted
+	VectorMetadataUpdated = "update_metadata" // A vector's metadata was replaced
+)
+
+// VectorEvent describes a successful write to a collection
+type VectorEvent struct {
+	Collection string    // Name of the collection written to
+	Operation  string    // VectorInserted, VectorDeleted or VectorMetadataUpdated
+	ID         string    // ID of the vector written
+	Version    uint64    // Version after the write (0 for deletes)
+	Timestamp  time.Time // When the write was applied
+}
+
+// AddObserver registers fn to be called after every successful insert,
+// delete and metadata update. Observers run asynchronously, off the
+// collection's lock, so they may call back into the collection; events of one
+// operation arrive in order, but events of concurrent operations may
+// interleave. A panicking observer is logged and does not affect the
+// collection or other observers.
+func (c *VectorCollection) AddObserver(fn func(event VectorEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Copy on write, so deliveries in flight keep the list they started with
+	observers := make([]func(VectorEvent), len(c.observers), len(c.observers)+1)
+	copy(observers, c.observers)
+	c.observers = append(observers, fn)
+}
+
+// notify delivers events for the given operation on the listed IDs to the
+// observers. Callers must hold the lock, which protects the observer list.
+func (c *VectorCollection) notify(operation string, ids []string, versions []uint64) {
+	if len(c.observers) == 0 || len(ids) == 0 {
+		return
+	}
+
+	now := time.Now()
+	events := make([]VectorEvent, len(ids))
+	for i, id := range ids {
+		events[i] = VectorEvent{Collection: c.Name, Operation: operation, ID: id, Timestamp: now}
+		if versions != nil {
+			events[i].Version = versions[i]
+		}
+	}
+
+	observers := c.observers
+	go func() {
+		for _, observer := range observers {
+			for _, event := range events {
+				deliver(observer, event)
+			}
+		}
+	}()
+}
+
+// deliver calls one observer, recovering from a panic in it
+func deliver(observer func(VectorEvent), event VectorEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("collection %s: observer panicked on %s of %s: %v", event.Collection, event.Operation, event.ID, r)
+		}
+	}()
+	observer(event)
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an applied idempotency key is remembered
+// unless the collection is configured otherwise
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// Statuses reported per item by BulkUpsert
+const (
+	UpsertApplied   = "applied"   // The vector was written
+	UpsertDuplicate = "duplicate" // The idempotency key was seen recently; nothing was written
+	UpsertFailed    = "error"     // The vector was rejected; see the item's error
+)
+
+// UpsertItem is one vector of a bulk upsert, with an optional idempotency
+// key identifying the write across client retries
+type UpsertItem struct {
+	Vector         *Vector
+	IdempotencyKey string
+}
+
+// UpsertResult reports the outcome of one item of a bulk upsert
+type UpsertResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// idempotencyKeys remembers applied idempotency keys until they expire. It
+// is guarded by the collection's lock.
+type idempotencyKeys struct {
+	ttl     time.Duration
+	expires map[string]time.Time
+	now     func() time.Time
+}
+
+// newIdempotencyKeys creates an empty key set remembering keys for ttl
+func newIdempotencyKeys(ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		ttl:     ttl,
+		expires: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// seen reports whether key was recorded and has not expired yet
+func (k *idempotencyKeys) seen(key string) bool {
+	expiry, ok := k.expires[key]
+	return ok && k.now().Before(expiry)
+}
+
+// record remembers key for the configured TTL
+func (k *idempotencyKeys) record(key string) {
+	k.expires[key] = k.now().Add(k.ttl)
+}
+
+// purge forgets expired keys
+func (k *idempotencyKeys) purge() {
+	now := k.now()
+	for key, expiry := range k.expires {
+		if !now.Before(expiry) {
+			delete(k.expires, key)
+		}
+	}
+}
+
+// SetIdempotencyTTL sets how long BulkUpsert remembers applied idempotency
+// keys. A non-positive ttl restores DefaultIdempotencyTTL. Keys already
+// recorded keep their original expiry.
+func (c *VectorCollection) SetIdempotencyTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	if c.idempotency == nil {
+		c.idempotency = newIdempotencyKeys(ttl)
+		return
+	}
+	c.idempotency.ttl = ttl
+}
+
+// BulkUpsert inserts or replaces each vector under a single write lock. Items
+// whose idempotency key was applied within the TTL are skipped and reported
+// as duplicates, so a retried batch is not applied twice. Items are applied
+// independently: a rejected vector is reported without affecting the others,
+// and its key is not recorded.
+func (c *VectorCollection) BulkUpsert(items []UpsertItem) []UpsertResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idempotency == nil {
+		c.idempotency = newIdempotencyKeys(DefaultIdempotencyTTL)
+	}
+	c.idempotency.purge()
+
+	results := make([]UpsertResult, len(items))
+	var applied []string
+	var versions []uint64
+	defer func() { c.notify(VectorInserted, applied, versions) }()
+	for i, item := range items {
+		results[i].ID = item.Vector.ID
+		if item.IdempotencyKey != "" && c.idempotency.seen(item.IdempotencyKey) {
+			results[i].Status = UpsertDuplicate
+			continue
+		}
+
+		if err := c.insertLocked(item.Vector); err != nil {
+			results[i].Status = UpsertFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].ID = item.Vector.ID // May have been generated
+		results[i].Status = UpsertApplied
+		applied = append(applied, item.Vector.ID)
+		versions = append(versions, item.Vector.Version)
+		if item.IdempotencyKey != "" {
+			c.idempotency.record(item.IdempotencyKey)
+		}
+	}
+	return results
+}
@@ -1,9 +1,15 @@
 package models
 
 import (
+	"container/list"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // FieldType represents the data type of a metadata field
@@ -19,13 +25,15 @@ const (
 
 // MetadataSchema defines typed fields for efficient filtering
 type MetadataSchema struct {
-	Fields map[string]FieldType
+	Fields   map[string]FieldType
+	Required map[string]bool // Fields every vector's metadata must contain
 }
 
 // NewMetadataSchema creates a new empty metadata schema
 func NewMetadataSchema() *MetadataSchema {
 	return &MetadataSchema{
-		Fields: make(map[string]FieldType),
+		Fields:   make(map[string]FieldType),
+		Required: make(map[string]bool),
 	}
 }
 
@@ -34,23 +42,225 @@ func (s *MetadataSchema) AddField(name string, fieldType FieldType) {
 	s.Fields[name] = fieldType
 }
 
+// AddRequiredField adds a field that every vector's metadata must contain
+func (s *MetadataSchema) AddRequiredField(name string, fieldType FieldType) {
+	s.Fields[name] = fieldType
+	if s.Required == nil {
+		s.Required = make(map[string]bool)
+	}
+	s.Required[name] = true
+}
+
+// RemoveField drops a field from the schema; values already stored under it are left untouched
+func (s *MetadataSchema) RemoveField(name string) {
+	delete(s.Fields, name)
+	delete(s.Required, name)
+}
+
+// RetypeField changes the declared type of an existing field
+func (s *MetadataSchema) RetypeField(name string, fieldType FieldType) error {
+	if _, exists := s.Fields[name]; !exists {
+		return fmt.Errorf("field %s is not defined in the schema: %w", name, ErrInvalidArgument)
+	}
+	s.Fields[name] = fieldType
+	return nil
+}
+
+// Copy creates a deep copy of the schema
+func (s *MetadataSchema) Copy() *MetadataSchema {
+	fields := make(map[string]FieldType, len(s.Fields))
+	for name, fieldType := range s.Fields {
+		fields[name] = fieldType
+	}
+	required := make(map[string]bool, len(s.Required))
+	for name, isRequired := range s.Required {
+		required[name] = isRequired
+	}
+	return &MetadataSchema{Fields: fields, Required: required}
+}
+
 // ValidateMetadata checks if the provided metadata conforms to the schema
 func (s *MetadataSchema) ValidateMetadata(metadata map[string]interface{}) error {
 	for name, expectedType := range s.Fields {
 		value, exists := metadata[name]
 		if !exists {
+			if s.Required[name] {
+				return fmt.Errorf("required field %s is missing: %w", name, ErrInvalidArgument)
+			}
 			continue // Field is optional
 		}
 
+		// Geo points get strict structural validation
+		if expectedType == GeoField {
+			if err := validateGeoPoint(value); err != nil {
+				return fmt.Errorf("field %s is not a valid geo point: %v: %w", name, err, ErrInvalidArgument)
+			}
+			continue
+		}
+
 		// Validate type
 		actualType := detectFieldType(value)
 		if actualType != expectedType {
-			return fmt.Errorf("field %s has wrong type: expected %v, got %v", name, expectedType, actualType)
+			return fmt.Errorf("field %s has wrong type: expected %v, got %v: %w", name, expectedType, actualType, ErrInvalidArgument)
 		}
 	}
 	return nil
 }
 
+// JSONSchema describes the metadata schema as a JSON Schema object
+func (s *MetadataSchema) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(s.Fields))
+	for name, fieldType := range s.Fields {
+		properties[name] = fieldType.jsonSchema()
+	}
+
+	doc := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	var required []string
+	for name, isRequired := range s.Required {
+		if isRequired {
+			required = append(required, name)
+		}
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		doc["required"] = required
+	}
+	return doc
+}
+
+// String returns the name of the field type
+func (t FieldType) String() string {
+	switch t {
+	case StringField:
+		return "string"
+	case NumberField:
+		return "number"
+	case BoolField:
+		return "bool"
+	case ArrayField:
+		return "array"
+	case GeoField:
+		return "geo"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFieldType resolves a field type from its name
+func ParseFieldType(name string) (FieldType, error) {
+	switch strings.ToLower(name) {
+	case "string", "keyword", "text":
+		return StringField, nil
+	case "number", "float", "int", "integer":
+		return NumberField, nil
+	case "bool", "boolean":
+		return BoolField, nil
+	case "array":
+		return ArrayField, nil
+	case "geo":
+		return GeoField, nil
+	default:
+		return StringField, fmt.Errorf("unknown field type %s", name)
+	}
+}
+
+// jsonSchema returns the JSON Schema fragment describing values of this type
+func (t FieldType) jsonSchema() map[string]interface{} {
+	switch t {
+	case StringField:
+		return map[string]interface{}{"type": "string"}
+	case NumberField:
+		return map[string]interface{}{"type": "number"}
+	case BoolField:
+		return map[string]interface{}{"type": "boolean"}
+	case ArrayField:
+		return map[string]interface{}{"type": "array"}
+	case GeoField:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"lat": map[string]interface{}{"type": "number", "minimum": -90, "maximum": 90},
+				"lon": map[string]interface{}{"type": "number", "minimum": -180, "maximum": 180},
+			},
+			"required": []string{"lat", "lon"},
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// validateGeoPoint checks that a value is a map with numeric lat/lon
+// coordinates within their valid ranges
+func validateGeoPoint(value interface{}) error {
+	point, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object with lat and lon, got %T", value)
+	}
+
+	if err := validateCoordinate(point, "lat", 90); err != nil {
+		return err
+	}
+	return validateCoordinate(point, "lon", 180)
+}
+
+// validateCoordinate checks that a coordinate is numeric and lies in [-limit, limit]
+func validateCoordinate(point map[string]interface{}, key string, limit float64) error {
+	raw, exists := point[key]
+	if !exists {
+		return fmt.Errorf("missing %s", key)
+	}
+
+	coord, ok := toFloat64(raw)
+	if !ok {
+		return fmt.Errorf("%s must be numeric, got %T", key, raw)
+	}
+	if coord < -limit || coord > limit {
+		return fmt.Errorf("%s %v out of range [%v, %v]", key, coord, -limit, limit)
+	}
+	return nil
+}
+
+// NumericValue converts any Go numeric metadata value to float64, reporting
+// false for non-numeric values
+func NumericValue(value interface{}) (float64, bool) {
+	return toFloat64(value)
+}
+
+// toFloat64 converts any Go numeric type to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
 // detectFieldType determines the FieldType based on a Go value
 func detectFieldType(value interface{}) FieldType {
 	if value == nil {
@@ -90,7 +300,7 @@ const (
 // FilterCondition represents a single filtering condition
 type FilterCondition struct {
 	Field    string      // Path to the field
-	Operator string      // eq, gt, lt, range, contains
+	Operator string      // eq, neq, gt, gte, lt, lte, range, contains, exists, prefix, regex
 	Value    interface{} // Value to compare against
 }
 
@@ -115,6 +325,127 @@ func NewRangeCondition(field string, min, max interface{}) FilterCondition {
 	}
 }
 
+// NewExistsCondition creates a condition that checks whether a field is
+// present in the metadata (shouldExist true) or absent (shouldExist false)
+func NewExistsCondition(field string, shouldExist bool) FilterCondition {
+	return FilterCondition{
+		Field:    field,
+		Operator: "exists",
+		Value:    shouldExist,
+	}
+}
+
+// NewPrefixCondition creates a condition that checks whether a string field
+// starts with the given prefix
+func NewPrefixCondition(field, prefix string) FilterCondition {
+	return FilterCondition{
+		Field:    field,
+		Operator: "prefix",
+		Value:    prefix,
+	}
+}
+
+// NewRegexCondition creates a condition that checks whether a string field
+// matches a regular expression (RE2 syntax). The pattern is validated up front.
+func NewRegexCondition(field, pattern string) (FilterCondition, error) {
+	if _, err := compileFilterRegex(pattern); err != nil {
+		return FilterCondition{}, err
+	}
+	return FilterCondition{
+		Field:    field,
+		Operator: "regex",
+		Value:    pattern,
+	}, nil
+}
+
+// maxRegexLength bounds the size of regex filter patterns. Go's RE2 engine
+// matches in linear time, but very large patterns are still costly to compile.
+const maxRegexLength = 1024
+
+// maxCachedRegexes bounds how many compiled regex filter patterns are kept
+const maxCachedRegexes = 256
+
+// regexCache is a fixed-size LRU cache of compiled regex filter patterns, so
+// clients sending ever-new patterns cannot grow it without bound
+type regexCache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // Front is most recently used
+	mu       sync.Mutex
+}
+
+// regexCacheEntry is the value stored in the LRU list
+type regexCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// filterRegexes caches compiled regex filter patterns
+var filterRegexes = newRegexCache(maxCachedRegexes)
+
+// newRegexCache creates an empty cache holding up to capacity patterns
+func newRegexCache(capacity int) *regexCache {
+	return &regexCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the compiled pattern, if cached
+func (rc *regexCache) get(pattern string) (*regexp.Regexp, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.entries[pattern]
+	if !ok {
+		return nil, false
+	}
+	rc.order.MoveToFront(elem)
+	return elem.Value.(*regexCacheEntry).re, true
+}
+
+// put stores a compiled pattern, evicting the least recently used one if the
+// cache is full
+func (rc *regexCache) put(pattern string, re *regexp.Regexp) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.entries[pattern]; ok {
+		rc.order.MoveToFront(elem)
+		return
+	}
+	rc.entries[pattern] = rc.order.PushFront(&regexCacheEntry{pattern: pattern, re: re})
+	if rc.order.Len() > rc.capacity {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*regexCacheEntry).pattern)
+	}
+}
+
+// len returns the number of cached patterns
+func (rc *regexCache) len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.order.Len()
+}
+
+// compileFilterRegex compiles a regex filter pattern, reusing cached results
+func compileFilterRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := filterRegexes.get(pattern); ok {
+		return cached, nil
+	}
+	if len(pattern) > maxRegexLength {
+		return nil, fmt.Errorf("regex pattern length %d exceeds the maximum of %d", len(pattern), maxRegexLength)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern %q: %w", pattern, err)
+	}
+	filterRegexes.put(pattern, re)
+	return re, nil
+}
+
 // MetadataFilter represents a filter for metadata based on conditions
 type MetadataFilter struct {
 	Conditions []FilterCondition
@@ -137,17 +468,62 @@ func NewOrFilter(conditions ...FilterCondition) *MetadataFilter {
 	}
 }
 
+// maxFilterConditions bounds the number of conditions in one filter
+const maxFilterConditions = 1024
+
+// FilterFromJSON decodes a filter as sent by clients and validates it, so
+// malformed input is reported as an error instead of surfacing during matching
+func FilterFromJSON(data []byte) (*MetadataFilter, error) {
+	var filter MetadataFilter
+	if err := json.Unmarshal(data, &filter); err != nil {
+		return nil, fmt.Errorf("invalid filter: %v: %w", err, ErrInvalidArgument)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// ToJSON encodes the filter in the form accepted by FilterFromJSON
+func (f *MetadataFilter) ToJSON() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+// Validate checks conditions that can be rejected before matching, such as
+// regex patterns decoded from JSON
+func (f *MetadataFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.Operator != AND && f.Operator != OR {
+		return fmt.Errorf("unknown filter operator %d: %w", int(f.Operator), ErrInvalidArgument)
+	}
+	if len(f.Conditions) > maxFilterConditions {
+		return fmt.Errorf("filter has %d conditions, more than the maximum of %d: %w",
+			len(f.Conditions), maxFilterConditions, ErrInvalidArgument)
+	}
+	for _, condition := range f.Conditions {
+		if condition.Operator != "regex" {
+			continue
+		}
+		pattern, ok := condition.Value.(string)
+		if !ok {
+			return fmt.Errorf("regex condition on %s requires a string pattern: %w", condition.Field, ErrInvalidArgument)
+		}
+		if _, err := compileFilterRegex(pattern); err != nil {
+			return fmt.Errorf("regex condition on %s: %v: %w", condition.Field, err, ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
 // MatchVector checks if a vector's metadata matches the filter
 func (f *MetadataFilter) MatchVector(vector *Vector) bool {
 	if f == nil || len(f.Conditions) == 0 {
 		return true // Empty filter matches everything
 	}
 
-	// If metadata is nil, only match if the filter is empty
-	if vector.Metadata == nil {
-		return len(f.Conditions) == 0
-	}
-
+	// Nil metadata behaves like an empty map: every field is missing
 	if f.Operator == AND {
 		// All conditions must match
 		for _, condition := range f.Conditions {
@@ -171,6 +547,16 @@ func (f *MetadataFilter) MatchVector(vector *Vector) bool {
 func matchCondition(metadata map[string]interface{}, condition FilterCondition) bool {
 	// Extract the value from metadata
 	pathParts := strings.Split(condition.Field, ".")
+	
+	// Existence checks must run before missing fields are rejected below
+	if condition.Operator == "exists" {
+		shouldExist, ok := condition.Value.(bool)
+		if !ok {
+			return false
+		}
+		return hasNestedField(metadata, pathParts) == shouldExist
+	}
+	
 	value := getNestedValue(metadata, pathParts)
 	
 	if value == nil {
@@ -180,9 +566,9 @@ func matchCondition(metadata map[string]interface{}, condition FilterCondition)
 
 	switch condition.Operator {
 	case "eq":
-		return reflect.DeepEqual(value, condition.Value)
+		return valuesEqual(value, condition.Value)
 	case "neq":
-		return !reflect.DeepEqual(value, condition.Value)
+		return !valuesEqual(value, condition.Value)
 	case "gt":
 		return compareValues(value, condition.Value) > 0
 	case "gte":
@@ -212,17 +598,40 @@ func matchCondition(metadata map[string]interface{}, condition FilterCondition)
 			}
 		} else if arrVal, ok := value.([]interface{}); ok {
 			for _, item := range arrVal {
-				if reflect.DeepEqual(item, condition.Value) {
+				if valuesEqual(item, condition.Value) {
 					return true
 				}
 			}
 		}
 		return false
+	case "prefix":
+		strVal, ok := value.(string)
+		prefix, isString := condition.Value.(string)
+		return ok && isString && strings.HasPrefix(strVal, prefix)
+	case "regex":
+		strVal, ok := value.(string)
+		pattern, isString := condition.Value.(string)
+		if !ok || !isString {
+			return false
+		}
+		re, err := compileFilterRegex(pattern)
+		return err == nil && re.MatchString(strVal)
 	default:
 		return false
 	}
 }
 
+// valuesEqual compares metadata values, treating numbers of different Go types
+// (such as an int bound against a JSON-decoded float64) as equal when their
+// values are
+func valuesEqual(v1, v2 interface{}) bool {
+	if n1, ok := toFloat64(v1); ok {
+		n2, ok := toFloat64(v2)
+		return ok && n1 == n2
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
 // getNestedValue retrieves a value from nested maps using a path
 func getNestedValue(data map[string]interface{}, path []string) interface{} {
 	if len(path) == 0 {
@@ -241,10 +650,31 @@ func getNestedValue(data map[string]interface{}, path []string) interface{} {
 	return nil
 }
 
+// hasNestedField reports whether the path is present in nested maps, even if
+// the value stored there is nil
+func hasNestedField(data map[string]interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	current, exists := data[path[0]]
+	if !exists || len(path) == 1 {
+		return exists
+	}
+
+	if nestedMap, ok := current.(map[string]interface{}); ok {
+		return hasNestedField(nestedMap, path[1:])
+	}
+	return false
+}
+
 // compareValues compares two values and returns:
 // -1 if v1 < v2
 //  0 if v1 == v2
 //  1 if v1 > v2
+//
+// Numbers of any Go type are compared as float64. Dates, given as time.Time
+// or RFC3339 strings, are compared chronologically; other strings lexically.
 func compareValues(v1, v2 interface{}) int {
 	// Handle nil cases
 	if v1 == nil && v2 == nil {
@@ -257,55 +687,50 @@ func compareValues(v1, v2 interface{}) int {
 		return 1
 	}
 
-	// Compare based on type
-	switch val1 := v1.(type) {
-	case string:
-		if val2, ok := v2.(string); ok {
-			if val1 < val2 {
-				return -1
-			} else if val1 > val2 {
-				return 1
-			}
-			return 0
+	if n1, ok := toFloat64(v1); ok {
+		if n2, ok := toFloat64(v2); ok {
+			return compareOrdered(n1 < n2, n1 > n2)
 		}
-	case float64:
-		switch val2 := v2.(type) {
-		case float64:
-			if val1 < val2 {
-				return -1
-			} else if val1 > val2 {
-				return 1
-			}
-			return 0
-		case int:
-			val2Float := float64(val2)
-			if val1 < val2Float {
-				return -1
-			} else if val1 > val2Float {
-				return 1
-			}
-			return 0
+		return 0
+	}
+
+	if t1, ok := toTime(v1); ok {
+		if t2, ok := toTime(v2); ok {
+			return compareOrdered(t1.Before(t2), t1.After(t2))
 		}
-	case int:
-		switch val2 := v2.(type) {
-		case int:
-			if val1 < val2 {
-				return -1
-			} else if val1 > val2 {
-				return 1
-			}
-			return 0
-		case float64:
-			val1Float := float64(val1)
-			if val1Float < val2 {
-				return -1
-			} else if val1Float > val2 {
-				return 1
-			}
-			return 0
+	}
+
+	if s1, ok := v1.(string); ok {
+		if s2, ok := v2.(string); ok {
+			return compareOrdered(s1 < s2, s1 > s2)
 		}
 	}
 
 	// Default: if types don't match or can't be compared
 	return 0
+}
+
+// compareOrdered turns the results of a less-than and a greater-than
+// comparison into -1, 0 or 1
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// toTime converts a time.Time or an RFC3339 string to a time.Time
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
 }
\ No newline at end of file
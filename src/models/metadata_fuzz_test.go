@@ -0,0 +1,41 @@
+//go:build go1.18
+// +build go1.18
+
+package models
+
+import "testing"
+
+// FuzzFilterFromJSON checks that arbitrary input never panics while decoding,
+// and that filters which decode can be matched and re-encoded
+func FuzzFilterFromJSON(f *testing.F) {
+	for _, seed := range filterJSONSeeds {
+		f.Add([]byte(seed))
+	}
+
+	vectors := []*Vector{
+		NewVector("empty", []float32{1}, nil),
+		NewVector("full", []float32{1}, map[string]interface{}{
+			"region": "A",
+			"rating": 3.0,
+			"tags":   []interface{}{"new", 1.0},
+			"meta":   map[string]interface{}{"owner": "bob", "nested": map[string]interface{}{}},
+		}),
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		filter, err := FilterFromJSON(data)
+		if err != nil {
+			return
+		}
+		for _, v := range vectors {
+			filter.MatchVector(v)
+		}
+		encoded, err := filter.ToJSON()
+		if err != nil {
+			t.Fatalf("ToJSON failed for a decoded filter: %v", err)
+		}
+		if _, err := FilterFromJSON(encoded); err != nil {
+			t.Fatalf("Re-decoding %s failed: %v", encoded, err)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// metadataIndex is an inverted index from the values of declared metadata
+// fields to the IDs of the live vectors holding them. It serves equality
+// conditions on those fields, so filtered searches only compute distances for
+// vectors that can match. Only scalar values (strings, numbers and booleans)
+// are indexed.
+type metadataIndex struct {
+	lookups  uint64                                // Searches served from the postings (atomic, first for alignment)
+	fields   map[string][]string                   // Indexed field -> its dot-separated path
+	postings map[string]map[string]map[string]bool // Field -> value key -> IDs
+	keys     map[string]map[string]string          // ID -> field -> value key, for removal
+}
+
+// newMetadataIndex creates an empty inverted index over the given fields
+func newMetadataIndex(fields []string) *metadataIndex {
+	m := &metadataIndex{
+		fields:   make(map[string][]string, len(fields)),
+		postings: make(map[string]map[string]map[string]bool, len(fields)),
+		keys:     make(map[string]map[string]string),
+	}
+	for _, field := range fields {
+		m.fields[field] = strings.Split(field, ".")
+		m.postings[field] = make(map[string]map[string]bool)
+	}
+	return m
+}
+
+// valueKey returns the posting key of a metadata value. Numbers of any Go
+// type share keys, matching how equality filters compare them.
+func valueKey(value interface{}) (string, bool) {
+	if n, ok := toFloat64(value); ok {
+		if n == 0 {
+			n = 0 // -0 equals 0
+		}
+		return "n" + strconv.FormatFloat(n, 'g', -1, 64), true
+	}
+	switch v := value.(type) {
+	case string:
+		return "s" + v, true
+	case bool:
+		return "b" + strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// add indexes a vector, replacing any previous entry for its ID
+func (m *metadataIndex) add(vector *Vector) {
+	if m == nil {
+		return
+	}
+	m.remove(vector.ID)
+
+	keys := make(map[string]string)
+	for field, path := range m.fields {
+		key, ok := valueKey(getNestedValue(vector.Metadata, path))
+		if !ok {
+			continue
+		}
+		ids := m.postings[field][key]
+		if ids == nil {
+			ids = make(map[string]bool)
+			m.postings[field][key] = ids
+		}
+		ids[vector.ID] = true
+		keys[field] = key
+	}
+	if len(keys) > 0 {
+		m.keys[vector.ID] = keys
+	}
+}
+
+// remove drops a vector from the index, if present
+func (m *metadataIndex) remove(id string) {
+	if m == nil {
+		return
+	}
+	for field, key := range m.keys[id] {
+		ids := m.postings[field][key]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(m.postings[field], key)
+		}
+	}
+	delete(m.keys, id)
+}
+
+// candidates returns the sorted IDs of the vectors that can match the filter,
+// or false if the filter cannot be answered from the index. For AND filters
+// every usable equality condition narrows the candidates and the remaining
+// conditions are left to the search; OR filters need every condition to be
+// usable. The candidates must still be checked against the whole filter.
+func (m *metadataIndex) candidates(filter *MetadataFilter) ([]string, bool) {
+	if m == nil || filter == nil || len(filter.Conditions) == 0 {
+		return nil, false
+	}
+
+	var sets []map[string]bool
+	for _, condition := range filter.Conditions {
+		key, usable := "", false
+		if _, indexed := m.fields[condition.Field]; indexed && condition.Operator == "eq" {
+			key, usable = valueKey(condition.Value)
+		}
+		if !usable {
+			if filter.Operator == OR {
+				return nil, false
+			}
+			continue
+		}
+		sets = append(sets, m.postings[condition.Field][key])
+	}
+	if len(sets) == 0 {
+		return nil, false
+	}
+
+	var ids []string
+	if filter.Operator == OR {
+		union := make(map[string]bool)
+		for _, set := range sets {
+			for id := range set {
+				union[id] = true
+			}
+		}
+		for id := range union {
+			ids = append(ids, id)
+		}
+	} else {
+		// Intersect starting from the smallest posting list
+		sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+		for id := range sets[0] {
+			inAll := true
+			for _, set := range sets[1:] {
+				if !set[id] {
+					inAll = false
+					break
+				}
+			}
+			if inAll {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, true
+}
+
+// candidatesLocked returns the IDs a search on the given index can be
+// restricted to, or false if the filter cannot use the inverted index or the
+// index cannot search a subset. Callers must hold the lock.
+func (c *VectorCollection) candidatesLocked(index VectorIndex, filter *MetadataFilter) ([]string, bool) {
+	if _, ok := index.(CandidateSearcher); !ok {
+		return nil, false
+	}
+	return c.metadata.candidates(filter)
+}
+
+// SetIndexedFields declares the (possibly nested, dot-separated) metadata
+// fields to keep an inverted index on, replacing any previous declaration and
+// indexing the vectors already stored. Searches whose filter has equality
+// conditions on these fields then only consider the vectors the index
+// selects, provided the chosen index implements CandidateSearcher; other
+// searches scan as before. No fields disables the inverted index.
+func (c *VectorCollection) SetIndexedFields(fields []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(fields) == 0 {
+		c.metadata = nil
+		return nil
+	}
+	m := newMetadataIndex(fields)
+	if len(c.versions) > 0 {
+		if err := c.scan(func(vector *Vector) bool {
+			m.add(vector)
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+	c.metadata = m
+	return nil
+}
+
+// IndexedFields returns the sorted metadata fields the collection keeps an
+// inverted index on
+func (c *VectorCollection) IndexedFields() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.metadata == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(c.metadata.fields))
+	for field := range c.metadata.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// IndexedFilterSearches returns how many searches have been restricted to
+// candidates from the inverted index
+func (c *VectorCollection) IndexedFilterSearches() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.metadata == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.metadata.lookups)
+}
@@ -0,0 +1,359 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeoFieldValidation(t *testing.T) {
+	schema := NewMetadataSchema()
+	schema.AddField("location", GeoField)
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr string
+	}{
+		{"Valid", map[string]interface{}{"lat": 37.77, "lon": -122.42}, ""},
+		{"ValidIntegers", map[string]interface{}{"lat": 0, "lon": 180}, ""},
+		{"LatOutOfRange", map[string]interface{}{"lat": 91.0, "lon": 0.0}, "lat"},
+		{"LonOutOfRange", map[string]interface{}{"lat": 0.0, "lon": -180.5}, "lon"},
+		{"MissingLat", map[string]interface{}{"lon": 10.0}, "missing lat"},
+		{"MissingLon", map[string]interface{}{"lat": 10.0}, "missing lon"},
+		{"NonNumeric", map[string]interface{}{"lat": "north", "lon": 10.0}, "numeric"},
+		{"NotAMap", "37.77,-122.42", "expected an object"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.ValidateMetadata(map[string]interface{}{"location": tc.value})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected valid geo point, got error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := NewMetadataSchema()
+	schema.AddField("category", StringField)
+	schema.AddField("location", GeoField)
+
+	doc := schema.JSONSchema()
+	if doc["type"] != "object" {
+		t.Errorf("Expected top-level type object, got %v", doc["type"])
+	}
+
+	properties := doc["properties"].(map[string]interface{})
+	category := properties["category"].(map[string]interface{})
+	if category["type"] != "string" {
+		t.Errorf("Expected category type string, got %v", category["type"])
+	}
+
+	location := properties["location"].(map[string]interface{})
+	if location["type"] != "object" {
+		t.Errorf("Expected geo field to be an object, got %v", location["type"])
+	}
+	geoProps := location["properties"].(map[string]interface{})
+	for _, coord := range []string{"lat", "lon"} {
+		if _, ok := geoProps[coord]; !ok {
+			t.Errorf("Expected geo schema to describe %s", coord)
+		}
+	}
+}
+
+func TestExistsCondition(t *testing.T) {
+	v := NewVector("v1", []float32{1, 0}, map[string]interface{}{
+		"price":   10.0,
+		"comment": nil,
+		"details": map[string]interface{}{"color": "red"},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *MetadataFilter
+		expected bool
+	}{
+		{"PresentField", NewAndFilter(NewExistsCondition("price", true)), true},
+		{"PresentNilField", NewAndFilter(NewExistsCondition("comment", true)), true},
+		{"MissingField", NewAndFilter(NewExistsCondition("discount", true)), false},
+		{"MissingFieldNegated", NewAndFilter(NewExistsCondition("discount", false)), true},
+		{"NestedField", NewAndFilter(NewExistsCondition("details.color", true)), true},
+		{"MissingNestedField", NewAndFilter(NewExistsCondition("details.size", false)), true},
+		{"ThroughNonMap", NewAndFilter(NewExistsCondition("price.amount", true)), false},
+		{"ComposesWithAnd", NewAndFilter(
+			NewExistsCondition("price", true),
+			NewEqualsCondition("details.color", "blue"),
+		), false},
+		{"ComposesWithOr", NewOrFilter(
+			NewExistsCondition("discount", true),
+			NewEqualsCondition("details.color", "red"),
+		), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.MatchVector(v); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("NilMetadata", func(t *testing.T) {
+		empty := NewVector("v2", []float32{1, 0}, nil)
+		if !NewAndFilter(NewExistsCondition("price", false)).MatchVector(empty) {
+			t.Errorf("Expected a missing-field condition to match nil metadata")
+		}
+	})
+
+	t.Run("JSONRoundTrip", func(t *testing.T) {
+		data, err := json.Marshal(NewAndFilter(NewExistsCondition("details.color", true)))
+		if err != nil {
+			t.Fatalf("Failed to marshal filter: %v", err)
+		}
+		var decoded MetadataFilter
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal filter: %v", err)
+		}
+		if !decoded.MatchVector(v) {
+			t.Errorf("Expected decoded filter to match, got %s", data)
+		}
+	})
+}
+
+func TestPrefixAndRegexConditions(t *testing.T) {
+	v := NewVector("v1", []float32{1, 0}, map[string]interface{}{
+		"url":    "https://example.com/docs/intro",
+		"source": map[string]interface{}{"path": "/var/log/app.log"},
+		"size":   42.0,
+	})
+
+	mustRegex := func(field, pattern string) FilterCondition {
+		condition, err := NewRegexCondition(field, pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error for pattern %q: %v", pattern, err)
+		}
+		return condition
+	}
+
+	tests := []struct {
+		name      string
+		condition FilterCondition
+		expected  bool
+	}{
+		{"PrefixMatch", NewPrefixCondition("url", "https://example.com/"), true},
+		{"PrefixMismatch", NewPrefixCondition("url", "http://"), false},
+		{"NestedPrefix", NewPrefixCondition("source.path", "/var/log/"), true},
+		{"PrefixOnNonString", NewPrefixCondition("size", "4"), false},
+		{"RegexMatch", mustRegex("url", `^https://[^/]+/docs/`), true},
+		{"RegexMismatch", mustRegex("url", `/blog/`), false},
+		{"NestedRegex", mustRegex("source.path", `\.log$`), true},
+		{"RegexOnMissingField", mustRegex("title", `.*`), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NewAndFilter(tc.condition).MatchVector(v); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		if _, err := NewRegexCondition("url", `([a-z`); err == nil {
+			t.Errorf("Expected an error for an invalid pattern")
+		}
+		if _, err := NewRegexCondition("url", strings.Repeat("a", maxRegexLength+1)); err == nil {
+			t.Errorf("Expected an error for an overlong pattern")
+		}
+	})
+
+	t.Run("BoundedCache", func(t *testing.T) {
+		for i := 0; i < maxCachedRegexes+50; i++ {
+			mustRegex("url", fmt.Sprintf("^id-%d$", i))
+		}
+		if size := filterRegexes.len(); size > maxCachedRegexes {
+			t.Errorf("Expected at most %d cached patterns, got %d", maxCachedRegexes, size)
+		}
+		if got := NewAndFilter(mustRegex("url", `^https://`)).MatchVector(v); !got {
+			t.Errorf("Expected an evicted-and-recompiled pattern to still match")
+		}
+	})
+
+	t.Run("JSONRoundTrip", func(t *testing.T) {
+		data, err := json.Marshal(NewAndFilter(
+			NewPrefixCondition("url", "https://"),
+			mustRegex("source.path", `app\.log$`),
+		))
+		if err != nil {
+			t.Fatalf("Failed to marshal filter: %v", err)
+		}
+		var decoded MetadataFilter
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal filter: %v", err)
+		}
+		if err := decoded.Validate(); err != nil {
+			t.Fatalf("Expected decoded filter to be valid: %v", err)
+		}
+		if !decoded.MatchVector(v) {
+			t.Errorf("Expected decoded filter to match, got %s", data)
+		}
+
+		decoded.Conditions[1].Value = "("
+		if err := decoded.Validate(); err == nil {
+			t.Errorf("Expected Validate to reject an invalid decoded pattern")
+		}
+	})
+}
+
+// filterJSONSeeds are filters in the JSON form clients send
+var filterJSONSeeds = []string{
+	`{"conditions":[{"field":"region","operator":"eq","value":"A"}]}`,
+	`{"conditions":[{"field":"rating","operator":"range","value":{"gte":2,"lte":4}}],"operator":0}`,
+	`{"conditions":[{"field":"tags","operator":"contains","value":"new"},{"field":"price","operator":"lt","value":10}],"operator":1}`,
+	`{"conditions":[{"field":"meta.owner","operator":"exists","value":true}]}`,
+	`{"conditions":[{"field":"url","operator":"regex","value":"^https://"}]}`,
+	`{"conditions":[]}`,
+	`null`,
+}
+
+func TestFilterJSONRoundTrip(t *testing.T) {
+	regex, err := NewRegexCondition("name", "^item-[0-9]+$")
+	if err != nil {
+		t.Fatalf("Failed to build regex condition: %v", err)
+	}
+	filters := []*MetadataFilter{
+		NewAndFilter(NewEqualsCondition("rating", 3)),
+		NewAndFilter(FilterCondition{Field: "name", Operator: "neq", Value: "item-2"}),
+		NewAndFilter(FilterCondition{Field: "rating", Operator: "gt", Value: 2}),
+		NewAndFilter(FilterCondition{Field: "price", Operator: "lte", Value: 9.5}),
+		NewAndFilter(NewRangeCondition("rating", 2, 4)),
+		NewAndFilter(FilterCondition{Field: "tags", Operator: "contains", Value: "sale"}),
+		NewAndFilter(NewExistsCondition("meta.owner", false)),
+		NewAndFilter(NewPrefixCondition("name", "item-1"), regex),
+		NewOrFilter(NewEqualsCondition("meta.owner", "bob"), NewRangeCondition("price", 0, 5)),
+	}
+
+	var vectors []*Vector
+	for i := 0; i < 6; i++ {
+		metadata := map[string]interface{}{
+			"name":   fmt.Sprintf("item-%d", i),
+			"rating": i,
+			"price":  float64(i) * 2.5,
+			"tags":   []interface{}{"new", []string{"sale", "old"}[i%2]},
+		}
+		if i%3 == 0 {
+			metadata["meta"] = map[string]interface{}{"owner": []string{"alice", "bob"}[i%2]}
+		}
+		vectors = append(vectors, NewVector(fmt.Sprintf("v%d", i), []float32{1, 0}, metadata))
+	}
+
+	for i, filter := range filters {
+		data, err := filter.ToJSON()
+		if err != nil {
+			t.Fatalf("Filter %d: ToJSON failed: %v", i, err)
+		}
+		decoded, err := FilterFromJSON(data)
+		if err != nil {
+			t.Fatalf("Filter %d: FilterFromJSON(%s) failed: %v", i, data, err)
+		}
+		for _, v := range vectors {
+			if got, want := decoded.MatchVector(v), filter.MatchVector(v); got != want {
+				t.Errorf("Filter %d (%s) on %s: decoded filter matched %v, original %v", i, data, v.ID, got, want)
+			}
+		}
+	}
+}
+
+func TestFilterFromJSONErrors(t *testing.T) {
+	for _, seed := range filterJSONSeeds {
+		if _, err := FilterFromJSON([]byte(seed)); err != nil {
+			t.Errorf("Expected %s to decode, got %v", seed, err)
+		}
+	}
+
+	for _, input := range []string{
+		``,
+		`{"conditions":`,
+		`{"conditions":{"field":"a"}}`,
+		`{"conditions":[{"field":"a","operator":"regex","value":"("}]}`,
+		`{"conditions":[{"field":"a","operator":"regex","value":5}]}`,
+		`{"conditions":[],"operator":7}`,
+		`{"operator":"and"}`,
+	} {
+		if _, err := FilterFromJSON([]byte(input)); err == nil {
+			t.Errorf("Expected an error decoding %q", input)
+		}
+	}
+}
+
+func TestCompareMixedNumericTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		condition FilterCondition
+		expected  bool
+	}{
+		{"Int64GtInt", int64(5), FilterCondition{Field: "n", Operator: "gt", Value: 4}, true},
+		{"Int32LtFloat64", int32(5), FilterCondition{Field: "n", Operator: "lt", Value: 5.5}, true},
+		{"Float32GteInt64", float32(2.5), FilterCondition{Field: "n", Operator: "gte", Value: int64(3)}, false},
+		{"Float64LteFloat32", 1.5, FilterCondition{Field: "n", Operator: "lte", Value: float32(1.5)}, true},
+		{"Uint8EqFloat64", uint8(7), NewEqualsCondition("n", 7.0), true},
+		{"Int64InIntRange", int64(10), NewRangeCondition("n", 5, 10), true},
+		{"Float32BelowInt64Range", float32(4.9), NewRangeCondition("n", int64(5), int64(10)), false},
+		{"Float64InFloat32Range", 7.25, NewRangeCondition("n", float32(7), float32(7.5)), true},
+		{"NumberVsString", 5, FilterCondition{Field: "n", Operator: "gt", Value: "4"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewVector("v1", []float32{1}, map[string]interface{}{"n": tc.value})
+			if got := NewAndFilter(tc.condition).MatchVector(v); got != tc.expected {
+				t.Errorf("Expected %v %s %v to be %v", tc.value, tc.condition.Operator, tc.condition.Value, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDateRangeConditions(t *testing.T) {
+	published := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	vectors := map[string]*Vector{
+		"time":   NewVector("time", []float32{1}, map[string]interface{}{"published": published}),
+		"string": NewVector("string", []float32{1}, map[string]interface{}{"published": "2024-03-15T12:00:00Z"}),
+		"offset": NewVector("offset", []float32{1}, map[string]interface{}{"published": "2024-03-15T17:00:00+05:00"}),
+	}
+
+	inMarch := NewAndFilter(NewRangeCondition("published", "2024-03-01T00:00:00Z", "2024-03-31T23:59:59Z"))
+	after := NewAndFilter(FilterCondition{Field: "published", Operator: "gt", Value: published.Add(-time.Hour)})
+	before := NewAndFilter(FilterCondition{Field: "published", Operator: "lt", Value: "2024-03-15T11:00:00Z"})
+
+	for id, v := range vectors {
+		if !inMarch.MatchVector(v) {
+			t.Errorf("Expected %s to fall in the March range", id)
+		}
+		if !after.MatchVector(v) {
+			t.Errorf("Expected %s to be after an hour earlier", id)
+		}
+		if before.MatchVector(v) {
+			t.Errorf("Expected %s not to be before 11:00", id)
+		}
+	}
+
+	// 12:00 at +05:00 is 07:00 UTC: lexically after 11:00Z, chronologically before
+	early := NewVector("early", []float32{1}, map[string]interface{}{"published": "2024-03-15T12:00:00+05:00"})
+	if !before.MatchVector(early) {
+		t.Errorf("Expected dates with offsets to be compared chronologically")
+	}
+}
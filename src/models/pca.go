@@ -0,0 +1,197 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// pcaIterations bounds the power iterations spent on each component
+const pcaIterations = 500
+
+// PCAProjection maps vectors onto the principal components of a sample,
+// reducing their dimension while keeping as much of the variance as possible
+type PCAProjection struct {
+	Mean       []float32   `json:"mean"`       // Sample mean, subtracted before projecting
+	Components [][]float32 `json:"components"` // Orthonormal components, largest variance first
+}
+
+// FitPCA computes the top targetDim principal components of the sample using
+// power iteration with deflation on its covariance matrix
+func FitPCA(sample [][]float32, targetDim int) (*PCAProjection, error) {
+	if len(sample) < 2 {
+		return nil, fmt.Errorf("PCA needs at least 2 sample vectors: %w", ErrInvalidArgument)
+	}
+	dim := len(sample[0])
+	if targetDim <= 0 || targetDim >= dim {
+		return nil, fmt.Errorf("target dimension must be between 1 and %d, got %d: %w", dim-1, targetDim, ErrInvalidArgument)
+	}
+	for i, values := range sample {
+		if len(values) != dim {
+			return nil, fmt.Errorf("sample vector %d has dimension %d, expected %d: %w",
+				i, len(values), dim, ErrDimensionMismatch)
+		}
+	}
+
+	mean := make([]float64, dim)
+	for _, values := range sample {
+		for j, val := range values {
+			mean[j] += float64(val)
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(len(sample))
+	}
+
+	cov := make([][]float64, dim)
+	for i := range cov {
+		cov[i] = make([]float64, dim)
+	}
+	centered := make([]float64, dim)
+	for _, values := range sample {
+		for j, val := range values {
+			centered[j] = float64(val) - mean[j]
+		}
+		for i := 0; i < dim; i++ {
+			if centered[i] == 0 {
+				continue
+			}
+			row := cov[i]
+			for j := i; j < dim; j++ {
+				row[j] += centered[i] * centered[j]
+			}
+		}
+	}
+	for i := 0; i < dim; i++ {
+		for j := i; j < dim; j++ {
+			cov[i][j] /= float64(len(sample) - 1)
+			cov[j][i] = cov[i][j]
+		}
+	}
+
+	components := make([][]float64, 0, targetDim)
+	for c := 0; c < targetDim; c++ {
+		component, eigenvalue, err := powerIteration(cov, components)
+		if err != nil {
+			return nil, fmt.Errorf("component %d: %w", c, err)
+		}
+		components = append(components, component)
+
+		// Deflate so the next iteration converges to the next component
+		for i := 0; i < dim; i++ {
+			for j := 0; j < dim; j++ {
+				cov[i][j] -= eigenvalue * component[i] * component[j]
+			}
+		}
+	}
+
+	p := &PCAProjection{
+		Mean:       make([]float32, dim),
+		Components: make([][]float32, targetDim),
+	}
+	for j, val := range mean {
+		p.Mean[j] = float32(val)
+	}
+	for c, component := range components {
+		p.Components[c] = make([]float32, dim)
+		for j, val := range component {
+			p.Components[c][j] = float32(val)
+		}
+	}
+	return p, nil
+}
+
+// powerIteration finds the dominant eigenvector of the symmetric matrix m,
+// kept orthogonal to the components already found
+func powerIteration(m [][]float64, found [][]float64) ([]float64, float64, error) {
+	dim := len(m)
+
+	// A deterministic, uneven start avoids being orthogonal to the answer
+	v := make([]float64, dim)
+	for i := range v {
+		v[i] = 1 + float64(i%7)/7
+	}
+	orthonormalize(v, found)
+
+	next := make([]float64, dim)
+	for iter := 0; iter < pcaIterations; iter++ {
+		for i, row := range m {
+			var sum float64
+			for j, val := range row {
+				sum += val * v[j]
+			}
+			next[i] = sum
+		}
+		if !orthonormalize(next, found) {
+			return nil, 0, fmt.Errorf("sample does not span enough dimensions: %w", ErrInvalidArgument)
+		}
+
+		var delta float64
+		for i := range v {
+			delta += math.Abs(next[i] - v[i])
+		}
+		v, next = next, v
+		if delta < 1e-10 {
+			break
+		}
+	}
+
+	var eigenvalue float64
+	for i, row := range m {
+		var sum float64
+		for j, val := range row {
+			sum += val * v[j]
+		}
+		eigenvalue += v[i] * sum
+	}
+	return v, eigenvalue, nil
+}
+
+// orthonormalize removes the projections of v onto the (orthonormal) basis
+// vectors and scales it to unit length. It reports false if nothing is left.
+func orthonormalize(v []float64, basis [][]float64) bool {
+	for _, b := range basis {
+		var dot float64
+		for i := range v {
+			dot += v[i] * b[i]
+		}
+		for i := range v {
+			v[i] -= dot * b[i]
+		}
+	}
+
+	var norm float64
+	for _, val := range v {
+		norm += val * val
+	}
+	norm = math.Sqrt(norm)
+	if norm < 1e-12 {
+		return false
+	}
+	for i := range v {
+		v[i] /= norm
+	}
+	return true
+}
+
+// InputDim returns the dimension of the vectors the projection accepts
+func (p *PCAProjection) InputDim() int {
+	return len(p.Mean)
+}
+
+// OutputDim returns the dimension of projected vectors
+func (p *PCAProjection) OutputDim() int {
+	return len(p.Components)
+}
+
+// Project maps values (of InputDim dimensions) into the reduced space
+func (p *PCAProjection) Project(values []float32) []float32 {
+	projected := make([]float32, len(p.Components))
+	for c, component := range p.Components {
+		var sum float32
+		for j, val := range values {
+			sum += (val - p.Mean[j]) * component[j]
+		}
+		projected[c] = sum
+	}
+	return projected
+}
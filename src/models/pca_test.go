@@ -0,0 +1,210 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// correlatedVectors generates n vectors of dim dimensions driven by a few
+// latent factors plus a little noise, so most variance lies in a subspace
+func correlatedVectors(n, dim, factors int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	loadings := make([][]float64, factors)
+	for f := range loadings {
+		loadings[f] = make([]float64, dim)
+		for j := range loadings[f] {
+			loadings[f][j] = rng.NormFloat64()
+		}
+	}
+
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for f := range loadings {
+			weight := rng.NormFloat64() * float64(factors-f)
+			for j := range vectors[i] {
+				vectors[i][j] += float32(weight * loadings[f][j])
+			}
+		}
+		for j := range vectors[i] {
+			vectors[i][j] += float32(rng.NormFloat64() * 0.05)
+		}
+	}
+	return vectors
+}
+
+// nearest returns the indexes of the k vectors closest (Euclidean) to query
+func nearest(query []float32, vectors [][]float32, k int) []int {
+	order := make([]int, len(vectors))
+	dists := make([]float64, len(vectors))
+	for i, v := range vectors {
+		order[i] = i
+		for j := range v {
+			d := float64(query[j] - v[j])
+			dists[i] += d * d
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return dists[order[a]] < dists[order[b]] })
+	return order[:k]
+}
+
+func TestFitPCAPreservesNeighbors(t *testing.T) {
+	data := correlatedVectors(500, 32, 4, 1)
+	projection, err := FitPCA(data, 4)
+	if err != nil {
+		t.Fatalf("FitPCA failed: %v", err)
+	}
+	if projection.InputDim() != 32 || projection.OutputDim() != 4 {
+		t.Fatalf("Expected a 32 -> 4 projection, got %d -> %d", projection.InputDim(), projection.OutputDim())
+	}
+
+	// Components must be orthonormal
+	for a := range projection.Components {
+		for b := range projection.Components {
+			var dot float64
+			for j := range projection.Components[a] {
+				dot += float64(projection.Components[a][j] * projection.Components[b][j])
+			}
+			expected := 0.0
+			if a == b {
+				expected = 1
+			}
+			if math.Abs(dot-expected) > 1e-4 {
+				t.Errorf("Components %d and %d have dot product %f, expected %f", a, b, dot, expected)
+			}
+		}
+	}
+
+	projected := make([][]float32, len(data))
+	for i, v := range data {
+		projected[i] = projection.Project(v)
+	}
+
+	const k = 10
+	overlap := 0
+	queries := correlatedVectors(20, 32, 4, 2)
+	for _, query := range queries {
+		full := nearest(query, data, k)
+		reduced := nearest(projection.Project(query), projected, k)
+		inFull := make(map[int]bool, k)
+		for _, i := range full {
+			inFull[i] = true
+		}
+		for _, i := range reduced {
+			if inFull[i] {
+				overlap++
+			}
+		}
+	}
+	if recall := float64(overlap) / float64(k*len(queries)); recall < 0.9 {
+		t.Errorf("Expected at least 90%% of neighbors preserved, got %.2f", recall)
+	}
+}
+
+func TestFitPCAValidation(t *testing.T) {
+	data := correlatedVectors(10, 4, 2, 1)
+	if _, err := FitPCA(data[:1], 2); err == nil {
+		t.Errorf("Expected an error for a single sample vector")
+	}
+	if _, err := FitPCA(data, 4); err == nil {
+		t.Errorf("Expected an error for a target dimension that does not reduce")
+	}
+	if _, err := FitPCA(append(data, []float32{1, 2}), 2); err == nil {
+		t.Errorf("Expected an error for mixed dimensions")
+	}
+}
+
+// rebuildWith returns an ApplyProjection rebuild function that replaces every
+// index with the given one
+func rebuildWith(index VectorIndex) func(string, VectorIndex) (VectorIndex, error) {
+	return func(string, VectorIndex) (VectorIndex, error) {
+		return index, nil
+	}
+}
+
+func TestApplyProjection(t *testing.T) {
+	collection := NewVectorCollection("test", 3, Euclidean)
+	if err := collection.AddIndex("mock", newMockIndex(3)); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	if err := collection.Insert(NewVector("v1", []float32{1, 2, 3}, nil)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// Keep the first two coordinates
+	projection := &PCAProjection{
+		Mean:       []float32{0, 0, 0},
+		Components: [][]float32{{1, 0, 0}, {0, 1, 0}},
+	}
+	if err := collection.ApplyProjection(projection, rebuildWith(newMockIndex(3))); err == nil {
+		t.Fatalf("Expected indexes of the wrong dimension to be rejected")
+	}
+	reduced := newMockIndex(2)
+	if err := collection.ApplyProjection(projection, rebuildWith(reduced)); err != nil {
+		t.Fatalf("ApplyProjection failed: %v", err)
+	}
+	if collection.IndexDimension() != 2 {
+		t.Errorf("Expected index dimension 2, got %d", collection.IndexDimension())
+	}
+
+	if v := reduced.vectors["v1"]; v == nil || len(v.Values) != 2 || v.Values[1] != 2 {
+		t.Errorf("Expected v1 to be re-indexed as [1 2], got %v", v)
+	}
+	if err := collection.Insert(NewVector("v2", []float32{4, 5, 6}, nil)); err != nil {
+		t.Fatalf("Insert after projection failed: %v", err)
+	}
+	if v := reduced.vectors["v2"]; v == nil || len(v.Values) != 2 || v.Values[0] != 4 {
+		t.Errorf("Expected v2 to be projected to [4 5], got %v", v)
+	}
+
+	for _, query := range [][]float32{{1, 2, 3}, {1, 2}} {
+		if _, err := collection.Search(query, 1, nil, nil); err != nil {
+			t.Errorf("Search with a %d-dimensional query failed: %v", len(query), err)
+		}
+	}
+	if _, err := collection.Search([]float32{1}, 1, nil, nil); err == nil {
+		t.Errorf("Expected a dimension mismatch for a 1-dimensional query")
+	}
+
+	// Scanned queries are projected into the space of the scanned vectors
+	scanned := 0
+	queries, err := collection.ScanQueries([][]float32{{1, 2, 3}, {1, 2}}, func(v *Vector) bool {
+		if len(v.Values) != 2 {
+			t.Errorf("Expected scanned vectors of dimension 2, got %v", v.Values)
+		}
+		scanned++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ScanQueries failed: %v", err)
+	}
+	if len(queries) != 2 || len(queries[0]) != 2 || len(queries[1]) != 2 || scanned != 2 {
+		t.Errorf("Expected two 2-dimensional queries and 2 vectors, got %v and %d", queries, scanned)
+	}
+	if _, err := collection.ScanQueries([][]float32{{1}}, func(*Vector) bool { return true }); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from ScanQueries, got %v", err)
+	}
+	if err := collection.ApplyProjection(projection, rebuildWith(newMockIndex(2))); !errors.Is(err, ErrAlreadyProjected) {
+		t.Errorf("Expected ErrAlreadyProjected for a second projection, got %v", err)
+	}
+
+	// Stored vectors are already projected, so updates keep their values
+	if _, err := collection.UpdateMetadata("v1", map[string]interface{}{"tag": "x"}, 0); err != nil {
+		t.Fatalf("UpdateMetadata on a projected collection failed: %v", err)
+	}
+	if v := reduced.vectors["v1"]; len(v.Values) != 2 || v.Values[0] != 1 || v.Values[1] != 2 || v.Metadata["tag"] != "x" {
+		t.Errorf("Expected v1 to keep values [1 2] with the new metadata, got %v", v)
+	}
+
+	// Writes must still use the full dimension
+	if err := collection.Insert(NewVector("v3", []float32{1, 2}, nil)); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from Insert of a reduced vector, got %v", err)
+	}
+	err = collection.BatchInsert([]*Vector{NewVector("v3", []float32{1, 2}, nil)})
+	if !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from BatchInsert of a reduced vector, got %v", err)
+	}
+}
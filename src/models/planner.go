@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// planSampleSize bounds the vectors Explain scans to estimate how many pass
+// the filter
+const planSampleSize = 1000
+
+// QueryPlan describes how the collection would run a search, as reported by
+// Explain without executing it
+type QueryPlan struct {
+	Index               string  `json:"index"`                // Index the search would use
+	IndexType           string  `json:"index_type"`           // Go type of that index
+	Exact               bool    `json:"exact"`                // Whether the index compares the query against every candidate
+	Metric              string  `json:"metric"`               // Metric results are ranked by
+	Projected           bool    `json:"projected"`            // Whether the query is reduced by the collection's PCA projection
+	FilterConditions    int     `json:"filter_conditions"`    // Filter conditions evaluated during the search (0 = unfiltered)
+	IndexedFilter       bool    `json:"indexed_filter"`       // Whether the inverted metadata index narrows the candidates
+	FilterSelectivity   float64 `json:"filter_selectivity"`   // Estimated fraction of vectors passing the filter
+	EstimatedCandidates int     `json:"estimated_candidates"` // Estimated vectors passing the filter
+	Rerank              bool    `json:"rerank"`               // Whether results are re-ranked after the search (boosts)
+}
+
+// chooseIndexLocked picks the index a search runs on. Exact searches prefer an
+// index reporting itself exact and other searches prefer an approximate one;
+// ties go to the first index by name. Callers must hold the lock and ensure
+// the collection has an index.
+func (c *VectorCollection) chooseIndexLocked(params *SearchParams) (string, VectorIndex) {
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	wantExact := params != nil && params.Exact
+	for _, name := range names {
+		if isExactIndex(c.Indexes[name]) == wantExact {
+			return name, c.Indexes[name]
+		}
+	}
+	return names[0], c.Indexes[names[0]]
+}
+
+// isExactIndex reports whether the index declares its searches exact
+func isExactIndex(index VectorIndex) bool {
+	exact, ok := index.(ExactSearcher)
+	return ok && exact.Exact()
+}
+
+// Explain reports the plan Search would follow for the request: the index it
+// would choose, how selective the filter is estimated to be (from a sample of
+// at most planSampleSize vectors) and whether results would be re-ranked. The
+// search itself is not run.
+func (c *VectorCollection) Explain(request *QueryRequest) (*QueryPlan, error) {
+	if request.Filter != nil {
+		if err := request.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	params := request.Params
+	if params == nil {
+		params = NewSearchParams()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.Indexes) == 0 {
+		return nil, fmt.Errorf("no indexes available in collection %s", c.Name)
+	}
+
+	metric := c.DistanceFunc
+	if params.Metric != nil {
+		metric = *params.Metric
+	}
+	name, index := c.chooseIndexLocked(params)
+	plan := &QueryPlan{
+		Index:             name,
+		IndexType:         fmt.Sprintf("%T", index),
+		Exact:             isExactIndex(index),
+		Metric:            metric.String(),
+		Projected:         c.Projection != nil && len(request.Vector) == c.Dimension,
+		FilterSelectivity: 1,
+		Rerank:            len(request.Boosts) > 0,
+	}
+
+	total := len(c.versions)
+	if request.Filter != nil && len(request.Filter.Conditions) > 0 {
+		plan.FilterConditions = len(request.Filter.Conditions)
+		_, plan.IndexedFilter = c.candidatesLocked(index, request.Filter)
+
+		sampled, matched := 0, 0
+		if err := c.scan(func(vector *Vector) bool {
+			sampled++
+			if request.Filter.MatchVector(vector) {
+				matched++
+			}
+			return sampled < planSampleSize
+		}); err != nil {
+			return nil, err
+		}
+		if sampled > 0 {
+			plan.FilterSelectivity = float64(matched) / float64(sampled)
+		}
+	}
+	plan.EstimatedCandidates = int(math.Round(plan.FilterSelectivity * float64(total)))
+
+	return plan, nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+)
+
+// searchCache is a fixed-size LRU cache of search results. Entries are keyed
+// by a hash of everything that influences a search, and the whole cache is
+// cleared whenever the collection changes.
+type searchCache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // Front is most recently used
+	hits     uint64
+	misses   uint64
+	mu       sync.Mutex
+}
+
+// searchCacheEntry is the value stored in the LRU list
+type searchCacheEntry struct {
+	key     string
+	results []SearchResult
+}
+
+// newSearchCache creates an empty cache holding up to capacity searches
+func newSearchCache(capacity int) *searchCache {
+	return &searchCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// searchCacheKey hashes a search request. ok is false if the request cannot
+// be encoded (e.g. a filter value that is not JSON-serializable), in which
+// case the search should bypass the cache.
+func searchCacheKey(query []float32, k int, filter *MetadataFilter, params *SearchParams) (string, bool) {
+	h := fnv.New128a()
+	binary.Write(h, binary.LittleEndian, query)
+	binary.Write(h, binary.LittleEndian, int64(k))
+
+	// Partial is an output and must not affect the key
+	var paramsCopy SearchParams
+	if params != nil {
+		paramsCopy = *params
+		paramsCopy.Partial = false
+	}
+	encoded, err := json.Marshal(struct {
+		Filter *MetadataFilter
+		Params SearchParams
+	}{filter, paramsCopy})
+	if err != nil {
+		return "", false
+	}
+	h.Write(encoded)
+
+	return string(h.Sum(nil)), true
+}
+
+// get returns a copy of the cached results for key, if present
+func (sc *searchCache) get(key string) ([]SearchResult, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	elem, ok := sc.entries[key]
+	if !ok {
+		sc.misses++
+		return nil, false
+	}
+	sc.hits++
+	sc.order.MoveToFront(elem)
+	return copyResults(elem.Value.(*searchCacheEntry).results), true
+}
+
+// put stores a copy of results under key, evicting the least recently used
+// entry if the cache is full
+func (sc *searchCache) put(key string, results []SearchResult) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if elem, ok := sc.entries[key]; ok {
+		elem.Value.(*searchCacheEntry).results = copyResults(results)
+		sc.order.MoveToFront(elem)
+		return
+	}
+
+	sc.entries[key] = sc.order.PushFront(&searchCacheEntry{key: key, results: copyResults(results)})
+	if sc.order.Len() > sc.capacity {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*searchCacheEntry).key)
+	}
+}
+
+// clear drops every cached entry. Safe to call on a nil cache.
+func (sc *searchCache) clear() {
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.entries = make(map[string]*list.Element)
+	sc.order.Init()
+}
+
+// stats returns the hit and miss counters
+func (sc *searchCache) stats() (hits, misses uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.hits, sc.misses
+}
+
+// copyResults returns a shallow copy of a result slice so callers cannot
+// modify cached entries
+func copyResults(results []SearchResult) []SearchResult {
+	copied := make([]SearchResult, len(results))
+	copy(copied, results)
+	return copied
+}
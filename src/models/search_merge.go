@@ -0,0 +1,81 @@
+package models
+
+import "container/heap"
+
+// MergeSearchResults k-way merges per-shard result slices into a global top
+// k. Each shard must already be sorted best first, as indexes return them.
+// When the same vector ID appears in several shards only its best result is
+// kept. Ties are broken by ID, matching the order indexes use.
+func MergeSearchResults(shards [][]SearchResult, k int, higherIsBetter bool) []SearchResult {
+	h := &mergeHeap{higherIsBetter: higherIsBetter}
+	total := 0
+	for shard, results := range shards {
+		if len(results) > 0 {
+			h.cursors = append(h.cursors, mergeCursor{shard: shard})
+			total += len(results)
+		}
+	}
+	h.shards = shards
+	heap.Init(h)
+
+	if k > total {
+		k = total
+	}
+	merged := make([]SearchResult, 0, k)
+	seen := make(map[string]bool, k)
+	for h.Len() > 0 && len(merged) < k {
+		cursor := &h.cursors[0]
+		result := shards[cursor.shard][cursor.pos]
+
+		// Results pop best first, so the first occurrence of an ID is its best
+		if !seen[result.ID] {
+			seen[result.ID] = true
+			merged = append(merged, result)
+		}
+
+		cursor.pos++
+		if cursor.pos == len(shards[cursor.shard]) {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return merged
+}
+
+// mergeCursor tracks the next unmerged result of one shard
+type mergeCursor struct {
+	shard int
+	pos   int
+}
+
+// mergeHeap orders shard cursors by the result each one points at
+type mergeHeap struct {
+	shards         [][]SearchResult
+	cursors        []mergeCursor
+	higherIsBetter bool
+}
+
+func (h *mergeHeap) Len() int { return len(h.cursors) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a := h.shards[h.cursors[i].shard][h.cursors[i].pos]
+	b := h.shards[h.cursors[j].shard][h.cursors[j].pos]
+	if a.Distance != b.Distance {
+		if h.higherIsBetter {
+			return a.Distance > b.Distance
+		}
+		return a.Distance < b.Distance
+	}
+	return a.ID < b.ID
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.cursors = append(h.cursors, x.(mergeCursor)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+// resultIDs extracts the IDs of a result list, in order
+func resultIDs(results []SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestMergeSearchResults(t *testing.T) {
+	t.Run("OverlappingIDs", func(t *testing.T) {
+		shards := [][]SearchResult{
+			{{ID: "a", Distance: 0.1}, {ID: "c", Distance: 0.5}, {ID: "d", Distance: 0.9}},
+			{{ID: "b", Distance: 0.2}, {ID: "c", Distance: 0.3}},
+			{{ID: "a", Distance: 0.4}},
+		}
+		merged := MergeSearchResults(shards, 10, false)
+
+		expected := []string{"a", "b", "c", "d"}
+		if got := resultIDs(merged); len(got) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+		for i, id := range expected {
+			if merged[i].ID != id {
+				t.Errorf("Position %d: expected %s, got %s", i, id, merged[i].ID)
+			}
+		}
+		// The better copy of a duplicated ID wins
+		if merged[0].Distance != 0.1 || merged[2].Distance != 0.3 {
+			t.Errorf("Expected the best copies of a and c, got %+v", merged)
+		}
+	})
+
+	t.Run("UnevenShards", func(t *testing.T) {
+		shards := [][]SearchResult{
+			{},
+			{{ID: "x", Distance: 0.9}, {ID: "y", Distance: 0.8}, {ID: "z", Distance: 0.1}},
+			nil,
+			{{ID: "w", Distance: 0.85}},
+		}
+		merged := MergeSearchResults(shards, 3, true)
+
+		expected := []string{"x", "w", "y"}
+		got := resultIDs(merged)
+		if len(got) != 3 || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("FewerThanK", func(t *testing.T) {
+		shards := [][]SearchResult{{{ID: "b", Distance: 1}}, {{ID: "a", Distance: 1}}}
+		got := resultIDs(MergeSearchResults(shards, 10, false))
+		if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("Expected ties broken by ID as [a b], got %v", got)
+		}
+		if merged := MergeSearchResults(nil, 5, false); len(merged) != 0 {
+			t.Errorf("Expected no results from no shards, got %v", merged)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default candidate list sizes (HnswEf) of the approximate search strategies
+const (
+	DefaultHnswEf = 100 // Default strategy
+	FastHnswEf    = 40  // FastSearch: lower ef for faster search
+	PreciseHnswEf = 300 // PreciseSearch: higher ef for more accurate search
+)
+
+// SearchParamsBuilder assembles SearchParams fluently and validates them on
+// Build, so strategy-specific fields cannot be silently misconfigured:
+//
+//	params, err := NewSearchParamsBuilder().
+//		WithStrategy(PreciseSearch).
+//		WithScoreThreshold(0.8).
+//		Build()
+//
+// Fields left unset get the defaults of the chosen strategy.
+type SearchParamsBuilder struct {
+	params SearchParams
+	efSet  bool // WithEf was called, so HnswEf is not defaulted
+}
+
+// NewSearchParamsBuilder starts building parameters for the Default strategy
+func NewSearchParamsBuilder() *SearchParamsBuilder {
+	return &SearchParamsBuilder{params: SearchParams{SearchStrategy: Default}}
+}
+
+// WithStrategy sets the search strategy
+func (b *SearchParamsBuilder) WithStrategy(strategy SearchStrategy) *SearchParamsBuilder {
+	b.params.SearchStrategy = strategy
+	return b
+}
+
+// WithEf sets the size of the HNSW dynamic candidate list. Only approximate
+// strategies use it, and it must be positive.
+func (b *SearchParamsBuilder) WithEf(ef int) *SearchParamsBuilder {
+	b.params.HnswEf = ef
+	b.efSet = true
+	return b
+}
+
+// WithNprobe sets how many inverted-file cells an IVF index probes (0 = the
+// index's default)
+func (b *SearchParamsBuilder) WithNprobe(nprobe int) *SearchParamsBuilder {
+	b.params.IvfNprobe = nprobe
+	return b
+}
+
+// WithScoreThreshold sets the minimum normalized score of results, between 0
+// and 1. Zero keeps the collection's MinScore.
+func (b *SearchParamsBuilder) WithScoreThreshold(threshold float32) *SearchParamsBuilder {
+	b.params.ScoreThreshold = threshold
+	return b
+}
+
+// WithoutScoreFloor returns every result regardless of score, overriding the
+// collection's MinScore
+func (b *SearchParamsBuilder) WithoutScoreFloor() *SearchParamsBuilder {
+	b.params.ScoreThreshold = -1
+	return b
+}
+
+// WithIndexedOnly restricts the search to indexed segments
+func (b *SearchParamsBuilder) WithIndexedOnly(indexedOnly bool) *SearchParamsBuilder {
+	b.params.IndexedOnly = indexedOnly
+	return b
+}
+
+// WithQuantization enables searching quantized vectors
+func (b *SearchParamsBuilder) WithQuantization(useQuantization bool) *SearchParamsBuilder {
+	b.params.UseQuantization = useQuantization
+	return b
+}
+
+// WithMetric overrides the index's distance metric
+func (b *SearchParamsBuilder) WithMetric(metric DistanceMetric) *SearchParamsBuilder {
+	b.params.Metric = &metric
+	return b
+}
+
+// WithTimeout bounds how long an index may scan (0 = no limit)
+func (b *SearchParamsBuilder) WithTimeout(timeout time.Duration) *SearchParamsBuilder {
+	b.params.Timeout = timeout
+	return b
+}
+
+// WithExplain attaches a debug trace to each result
+func (b *SearchParamsBuilder) WithExplain(explain bool) *SearchParamsBuilder {
+	b.params.Explain = explain
+	return b
+}
+
+// Build validates the parameters and returns them with the strategy's
+// defaults filled in. Errors wrap ErrInvalidSearchParams.
+func (b *SearchParamsBuilder) Build() (*SearchParams, error) {
+	params := b.params
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	switch params.SearchStrategy {
+	case ExactSearch:
+		params.Exact = true
+	case FastSearch:
+		if !b.efSet {
+			params.HnswEf = FastHnswEf
+		}
+	case PreciseSearch:
+		if !b.efSet {
+			params.HnswEf = PreciseHnswEf
+		}
+	case Default:
+		if !b.efSet {
+			params.HnswEf = DefaultHnswEf
+		}
+	}
+	return &params, nil
+}
+
+// validate rejects out-of-range values and settings the strategy cannot use
+func (b *SearchParamsBuilder) validate() error {
+	params := b.params
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf(format+": %w", append(args, ErrInvalidSearchParams)...)
+	}
+
+	switch params.SearchStrategy {
+	case Default, ExactSearch, FastSearch, PreciseSearch, BatchSearch:
+	default:
+		return invalid("unknown search strategy %d", params.SearchStrategy)
+	}
+
+	if params.ScoreThreshold != -1 && (params.ScoreThreshold < 0 || params.ScoreThreshold > 1) {
+		return invalid("score threshold must be between 0 and 1, got %v", params.ScoreThreshold)
+	}
+	if params.IvfNprobe < 0 {
+		return invalid("nprobe cannot be negative, got %d", params.IvfNprobe)
+	}
+	if params.Timeout < 0 {
+		return invalid("timeout cannot be negative, got %v", params.Timeout)
+	}
+
+	switch params.SearchStrategy {
+	case ExactSearch:
+		// Exact search compares against every vector, bypassing indexes
+		if b.efSet {
+			return invalid("exact search does not use ef, got %d", params.HnswEf)
+		}
+		if params.IvfNprobe != 0 {
+			return invalid("exact search does not use nprobe, got %d", params.IvfNprobe)
+		}
+		if params.IndexedOnly {
+			return invalid("exact search cannot be restricted to indexed segments")
+		}
+		if params.UseQuantization {
+			return invalid("exact search cannot use quantized vectors")
+		}
+	case BatchSearch:
+		// Batches share one exact scan
+		if b.efSet {
+			return invalid("batch search does not use ef, got %d", params.HnswEf)
+		}
+	default:
+		if b.efSet && params.HnswEf <= 0 {
+			return invalid("ef must be positive, got %d", params.HnswEf)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchParamsBuilderMatchesPresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *SearchParamsBuilder
+		want    *SearchParams
+	}{
+		{"default", NewSearchParamsBuilder(), NewSearchParams()},
+		{"fast", NewSearchParamsBuilder().WithStrategy(FastSearch), NewFastSearchParams()},
+		{"precise", NewSearchParamsBuilder().WithStrategy(PreciseSearch), NewPreciseSearchParams()},
+	}
+	for _, tt := range tests {
+		got, err := tt.builder.Build()
+		if err != nil {
+			t.Fatalf("%s: Build failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSearchParamsBuilderFillsStrategyDefaults(t *testing.T) {
+	params, err := NewSearchParamsBuilder().WithStrategy(ExactSearch).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if !params.Exact || params.HnswEf != 0 {
+		t.Errorf("Expected an exact search without ef, got %+v", params)
+	}
+
+	params, err = NewSearchParamsBuilder().WithStrategy(PreciseSearch).WithEf(500).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if params.HnswEf != 500 {
+		t.Errorf("Expected an explicit ef to be kept, got %d", params.HnswEf)
+	}
+
+	params, err = NewSearchParamsBuilder().
+		WithScoreThreshold(0.5).
+		WithNprobe(4).
+		WithMetric(Euclidean).
+		WithTimeout(time.Second).
+		WithExplain(true).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if params.ScoreThreshold != 0.5 || params.IvfNprobe != 4 || params.Metric == nil || *params.Metric != Euclidean ||
+		params.Timeout != time.Second || !params.Explain {
+		t.Errorf("Expected the configured fields to be kept, got %+v", params)
+	}
+
+	params, err = NewSearchParamsBuilder().WithoutScoreFloor().Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	c := NewVectorCollection("test", 2, Cosine)
+	c.MinScore = 0.9
+	if floor := c.ScoreFloor(params); floor != 0 {
+		t.Errorf("Expected WithoutScoreFloor to disable the collection's floor, got %v", floor)
+	}
+}
+
+func TestSearchParamsBuilderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *SearchParamsBuilder
+	}{
+		{"unknown strategy", NewSearchParamsBuilder().WithStrategy(SearchStrategy(42))},
+		{"negative threshold", NewSearchParamsBuilder().WithScoreThreshold(-0.5)},
+		{"threshold above 1", NewSearchParamsBuilder().WithScoreThreshold(1.5)},
+		{"negative nprobe", NewSearchParamsBuilder().WithNprobe(-1)},
+		{"negative timeout", NewSearchParamsBuilder().WithTimeout(-time.Second)},
+		{"exact with ef", NewSearchParamsBuilder().WithStrategy(ExactSearch).WithEf(64)},
+		{"exact with nprobe", NewSearchParamsBuilder().WithStrategy(ExactSearch).WithNprobe(8)},
+		{"exact indexed only", NewSearchParamsBuilder().WithStrategy(ExactSearch).WithIndexedOnly(true)},
+		{"exact with quantization", NewSearchParamsBuilder().WithStrategy(ExactSearch).WithQuantization(true)},
+		{"batch with ef", NewSearchParamsBuilder().WithStrategy(BatchSearch).WithEf(64)},
+		{"zero ef", NewSearchParamsBuilder().WithStrategy(FastSearch).WithEf(0)},
+		{"negative ef", NewSearchParamsBuilder().WithEf(-10)},
+	}
+	for _, tt := range tests {
+		params, err := tt.builder.Build()
+		if !errors.Is(err, ErrInvalidSearchParams) {
+			t.Errorf("%s: expected ErrInvalidSearchParams, got %v (%+v)", tt.name, err, params)
+		}
+	}
+}
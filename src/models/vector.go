@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/rand"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"time"
@@ -13,14 +15,30 @@ type Vector struct {
 	Values    []float32              // Vector values (fixed dimensions per collection)
 	Metadata  map[string]interface{} // Optional associated metadata
 	Timestamp int64                  // Creation/modification timestamp
+	Version   uint64                 // Incremented on every update (optimistic concurrency)
 	Deleted   bool                   // Soft deletion marker
 }
 
+// MaxVectorIDLength is the longest vector ID accepted by collections
+const MaxVectorIDLength = 256
+
+// NewVectorID returns a random (version 4) UUID for use as a vector ID
+func NewVectorID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate vector ID: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 // SparseVector represents a sparse vector with explicit indices and values
 type SparseVector struct {
 	ID        string                 // Unique identifier
 	Indices   []int                  // Indices of non-zero elements
 	Values    []float32              // Values at those indices
+	Dimension int                    // Dimension of the dense equivalent
 	Metadata  map[string]interface{} // Optional associated metadata
 	Timestamp int64                  // Creation/modification timestamp
 	Deleted   bool                   // Soft deletion marker
@@ -49,6 +67,39 @@ func NewSparseVector(id string, indices []int, values []float32, metadata map[st
 	}
 }
 
+// ToDense expands the sparse vector into a dense vector of its Dimension,
+// keeping its ID, metadata and timestamp. Indices must be distinct and within
+// the dimension, with one value each.
+func (sv *SparseVector) ToDense() (*Vector, error) {
+	if sv.Dimension <= 0 {
+		return nil, fmt.Errorf("sparse vector %s: dimension must be positive, got %d: %w", sv.ID, sv.Dimension, ErrInvalidArgument)
+	}
+	if len(sv.Indices) != len(sv.Values) {
+		return nil, fmt.Errorf("sparse vector %s has %d indices but %d values: %w", sv.ID, len(sv.Indices), len(sv.Values), ErrInvalidArgument)
+	}
+	
+	values := make([]float32, sv.Dimension)
+	seen := make(map[int]bool, len(sv.Indices))
+	for i, index := range sv.Indices {
+		if index < 0 || index >= sv.Dimension {
+			return nil, fmt.Errorf("sparse vector %s: index %d out of range for dimension %d: %w",
+				sv.ID, index, sv.Dimension, ErrDimensionMismatch)
+		}
+		if seen[index] {
+			return nil, fmt.Errorf("sparse vector %s: duplicate index %d: %w", sv.ID, index, ErrInvalidArgument)
+		}
+		seen[index] = true
+		values[index] = sv.Values[i]
+	}
+	
+	return &Vector{
+		ID:        sv.ID,
+		Values:    values,
+		Metadata:  sv.Metadata,
+		Timestamp: sv.Timestamp,
+	}, nil
+}
+
 // Copy creates a deep copy of the vector
 func (v *Vector) Copy() *Vector {
 	valuesCopy := make([]float32, len(v.Values))
@@ -64,6 +115,7 @@ func (v *Vector) Copy() *Vector {
 		Values:    valuesCopy,
 		Metadata:  metadataCopy,
 		Timestamp: v.Timestamp,
+		Version:   v.Version,
 		Deleted:   v.Deleted,
 	}
 }
@@ -85,6 +137,16 @@ func (v *Vector) Normalize() {
 	}
 }
 
+// IsZero reports whether every value of the vector is zero
+func (v *Vector) IsZero() bool {
+	for _, val := range v.Values {
+		if val != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Dimension returns the dimensionality of the vector
 func (v *Vector) Dimension() int {
 	return len(v.Values)
@@ -127,18 +189,22 @@ func (v *Vector) Size() int {
 	return size + metadataSize
 }
 
-// Serialize converts the vector to a byte array for persistence
-func (v *Vector) Serialize() []byte {
+// Serialize converts the vector to a byte array for persistence. It fails if
+// the metadata cannot be encoded.
+func (v *Vector) Serialize() ([]byte, error) {
 	// This is a simplified serialization - in production we would use
 	// a more sophisticated approach or a library like Protocol Buffers
 	
 	// Calculate total size
 	idBytes := []byte(v.ID)
-	metadataBytes := serializeMetadata(v.Metadata)
+	metadataBytes, err := serializeMetadata(v.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("vector %s: %w", v.ID, err)
+	}
 	
 	// ID length (4) + ID + Values length (4) + Values + 
-	// Metadata length (4) + Metadata + Timestamp (8) + Deleted (1)
-	totalSize := 4 + len(idBytes) + 4 + len(v.Values)*4 + 4 + len(metadataBytes) + 8 + 1
+	// Metadata length (4) + Metadata + Timestamp (8) + Deleted (1) + Version (8)
+	totalSize := 4 + len(idBytes) + 4 + len(v.Values)*4 + 4 + len(metadataBytes) + 8 + 1 + 8
 	
 	buf := make([]byte, totalSize)
 	offset := 0
@@ -173,8 +239,12 @@ func (v *Vector) Serialize() []byte {
 	} else {
 		buf[offset] = 0
 	}
+	offset++
+	
+	// Write Version
+	binary.LittleEndian.PutUint64(buf[offset:], v.Version)
 	
-	return buf
+	return buf, nil
 }
 
 // Deserialize constructs a vector from its serialized form
@@ -238,30 +308,48 @@ func DeserializeVector(data []byte) (*Vector, error) {
 		return nil, ErrInvalidFormat
 	}
 	deleted := data[offset] == 1
+	offset++
+	
+	// Read Version, which vectors serialized before it was added lack
+	var version uint64
+	if offset+8 <= len(data) {
+		version = binary.LittleEndian.Uint64(data[offset:])
+	}
 	
 	return &Vector{
 		ID:        id,
 		Values:    values,
 		Metadata:  metadata,
 		Timestamp: timestamp,
+		Version:   version,
 		Deleted:   deleted,
 	}, nil
 }
 
-// serializeMetadata converts metadata to a byte array
-// This is a simplified implementation - in production you would use
-// a more sophisticated approach
-func serializeMetadata(metadata map[string]interface{}) []byte {
-	// Simplified implementation - just a placeholder
-	// In a real implementation, we would properly encode the structure
-	return []byte{}
+// serializeMetadata converts metadata to a byte array. Metadata is encoded as
+// JSON, so numbers come back as float64, and values JSON cannot represent are
+// an error.
+func serializeMetadata(metadata map[string]interface{}) ([]byte, error) {
+	if len(metadata) == 0 {
+		return []byte{}, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return data, nil
 }
 
 // deserializeMetadata reconstructs metadata from its serialized form
 func deserializeMetadata(data []byte) (map[string]interface{}, error) {
-	// Simplified implementation - just a placeholder
-	// In a real implementation, we would properly decode the structure
-	return map[string]interface{}{}, nil
+	metadata := map[string]interface{}{}
+	if len(data) == 0 {
+		return metadata, nil
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	return metadata, nil
 }
 
 // Common errors for serialization
@@ -1,8 +1,14 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,15 +19,71 @@ type VectorCollection struct {
 	DistanceFunc DistanceMetric        // Default distance metric
 	Indexes      map[string]VectorIndex // Multiple indexes for different vector fields
 	MetadataSchema *MetadataSchema     // Optional schema for metadata validation
+	Projection   *PCAProjection        // Optional reduction applied to vectors and queries before indexing
 	
 	// Collection-level settings
+	MaxVectors   int                   // Maximum number of live vectors (0 = unlimited)
+	AutoGenerateID bool                // Assign a UUID to vectors inserted without an ID
+	RejectZeroVectors bool             // Refuse vectors whose values are all zero
+	AllowedMetadataKeys []string       // Top-level metadata keys accepted on insert (empty = any)
+	MinScore     float32               // Default score floor for searches (0 = none)
 	CreatedAt    int64                 // Creation timestamp
 	UpdatedAt    int64                 // Last update timestamp
 	
 	// Operational fields (not serialized)
 	mu           sync.RWMutex          // For thread safety
+	versions     map[string]uint64     // Current version of each live vector
+	retired      map[string]uint64     // Last version of each deleted ID, so a re-created ID keeps counting up
+	cache        *searchCache          // Optional search result cache (nil = disabled)
+	idempotency  *idempotencyKeys      // Recently applied BulkUpsert keys (nil until first used)
+	observers    []func(VectorEvent)   // Called after successful writes (copied on write)
+	metadata     *metadataIndex        // Inverted index on declared metadata fields (nil = none)
 }
 
+// Sentinel errors returned (wrapped) by collection and index operations so
+// callers can tell failure categories apart with errors.Is
+var (
+	// ErrVectorNotFound is returned when a referenced vector does not exist
+	ErrVectorNotFound = errors.New("vector not found")
+	
+	// ErrDimensionMismatch is returned when a vector or query has the wrong dimension
+	ErrDimensionMismatch = errors.New("dimension mismatch")
+	
+	// ErrInvalidID is returned for empty (without AutoGenerateID) or overlong vector IDs
+	ErrInvalidID = errors.New("invalid vector ID")
+	
+	// ErrDuplicateID is returned by InsertNew when the ID is already in use
+	ErrDuplicateID = errors.New("duplicate vector ID")
+	
+	// ErrVersionConflict is returned when an optimistic concurrency check fails
+	// because the stored vector's version differs from the expected one
+	ErrVersionConflict = errors.New("version conflict")
+	
+	// ErrCapacityExceeded is returned when an insert would take the collection
+	// past its MaxVectors limit
+	ErrCapacityExceeded = errors.New("capacity exceeded")
+	
+	// ErrZeroVector is returned when inserting a zero vector into a collection
+	// with RejectZeroVectors set
+	ErrZeroVector = errors.New("zero vector")
+	
+	// ErrInvalidSearchParams is returned by SearchParamsBuilder.Build for
+	// out-of-range values or settings that contradict the search strategy
+	ErrInvalidSearchParams = errors.New("invalid search parameters")
+	
+	// ErrSchemaViolation is returned by a strict MigrateSchema when existing
+	// vectors do not conform to the new schema
+	ErrSchemaViolation = errors.New("schema violation")
+	
+	// ErrInvalidArgument is returned for invalid input that no more specific
+	// error describes, such as malformed filters or metadata
+	ErrInvalidArgument = errors.New("invalid argument")
+	
+	// ErrAlreadyProjected is returned by ApplyProjection for a collection that
+	// has already been projected
+	ErrAlreadyProjected = errors.New("collection already projected")
+)
+
 // VectorIndex represents an interface for vector indexing structures
 type VectorIndex interface {
 	// Basic operations
@@ -39,6 +101,45 @@ type VectorIndex interface {
 	Save() error
 }
 
+// VectorScanner is implemented by indexes that can enumerate the live
+// (non-deleted) vectors they hold. The callback returns false to stop early.
+type VectorScanner interface {
+	Scan(fn func(vector *Vector) bool)
+}
+
+// VectorGetter is implemented by indexes that can look up a single live vector
+// by ID without scanning. Soft-deleted vectors must be reported as missing.
+type VectorGetter interface {
+	Get(id string) (*Vector, bool)
+}
+
+// ExactSearcher is implemented by indexes that can report whether their
+// searches compare the query against every candidate. Indexes that do not
+// implement it are treated as approximate.
+type ExactSearcher interface {
+	Exact() bool
+}
+
+// CandidateSearcher is implemented by indexes that can restrict a search to
+// the vectors with the given IDs, such as those selected by the collection's
+// inverted metadata index. The filter must still be applied to them.
+type CandidateSearcher interface {
+	SearchCandidates(query []float32, k int, ids []string, filter *MetadataFilter, params *SearchParams) ([]SearchResult, error)
+}
+
+// MetadataUpdater is implemented by indexes that can replace the metadata of
+// a stored vector in place, leaving its stored values and norms untouched.
+// Updating a vector the index does not hold must return ErrVectorNotFound.
+type MetadataUpdater interface {
+	UpdateMetadata(id string, metadata map[string]interface{}, version uint64, timestamp int64) error
+}
+
+// Warmer is implemented by indexes that can preload their data into memory
+// (e.g. after Load) so the first searches are not slowed by a cold start
+type Warmer interface {
+	Warmup(dummySearches int) error
+}
+
 // DistanceMetric defines different ways to measure vector similarity
 type DistanceMetric int
 
@@ -61,16 +162,86 @@ func (d DistanceMetric) String() string {
 	case Manhattan:
 		return "Manhattan"
 	default:
+		customMetricsMu.RLock()
+		defer customMetricsMu.RUnlock()
+		if name, ok := customMetrics[d]; ok {
+			return name
+		}
 		return "Unknown"
 	}
 }
 
+// Valid reports whether d is a built-in metric or one registered with
+// RegisterMetricName
+func (d DistanceMetric) Valid() bool {
+	if d >= Cosine && d <= Manhattan {
+		return true
+	}
+	customMetricsMu.RLock()
+	defer customMetricsMu.RUnlock()
+	_, ok := customMetrics[d]
+	return ok
+}
+
+var (
+	customMetricsMu sync.RWMutex
+	customMetrics   = make(map[DistanceMetric]string)
+)
+
+// RegisterMetricName records the name of a custom metric so that String and
+// Valid recognize it. The vector package's metric registry calls this when a
+// metric is registered.
+func RegisterMetricName(d DistanceMetric, name string) {
+	customMetricsMu.Lock()
+	defer customMetricsMu.Unlock()
+	customMetrics[d] = name
+}
+
+// ZeroVectorCosineSimilarity is the cosine similarity between a zero vector
+// and any vector. A zero vector has no direction, so it is treated as
+// orthogonal to everything: cosine distance (1 - similarity) 1 and score 0.5,
+// like an unrelated vector rather than an opposite one. Every cosine
+// implementation returns this value; collections that would rather refuse
+// zero vectors set RejectZeroVectors.
+const ZeroVectorCosineSimilarity float32 = 0
+
+// DistanceToScore converts a raw distance/similarity value produced by the given
+// metric into a normalized score in [0,1], where 1 is the best possible match.
+//
+//   - Cosine: similarity in [-1,1] is mapped linearly to [0,1]
+//   - DotProduct: unbounded, so the raw value is clamped to [0,1]
+//   - Euclidean: exponential decay, exp(-d)
+//   - Manhattan: slower exponential decay, exp(-d/2)
+//
+// Unknown metrics map to a neutral 0.5.
+func DistanceToScore(distance float32, metric DistanceMetric) float32 {
+	switch metric {
+	case Cosine:
+		return (distance + 1) / 2
+	case DotProduct:
+		if distance <= 0 {
+			return 0
+		}
+		if distance >= 1 {
+			return 1
+		}
+		return distance
+	case Euclidean:
+		return float32(math.Exp(-float64(distance)))
+	case Manhattan:
+		return float32(math.Exp(-float64(distance) * 0.5))
+	default:
+		return 0.5
+	}
+}
+
 // SearchResult represents a single search result
 type SearchResult struct {
 	ID       string    // Vector ID
 	Distance float32   // Distance/similarity score
 	Vector   *Vector   // Optional vector data (may be nil if not requested)
 	Score    float32   // Normalized score (1.0 = best match, 0.0 = worst)
+	Explain  map[string]interface{} `json:",omitempty"` // Debug trace (only when SearchParams.Explain is set)
 }
 
 // SearchParams controls how vector search is performed
@@ -78,6 +249,9 @@ type SearchParams struct {
 	// HNSW specific parameters
 	HnswEf          int     // Size of the dynamic candidate list
 	
+	// IVF specific parameters
+	IvfNprobe       int     // Number of inverted-file cells to probe
+	
 	// General search configuration
 	Exact           bool    // Whether to use exact search (bypassing indexes)
 	IndexedOnly     bool    // Search only in indexed segments
@@ -87,7 +261,20 @@ type SearchParams struct {
 	SearchStrategy  SearchStrategy
 	
 	// Result filtering
-	ScoreThreshold  float32 // Minimum score threshold for results
+	ScoreThreshold  float32 // Minimum score of results (0 = the collection's MinScore, negative = none)
+	
+	// Debugging
+	Explain         bool    // Attach a debug trace to each result
+	
+	// Metric overrides the index's default distance metric for this search
+	Metric          *DistanceMetric
+	
+	// Timeout bounds how long an index may scan. When it expires the index
+	// returns the best results found so far and sets Partial on the params it
+	// was given. VectorCollection hands indexes a copy and reports the flag
+	// from SearchPartial instead, so callers' params are never modified.
+	Timeout         time.Duration
+	Partial         bool
 }
 
 // SearchStrategy determines algorithm behavior during search
@@ -104,9 +291,9 @@ const (
 // NewSearchParams creates default search parameters
 func NewSearchParams() *SearchParams {
 	return &SearchParams{
-		HnswEf:         100,   // Default HNSW ef
-		Exact:          false, // Use index by default
-		IndexedOnly:    false, // Include all segments
+		HnswEf:         DefaultHnswEf, // Default HNSW ef
+		Exact:          false,         // Use index by default
+		IndexedOnly:    false,         // Include all segments
 		SearchStrategy: Default,
 	}
 }
@@ -114,7 +301,7 @@ func NewSearchParams() *SearchParams {
 // NewFastSearchParams creates parameters optimized for speed
 func NewFastSearchParams() *SearchParams {
 	return &SearchParams{
-		HnswEf:         40,        // Lower ef for faster search
+		HnswEf:         FastHnswEf, // Lower ef for faster search
 		SearchStrategy: FastSearch,
 	}
 }
@@ -122,7 +309,7 @@ func NewFastSearchParams() *SearchParams {
 // NewPreciseSearchParams creates parameters optimized for accuracy
 func NewPreciseSearchParams() *SearchParams {
 	return &SearchParams{
-		HnswEf:         300,          // Higher ef for more accurate search
+		HnswEf:         PreciseHnswEf, // Higher ef for more accurate search
 		SearchStrategy: PreciseSearch,
 	}
 }
@@ -136,35 +323,205 @@ func NewVectorCollection(name string, dimension int, distanceMetric DistanceMetr
 		DistanceFunc:  distanceMetric,
 		Indexes:       make(map[string]VectorIndex),
 		MetadataSchema: NewMetadataSchema(),
+		versions:      make(map[string]uint64),
+		retired:       make(map[string]uint64),
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
 }
 
+// Validate checks that the collection has a positive dimension and a known
+// distance metric
+func (c *VectorCollection) Validate() error {
+	if c.Dimension <= 0 {
+		return fmt.Errorf("dimension must be positive, got %d", c.Dimension)
+	}
+	if !c.DistanceFunc.Valid() {
+		return fmt.Errorf("unknown distance metric %d", int(c.DistanceFunc))
+	}
+	return nil
+}
+
 // AddIndex adds a new index to the collection
 func (c *VectorCollection) AddIndex(name string, index VectorIndex) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
-	if index.Dimension() != c.Dimension {
-		return fmt.Errorf("index dimension %d does not match collection dimension %d", 
-			index.Dimension(), c.Dimension)
+	if index.Dimension() != c.indexDimension() {
+		return fmt.Errorf("index dimension %d does not match collection dimension %d: %w", 
+			index.Dimension(), c.indexDimension(), ErrDimensionMismatch)
 	}
 	
 	c.Indexes[name] = index
+	c.cache.clear()
 	c.UpdatedAt = time.Now().UnixNano()
 	return nil
 }
 
-// Insert adds a vector to the collection
+// Insert adds a vector to the collection, replacing any existing vector with
+// the same ID and bumping its version
 func (c *VectorCollection) Insert(vector *Vector) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	return c.insertAndNotify(vector)
+}
+
+// InsertSparse densifies a sparse vector and inserts it like Insert. Its
+// Dimension must match the collection's.
+func (c *VectorCollection) InsertSparse(sv *SparseVector) error {
+	vector, err := c.Densify(sv)
+	if err != nil {
+		return err
+	}
+	return c.Insert(vector)
+}
+
+// Densify expands a sparse vector into a dense one. Its Dimension must match
+// the collection's, which is checked before anything is allocated.
+func (c *VectorCollection) Densify(sv *SparseVector) (*Vector, error) {
+	if sv.Dimension != c.Dimension {
+		return nil, fmt.Errorf("sparse vector dimension %d does not match collection dimension %d: %w",
+			sv.Dimension, c.Dimension, ErrDimensionMismatch)
+	}
+	return sv.ToDense()
+}
+
+// InsertNew adds a vector whose ID must not already be in use, returning an
+// error wrapping ErrDuplicateID otherwise
+func (c *VectorCollection) InsertNew(vector *Vector) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if _, exists := c.versions[vector.ID]; exists {
+		return fmt.Errorf("vector %s: %w", vector.ID, ErrDuplicateID)
+	}
+	return c.insertAndNotify(vector)
+}
+
+// InsertIfVersion inserts a vector only if the currently stored version matches
+// expectedVersion (0 meaning the vector must not exist yet). Returns an error
+// wrapping ErrVersionConflict otherwise.
+func (c *VectorCollection) InsertIfVersion(vector *Vector, expectedVersion uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if current := c.versions[vector.ID]; current != expectedVersion {
+		return fmt.Errorf("vector %s is at version %d, expected %d: %w",
+			vector.ID, current, expectedVersion, ErrVersionConflict)
+	}
+	return c.insertAndNotify(vector)
+}
+
+// UpdateMetadata replaces the metadata of an existing vector. If expectedVersion
+// is non-zero the update only applies when it matches the stored version.
+func (c *VectorCollection) UpdateMetadata(id string, metadata map[string]interface{}, expectedVersion uint64) (*Vector, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	current, exists := c.versions[id]
+	if !exists {
+		return nil, fmt.Errorf("vector %s: %w", id, ErrVectorNotFound)
+	}
+	if expectedVersion != 0 && current != expectedVersion {
+		return nil, fmt.Errorf("vector %s is at version %d, expected %d: %w",
+			id, current, expectedVersion, ErrVersionConflict)
+	}
+	
+	stored := c.getLocked(id)
+	if stored == nil {
+		return nil, fmt.Errorf("vector %s: %w", id, ErrVectorNotFound)
+	}
+	updated := stored.Copy()
+	
+	updated.Metadata = metadata
+	updated.Timestamp = time.Now().UnixNano()
+	if !c.updatesMetadataInPlace() {
+		// Re-inserting is only lossless for indexes that store raw values
+		if err := c.storeLocked(updated, true); err != nil {
+			return nil, err
+		}
+		c.notify(VectorMetadataUpdated, []string{id}, []uint64{updated.Version})
+		return updated, nil
+	}
+	
+	if err := c.checkMetadataKeys(metadata); err != nil {
+		return nil, err
+	}
+	if c.MetadataSchema != nil && len(c.MetadataSchema.Fields) > 0 {
+		if err := c.MetadataSchema.ValidateMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+	
+	updated.Version = current + 1
+	c.cache.clear()
+	for name, index := range c.Indexes {
+		err := index.(MetadataUpdater).UpdateMetadata(id, metadata, updated.Version, updated.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update metadata in index %s: %w", name, err)
+		}
+	}
+	
+	c.versions[id] = updated.Version
+	c.metadata.add(updated)
+	c.UpdatedAt = time.Now().UnixNano()
+	c.notify(VectorMetadataUpdated, []string{id}, []uint64{updated.Version})
+	return updated, nil
+}
+
+// updatesMetadataInPlace reports whether every index can replace a vector's
+// metadata without re-inserting its (possibly normalized or compressed)
+// stored values. Callers must hold the lock.
+func (c *VectorCollection) updatesMetadataInPlace() bool {
+	for _, index := range c.Indexes {
+		if _, ok := index.(MetadataUpdater); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// insertAndNotify inserts a vector and reports it to the observers. Callers
+// must hold the write lock.
+func (c *VectorCollection) insertAndNotify(vector *Vector) error {
+	if err := c.insertLocked(vector); err != nil {
+		return err
+	}
+	c.notify(VectorInserted, []string{vector.ID}, []uint64{vector.Version})
+	return nil
+}
+
+// insertLocked validates and stores a vector, stamping its new version.
+// Callers must hold the write lock.
+func (c *VectorCollection) insertLocked(vector *Vector) error {
+	return c.storeLocked(vector, false)
+}
+
+// storeLocked implements insertLocked. A projected vector is one read back
+// from the indexes (such as a stored vector whose metadata is being updated):
+// its values must have the reduced dimension and are stored as they are.
+// Callers must hold the write lock.
+func (c *VectorCollection) storeLocked(vector *Vector, projected bool) error {
+	if err := c.validateID(vector); err != nil {
+		return err
+	}
+	
 	// Validate vector dimension
-	if len(vector.Values) != c.Dimension {
-		return fmt.Errorf("vector dimension %d does not match collection dimension %d",
-			len(vector.Values), c.Dimension)
+	dimension := c.Dimension
+	if projected {
+		dimension = c.indexDimension()
+	}
+	if len(vector.Values) != dimension {
+		return fmt.Errorf("vector dimension %d does not match collection dimension %d: %w",
+			len(vector.Values), dimension, ErrDimensionMismatch)
+	}
+	
+	if c.RejectZeroVectors && vector.IsZero() {
+		return fmt.Errorf("vector %s: %w", vector.ID, ErrZeroVector)
+	}
+	if err := c.checkMetadataKeys(vector.Metadata); err != nil {
+		return err
 	}
 	
 	// Validate metadata if schema is defined
@@ -174,28 +531,261 @@ func (c *VectorCollection) Insert(vector *Vector) error {
 		}
 	}
 	
+	if _, exists := c.versions[vector.ID]; !exists {
+		if err := c.checkCapacity(1); err != nil {
+			return err
+		}
+	}
+	
+	vector.Version = c.nextVersion(vector.ID)
+	
+	// Any write invalidates cached search results, even if it fails partway
+	c.cache.clear()
+	
 	// Add to all indexes
+	indexed := vector
+	if !projected {
+		indexed = c.projectVector(vector)
+	}
 	for name, index := range c.Indexes {
-		if err := index.Insert(vector); err != nil {
+		if err := index.Insert(indexed); err != nil {
 			return fmt.Errorf("failed to insert into index %s: %w", name, err)
 		}
 	}
 	
+	c.versions[vector.ID] = vector.Version
+	delete(c.retired, vector.ID)
+	c.metadata.add(vector)
 	c.UpdatedAt = time.Now().UnixNano()
 	return nil
 }
 
-// BatchInsert adds multiple vectors at once
+// nextVersion returns the version the next write of id receives. IDs that
+// were deleted continue from their last version, so an If-Match holding a
+// version of the old vector can never match the re-created one. Callers must
+// hold the lock.
+func (c *VectorCollection) nextVersion(id string) uint64 {
+	if current, exists := c.versions[id]; exists {
+		return current + 1
+	}
+	return c.retired[id] + 1
+}
+
+// retire forgets the live version of a deleted vector, remembering it as the
+// ID's high-water mark. Callers must hold the write lock.
+func (c *VectorCollection) retire(id string) {
+	if version, exists := c.versions[id]; exists {
+		c.retired[id] = version
+		delete(c.versions, id)
+	}
+}
+
+// indexDimension returns the dimension of the vectors held by the indexes,
+// which is reduced when the collection has a projection
+func (c *VectorCollection) indexDimension() int {
+	if c.Projection != nil {
+		return c.Projection.OutputDim()
+	}
+	return c.Dimension
+}
+
+// IndexDimension returns the dimension of stored vectors, which is reduced
+// when the collection has a projection
+func (c *VectorCollection) IndexDimension() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	return c.indexDimension()
+}
+
+// projectVector returns the vector to hand to the indexes: a projected copy
+// if the collection has a projection, otherwise the vector itself
+func (c *VectorCollection) projectVector(vector *Vector) *Vector {
+	if c.Projection == nil {
+		return vector
+	}
+	projected := vector.Copy()
+	projected.Values = c.projectValues(c.Projection, vector.Values)
+	return projected
+}
+
+// projectValues maps values into the reduced space of p. Cosine collections
+// normalize them first: the projection subtracts the sample mean, so it is not
+// scale-invariant, and vectors are projected as the indexes store them.
+func (c *VectorCollection) projectValues(p *PCAProjection, values []float32) []float32 {
+	if c.DistanceFunc == Cosine {
+		unit := &Vector{Values: append([]float32(nil), values...)}
+		unit.Normalize()
+		values = unit.Values
+	}
+	return p.Project(values)
+}
+
+// SampleValues returns copies of the values of up to n live vectors, for
+// fitting a projection
+func (c *VectorCollection) SampleValues(n int) ([][]float32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	sample := make([][]float32, 0, n)
+	err := c.scan(func(vector *Vector) bool {
+		if len(sample) >= n {
+			return false
+		}
+		values := make([]float32, len(vector.Values))
+		copy(values, vector.Values)
+		sample = append(sample, values)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// Projected reports whether the collection has been switched to a reduced
+// space by ApplyProjection
+func (c *VectorCollection) Projected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	return c.Projection != nil
+}
+
+// ApplyProjection switches the collection to the reduced space of p. Each
+// current index is replaced by the one rebuild returns for it, which must have
+// p's output dimension, and the replacements are filled with the projected
+// live vectors. Stored vectors then hold projected values, so a collection can
+// only be projected once; later calls fail with ErrAlreadyProjected.
+func (c *VectorCollection) ApplyProjection(p *PCAProjection, rebuild func(name string, existing VectorIndex) (VectorIndex, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if c.Projection != nil {
+		return fmt.Errorf("collection %s: %w", c.Name, ErrAlreadyProjected)
+	}
+	if p.InputDim() != c.Dimension {
+		return fmt.Errorf("projection input dimension %d does not match collection dimension %d: %w",
+			p.InputDim(), c.Dimension, ErrDimensionMismatch)
+	}
+	if len(c.Indexes) == 0 {
+		return fmt.Errorf("no indexes in collection %s: %w", c.Name, ErrInvalidArgument)
+	}
+	indexes := make(map[string]VectorIndex, len(c.Indexes))
+	for name, existing := range c.Indexes {
+		index, err := rebuild(name, existing)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild index %s: %w", name, err)
+		}
+		if index.Dimension() != p.OutputDim() {
+			return fmt.Errorf("index %s dimension %d does not match projected dimension %d: %w",
+				name, index.Dimension(), p.OutputDim(), ErrDimensionMismatch)
+		}
+		indexes[name] = index
+	}
+	
+	var projected []*Vector
+	err := c.scan(func(vector *Vector) bool {
+		copied := vector.Copy()
+		copied.Values = c.projectValues(p, vector.Values)
+		projected = append(projected, copied)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for name, index := range indexes {
+		if err := index.BatchInsert(projected); err != nil {
+			return fmt.Errorf("failed to fill index %s: %w", name, err)
+		}
+	}
+	
+	c.Projection = p
+	c.Indexes = indexes
+	c.cache.clear()
+	c.UpdatedAt = time.Now().UnixNano()
+	return nil
+}
+
+// checkMetadataKeys rejects metadata with top-level keys outside
+// AllowedMetadataKeys. Any key is accepted when the list is empty.
+func (c *VectorCollection) checkMetadataKeys(metadata map[string]interface{}) error {
+	if len(c.AllowedMetadataKeys) == 0 {
+		return nil
+	}
+	
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	for _, key := range keys {
+		allowed := false
+		for _, candidate := range c.AllowedMetadataKeys {
+			if key == candidate {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("metadata key %s is not allowed in collection %s (allowed: %s): %w",
+				key, c.Name, strings.Join(c.AllowedMetadataKeys, ", "), ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// validateID checks a vector's ID, assigning a generated one to vectors without
+// an ID when AutoGenerateID is enabled
+func (c *VectorCollection) validateID(vector *Vector) error {
+	if vector.ID == "" {
+		if !c.AutoGenerateID {
+			return fmt.Errorf("ID is required: %w", ErrInvalidID)
+		}
+		vector.ID = NewVectorID()
+	}
+	if len(vector.ID) > MaxVectorIDLength {
+		return fmt.Errorf("ID length %d exceeds the maximum of %d: %w",
+			len(vector.ID), MaxVectorIDLength, ErrInvalidID)
+	}
+	return nil
+}
+
+// checkCapacity returns ErrCapacityExceeded if adding n new vectors would
+// exceed MaxVectors. Callers must hold the lock.
+func (c *VectorCollection) checkCapacity(n int) error {
+	if c.MaxVectors <= 0 || len(c.versions)+n <= c.MaxVectors {
+		return nil
+	}
+	return fmt.Errorf("collection %s holds %d of %d vectors, cannot add %d more: %w",
+		c.Name, len(c.versions), c.MaxVectors, n, ErrCapacityExceeded)
+}
+
+// BatchInsert adds multiple vectors at once. The batch is all or nothing: if
+// any index fails, the indexes it reached are rolled back and the error names
+// the failing index.
 func (c *VectorCollection) BatchInsert(vectors []*Vector) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
 	// Validate all vectors first
 	for i, vector := range vectors {
+		if err := c.validateID(vector); err != nil {
+			return fmt.Errorf("vector %d: %w", i, err)
+		}
+		
 		// Validate vector dimension
 		if len(vector.Values) != c.Dimension {
-			return fmt.Errorf("vector %d: dimension %d does not match collection dimension %d",
-				i, len(vector.Values), c.Dimension)
+			return fmt.Errorf("vector %d: dimension %d does not match collection dimension %d: %w",
+				i, len(vector.Values), c.Dimension, ErrDimensionMismatch)
+		}
+		
+		if c.RejectZeroVectors && vector.IsZero() {
+			return fmt.Errorf("vector %d: %w", i, ErrZeroVector)
+		}
+		if err := c.checkMetadataKeys(vector.Metadata); err != nil {
+			return fmt.Errorf("vector %d: %w", i, err)
 		}
 		
 		// Validate metadata if schema is defined
@@ -206,14 +796,105 @@ func (c *VectorCollection) BatchInsert(vectors []*Vector) error {
 		}
 	}
 	
-	// Insert into all indexes
-	for name, index := range c.Indexes {
-		if err := index.BatchInsert(vectors); err != nil {
+	// Only IDs not already stored consume capacity
+	added := make(map[string]bool)
+	for _, vector := range vectors {
+		if _, exists := c.versions[vector.ID]; !exists {
+			added[vector.ID] = true
+		}
+	}
+	if err := c.checkCapacity(len(added)); err != nil {
+		return err
+	}
+	
+	for _, vector := range vectors {
+		vector.Version = c.nextVersion(vector.ID)
+	}
+	
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	// Remember the vectors being overwritten, as each index holds them, so a
+	// failed batch can restore every index from its own data
+	previous := make(map[string]map[string]*Vector, len(names))
+	for _, name := range names {
+		previous[name] = make(map[string]*Vector)
+	}
+	for _, vector := range vectors {
+		if added[vector.ID] {
+			continue
+		}
+		fallback := c.getLocked(vector.ID)
+		for _, name := range names {
+			old := fallback
+			if getter, ok := c.Indexes[name].(VectorGetter); ok {
+				if stored, ok := getter.Get(vector.ID); ok {
+					old = stored
+				}
+			}
+			if old != nil {
+				previous[name][vector.ID] = old.Copy()
+			}
+		}
+	}
+	
+	// Insert into all indexes, undoing the batch in every index it reached
+	// (including the failing one) if any index fails
+	c.cache.clear()
+	indexed := vectors
+	if c.Projection != nil {
+		indexed = make([]*Vector, len(vectors))
+		for i, vector := range vectors {
+			indexed[i] = c.projectVector(vector)
+		}
+	}
+	for i, name := range names {
+		if err := c.Indexes[name].BatchInsert(indexed); err != nil {
+			for _, done := range names[:i+1] {
+				if rbErr := rollbackBatch(c.Indexes[done], vectors, previous[done]); rbErr != nil {
+					return fmt.Errorf("failed to batch insert into index %s (rollback of index %s failed: %v): %w",
+						name, done, rbErr, err)
+				}
+			}
+			for _, vector := range vectors {
+				vector.Version = c.versions[vector.ID]
+			}
 			return fmt.Errorf("failed to batch insert into index %s: %w", name, err)
 		}
 	}
 	
+	ids := make([]string, len(vectors))
+	versions := make([]uint64, len(vectors))
+	for i, vector := range vectors {
+		c.versions[vector.ID] = vector.Version
+		delete(c.retired, vector.ID)
+		c.metadata.add(vector)
+		ids[i], versions[i] = vector.ID, vector.Version
+	}
 	c.UpdatedAt = time.Now().UnixNano()
+	c.notify(VectorInserted, ids, versions)
+	return nil
+}
+
+// rollbackBatch undoes a (possibly partial) batch insert into one index:
+// overwritten vectors are restored from previous, which holds them as that
+// index stored them, and new ones are deleted.
+// Deleting a vector the index never received is not an error.
+func rollbackBatch(index VectorIndex, vectors []*Vector, previous map[string]*Vector) error {
+	for _, vector := range vectors {
+		if old, ok := previous[vector.ID]; ok {
+			if err := index.Insert(old.Copy()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := index.Delete(vector.ID); err != nil && !errors.Is(err, ErrVectorNotFound) {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -223,16 +904,59 @@ func (c *VectorCollection) Delete(id string) error {
 	defer c.mu.Unlock()
 	
 	// Delete from all indexes
+	c.cache.clear()
 	for name, index := range c.Indexes {
 		if err := index.Delete(id); err != nil {
 			return fmt.Errorf("failed to delete from index %s: %w", name, err)
 		}
 	}
 	
+	c.retire(id)
+	c.metadata.remove(id)
 	c.UpdatedAt = time.Now().UnixNano()
+	c.notify(VectorDeleted, []string{id}, nil)
 	return nil
 }
 
+// DeleteBatch removes multiple vectors under a single write lock. IDs that
+// are not in the collection are returned in notFound rather than failing the
+// batch. An error from an index stops the batch; vectors deleted before it
+// stay deleted and are included in the count.
+func (c *VectorCollection) DeleteBatch(ids []string) (deleted int, notFound []string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	return c.deleteBatchLocked(ids)
+}
+
+// deleteBatchLocked implements DeleteBatch. Callers must hold the write lock.
+func (c *VectorCollection) deleteBatchLocked(ids []string) (deleted int, notFound []string, err error) {
+	c.cache.clear()
+	var removed []string
+	defer func() { c.notify(VectorDeleted, removed, nil) }()
+	for _, id := range ids {
+		if _, exists := c.versions[id]; !exists {
+			notFound = append(notFound, id)
+			continue
+		}
+		
+		for name, index := range c.Indexes {
+			if err := index.Delete(id); err != nil {
+				return deleted, notFound, fmt.Errorf("failed to delete %s from index %s: %w", id, name, err)
+			}
+		}
+		c.retire(id)
+		c.metadata.remove(id)
+		removed = append(removed, id)
+		deleted++
+	}
+	
+	if deleted > 0 {
+		c.UpdatedAt = time.Now().UnixNano()
+	}
+	return deleted, notFound, nil
+}
+
 // Search performs a vector similarity search
 func (c *VectorCollection) Search(
 	query []float32, 
@@ -240,34 +964,496 @@ func (c *VectorCollection) Search(
 	filter *MetadataFilter, 
 	params *SearchParams,
 ) ([]SearchResult, error) {
+	results, _, err := c.SearchPartial(query, k, filter, params)
+	return results, err
+}
+
+// SearchPartial performs a vector similarity search like Search, also
+// reporting whether params.Timeout cut it short so the results are only the
+// best found in time. params is not modified: the indexes are handed a copy.
+func (c *VectorCollection) SearchPartial(
+	query []float32, 
+	k int, 
+	filter *MetadataFilter, 
+	params *SearchParams,
+) ([]SearchResult, bool, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	
-	// Validate query dimension
-	if len(query) != c.Dimension {
-		return nil, fmt.Errorf("query dimension %d does not match collection dimension %d",
-			len(query), c.Dimension)
+	query, err := c.projectQueryLocked(query)
+	if err != nil {
+		return nil, false, err
 	}
 	
-	// Use default params if not provided
+	// Use default params if not provided. Indexes report a partial search
+	// through params, so they get a copy private to this search.
 	if params == nil {
 		params = NewSearchParams()
+	} else {
+		copied := *params
+		params = &copied
 	}
+	params.Partial = false
 	
-	// Choose the most appropriate index
-	// For a more sophisticated implementation, we would have a query planner here
 	if len(c.Indexes) == 0 {
-		return nil, fmt.Errorf("no indexes available in collection %s", c.Name)
+		return nil, false, fmt.Errorf("no indexes available in collection %s", c.Name)
+	}
+	
+	// Serve repeated searches from the cache, if enabled
+	var cacheKey string
+	cacheable := false
+	if c.cache != nil {
+		cacheKey, cacheable = searchCacheKey(query, k, filter, params)
+		if cacheable {
+			if results, ok := c.cache.get(cacheKey); ok {
+				return applyScoreFloor(results, c.ScoreFloor(params)), false, nil
+			}
+		}
+	}
+	
+	metric := c.DistanceFunc
+	if params.Metric != nil {
+		metric = *params.Metric
+	}
+	
+	name, index := c.chooseIndexLocked(params)
+	var results []SearchResult
+	if ids, ok := c.candidatesLocked(index, filter); ok {
+		atomic.AddUint64(&c.metadata.lookups, 1)
+		results, err = index.(CandidateSearcher).SearchCandidates(query, k, ids, filter, params)
+	} else {
+		results, err = index.Search(query, k, filter, params)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	
+	// Guarantee every result carries a normalized score, even if the
+	// index only reported raw distances
+	for i := range results {
+		if results[i].Score == 0 {
+			results[i].Score = DistanceToScore(results[i].Distance, metric)
+		}
+		if params.Explain {
+			results[i].Explain = map[string]interface{}{
+				"distance":         results[i].Distance,
+				"score":            results[i].Score,
+				"metric":           metric.String(),
+				"index":            name,
+				"filter_evaluated": filter != nil && len(filter.Conditions) > 0,
+			}
+		}
 	}
 	
-	// For now, just use the first index
-	// In a real implementation, we would choose based on the search strategy
+	// Partial (timed out) results are not worth reusing
+	if cacheable && !params.Partial {
+		c.cache.put(cacheKey, results)
+	}
+	return applyScoreFloor(results, c.ScoreFloor(params)), params.Partial, nil
+}
+
+// projectQueryLocked validates a query's dimension and maps it into the
+// space of the stored vectors. Projected collections also accept queries
+// already in the reduced space, such as centroids of stored vectors. Callers
+// must hold the lock.
+func (c *VectorCollection) projectQueryLocked(query []float32) ([]float32, error) {
+	switch {
+	case len(query) == c.Dimension:
+		if c.Projection != nil {
+			query = c.projectValues(c.Projection, query)
+		}
+	case c.Projection == nil || len(query) != c.Projection.OutputDim():
+		return nil, fmt.Errorf("query dimension %d does not match collection dimension %d: %w",
+			len(query), c.Dimension, ErrDimensionMismatch)
+	}
+	return query, nil
+}
+
+// ScoreFloor returns the minimum score of results for a search with the given
+// params: the search's own ScoreThreshold if set, otherwise the collection's
+// MinScore. A negative ScoreThreshold disables the floor. Zero means none.
+func (c *VectorCollection) ScoreFloor(params *SearchParams) float32 {
+	floor := c.MinScore
+	if params != nil && params.ScoreThreshold != 0 {
+		floor = params.ScoreThreshold
+	}
+	if floor < 0 {
+		return 0
+	}
+	return floor
+}
+
+// applyScoreFloor drops the results scoring below floor, so a search may
+// return fewer than k results rather than irrelevant ones
+func applyScoreFloor(results []SearchResult, floor float32) []SearchResult {
+	if floor <= 0 {
+		return results
+	}
+	kept := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if result.Score >= floor {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// EnableSearchCache turns on an LRU cache of up to size search results, which
+// is cleared on every write to the collection. A size of 0 or less disables
+// the cache. The cache is disabled by default.
+func (c *VectorCollection) EnableSearchCache(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if size <= 0 {
+		c.cache = nil
+		return
+	}
+	c.cache = newSearchCache(size)
+}
+
+// SearchCacheStats returns the number of cache hits and misses since the
+// cache was enabled, or zeros if it is disabled
+func (c *VectorCollection) SearchCacheStats() (hits, misses uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.cache == nil {
+		return 0, 0
+	}
+	return c.cache.stats()
+}
+
+// Warmup preloads every index that supports it, optionally running a few
+// dummy searches against each
+func (c *VectorCollection) Warmup(dummySearches int) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	for name, index := range c.Indexes {
+		if warmer, ok := index.(Warmer); ok {
+			if err := warmer.Warmup(dummySearches); err != nil {
+				return fmt.Errorf("failed to warm up index %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetByID returns a copy of the live vector with the given ID. Deleted
+// (tombstoned) vectors are never returned.
+func (c *VectorCollection) GetByID(id string) (*Vector, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	found := c.getLocked(id)
+	if found == nil {
+		return nil, false
+	}
+	return found.Copy(), true
+}
+
+// hasGetterLocked reports whether an index can look vectors up by ID, making
+// getLocked cheap. Callers must hold the lock.
+func (c *VectorCollection) hasGetterLocked() bool {
 	for _, index := range c.Indexes {
-		return index.Search(query, k, filter, params)
+		if _, ok := index.(VectorGetter); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getLocked finds the live vector with the given ID, preferring an index that
+// supports direct lookups over a scan. Callers must hold the lock.
+func (c *VectorCollection) getLocked(id string) *Vector {
+	if _, exists := c.versions[id]; !exists {
+		return nil
+	}
+	
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	for _, name := range names {
+		if getter, ok := c.Indexes[name].(VectorGetter); ok {
+			if vector, ok := getter.Get(id); ok && !vector.Deleted {
+				return vector
+			}
+			return nil
+		}
+	}
+	
+	var found *Vector
+	c.scan(func(vector *Vector) bool {
+		if vector.ID == id && !vector.Deleted {
+			found = vector
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// DistinctValues tallies the occurrences of each distinct value of a (possibly
+// nested, dot-separated) metadata field across the live vectors. Vectors missing
+// the field, or holding values that cannot be used as map keys, are skipped.
+func (c *VectorCollection) DistinctValues(field string) (map[interface{}]int, error) {
+	if field == "" {
+		return nil, fmt.Errorf("field is required: %w", ErrInvalidArgument)
+	}
+	path := strings.Split(field, ".")
+	
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	counts := make(map[interface{}]int)
+	err := c.scan(func(vector *Vector) bool {
+		value := getNestedValue(vector.Metadata, path)
+		if value == nil || !reflect.TypeOf(value).Comparable() {
+			return true
+		}
+		counts[value]++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return counts, nil
+}
+
+// Count returns the number of live vectors matching the filter. A nil filter
+// counts every vector without scanning. Filters the inverted metadata index
+// can answer (see SetIndexedFields) only check the vectors it selects; other
+// filtered counts scan the collection.
+func (c *VectorCollection) Count(filter *MetadataFilter) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if filter == nil {
+		return len(c.versions), nil
+	}
+	
+	// Candidates satisfy the indexed conditions, not necessarily the rest,
+	// so each is still checked against the whole filter
+	ids, indexed := c.metadata.candidates(filter)
+	if indexed && c.hasGetterLocked() {
+		count := 0
+		for _, id := range ids {
+			if vector := c.getLocked(id); vector != nil && filter.MatchVector(vector) {
+				count++
+			}
+		}
+		return count, nil
+	}
+	var candidates map[string]bool
+	if indexed {
+		candidates = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			candidates[id] = true
+		}
+	}
+	
+	count := 0
+	err := c.scan(func(vector *Vector) bool {
+		if (candidates == nil || candidates[vector.ID]) && filter.MatchVector(vector) {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Aggregate computes a numeric aggregation (min, max, avg, sum or count) of a
+// (possibly nested) metadata field over the live vectors matching the filter.
+// Missing and non-numeric values are skipped.
+func (c *VectorCollection) Aggregate(field string, op string, filter *MetadataFilter) (float64, error) {
+	switch op {
+	case "min", "max", "avg", "sum", "count":
+	default:
+		return 0, fmt.Errorf("unsupported aggregation %s: %w", op, ErrInvalidArgument)
+	}
+	if field == "" {
+		return 0, fmt.Errorf("field is required: %w", ErrInvalidArgument)
+	}
+	path := strings.Split(field, ".")
+	
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.MetadataSchema != nil {
+		if fieldType, declared := c.MetadataSchema.Fields[field]; declared && fieldType != NumberField {
+			return 0, fmt.Errorf("field %s is not numeric: %w", field, ErrInvalidArgument)
+		}
+	}
+	
+	var count, nonNumeric int
+	var sum, min, max float64
+	err := c.scan(func(vector *Vector) bool {
+		if !filter.MatchVector(vector) {
+			return true
+		}
+		value := getNestedValue(vector.Metadata, path)
+		if value == nil {
+			return true
+		}
+		number, ok := toFloat64(value)
+		if !ok {
+			nonNumeric++
+			return true
+		}
+		if count == 0 || number < min {
+			min = number
+		}
+		if count == 0 || number > max {
+			max = number
+		}
+		sum += number
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, err
 	}
 	
-	// This should never happen as we check for empty indexes above
-	return nil, fmt.Errorf("no index selected for search")
+	if count == 0 && nonNumeric > 0 {
+		return 0, fmt.Errorf("field %s is not numeric: %w", field, ErrInvalidArgument)
+	}
+	
+	switch op {
+	case "count":
+		return float64(count), nil
+	case "sum":
+		return sum, nil
+	}
+	
+	if count == 0 {
+		return 0, fmt.Errorf("no numeric values for field %s: %w", field, ErrInvalidArgument)
+	}
+	switch op {
+	case "min":
+		return min, nil
+	case "max":
+		return max, nil
+	default: // avg
+		return sum / float64(count), nil
+	}
+}
+
+// SchemaViolation records an existing vector that does not conform to a schema
+type SchemaViolation struct {
+	VectorID string // ID of the offending vector
+	Err      error  // Validation failure
+}
+
+// MigrateSchema replaces the collection's metadata schema, re-validating all
+// existing vectors against it. In strict mode any violation aborts the
+// migration and the old schema is kept; otherwise the schema is applied and
+// violating vectors are reported but left in place.
+func (c *VectorCollection) MigrateSchema(schema *MetadataSchema, strict bool) ([]SchemaViolation, error) {
+	if schema == nil {
+		schema = NewMetadataSchema()
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	var violations []SchemaViolation
+	err := c.scan(func(vector *Vector) bool {
+		if err := schema.ValidateMetadata(vector.Metadata); err != nil {
+			violations = append(violations, SchemaViolation{VectorID: vector.ID, Err: err})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	if strict && len(violations) > 0 {
+		return violations, fmt.Errorf("schema migration rejected: %d existing vectors violate the new schema: %w",
+			len(violations), ErrSchemaViolation)
+	}
+	
+	c.MetadataSchema = schema.Copy()
+	c.UpdatedAt = time.Now().UnixNano()
+	return violations, nil
+}
+
+// Schema returns a copy of the collection's metadata schema, or nil if it
+// has none. MigrateSchema may replace the schema at any time, so readers
+// outside the collection should use this rather than MetadataSchema.
+func (c *VectorCollection) Schema() *MetadataSchema {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.MetadataSchema == nil {
+		return nil
+	}
+	return c.MetadataSchema.Copy()
+}
+
+// Scan calls fn for each live vector of the collection until it returns
+// false. Vectors are passed as stored, reduced if the collection is projected,
+// and must not be modified.
+func (c *VectorCollection) Scan(fn func(vector *Vector) bool) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.scan(fn)
+}
+
+// ScanQueries maps each query into the space of the stored vectors, as
+// Search does, then calls fn for each live vector until it returns false.
+// Both happen under one read lock, so the projected queries always match the
+// vectors passed to fn. It returns the projected queries.
+func (c *VectorCollection) ScanQueries(queries [][]float32, fn func(vector *Vector) bool) ([][]float32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	projected := make([][]float32, len(queries))
+	for i, query := range queries {
+		var err error
+		if projected[i], err = c.projectQueryLocked(query); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.scan(fn); err != nil {
+		return nil, err
+	}
+	return projected, nil
+}
+
+// scan iterates over the live vectors of the collection using the first
+// (by name) index that supports scanning. Callers must hold the lock.
+func (c *VectorCollection) scan(fn func(vector *Vector) bool) error {
+	if len(c.Indexes) == 0 {
+		return nil // Nothing stored yet
+	}
+	
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	for _, name := range names {
+		if scanner, ok := c.Indexes[name].(VectorScanner); ok {
+			scanner.Scan(fn)
+			return nil
+		}
+	}
+	
+	return fmt.Errorf("no index in collection %s supports scanning", c.Name)
 }
 
 // Query performs a universal query against the collection
@@ -286,26 +1472,88 @@ func (c *VectorCollection) Query(request *QueryRequest) (interface{}, error) {
 		)
 	}
 	
-	return nil, fmt.Errorf("unsupported query type")
+	return nil, fmt.Errorf("unsupported query type: %w", ErrInvalidArgument)
 }
 
-// Size returns the number of vectors in the collection
+// Load replaces the collection's vectors with the copies its indexes persisted
+// (see VectorIndex.Load), then rebuilds the version bookkeeping and metadata
+// index from them. Vectors persisted without a version restart at version 1.
+func (c *VectorCollection) Load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	c.cache.clear()
+	for _, name := range names {
+		if err := c.Indexes[name].Load(); err != nil {
+			return fmt.Errorf("failed to load index %s: %w", name, err)
+		}
+	}
+	
+	versions := make(map[string]uint64)
+	var loaded []*Vector
+	if err := c.scan(func(vector *Vector) bool {
+		loaded = append(loaded, vector)
+		return true
+	}); err != nil {
+		return err
+	}
+	for id := range c.versions {
+		c.metadata.remove(id)
+	}
+	for _, vector := range loaded {
+		version := vector.Version
+		if version == 0 {
+			version = 1
+		}
+		versions[vector.ID] = version
+		delete(c.retired, vector.ID)
+		c.metadata.add(vector)
+	}
+	c.versions = versions
+	c.UpdatedAt = time.Now().UnixNano()
+	return nil
+}
+
+// Size returns the number of live vectors in the collection. The count comes
+// from the collection's own version bookkeeping, which Insert, BatchInsert and
+// Delete keep up to date, so it does not depend on how many indexes exist.
 func (c *VectorCollection) Size() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	
-	// Sum size from all indexes
-	// This is a simplification - in reality vectors might be in multiple indexes
-	if len(c.Indexes) == 0 {
-		return 0
-	}
+	return len(c.versions)
+}
+
+// IDs returns the IDs of the live vectors, sorted so pages taken from
+// consecutive offsets neither overlap nor skip vectors
+func (c *VectorCollection) IDs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	
-	// Just return the size of the first index
-	for _, index := range c.Indexes {
-		return index.Size()
+	ids := make([]string, 0, len(c.versions))
+	for id := range c.versions {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
+	return ids
+}
+
+// IndexSizes returns the number of vectors held by each index
+func (c *VectorCollection) IndexSizes() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	
-	return 0
+	sizes := make(map[string]int, len(c.Indexes))
+	for name, index := range c.Indexes {
+		sizes[name] = index.Size()
+	}
+	return sizes
 }
 
 // QueryRequest represents a universal query request
@@ -325,6 +1573,14 @@ type QueryRequest struct {
 	Offset       int               // Number of results to skip
 	WithVectors  bool              // Include vectors in response
 	WithPayload  interface{}       // Control payload inclusion
+	Explain      bool              // Attach a debug trace to each result
+	Metric       *DistanceMetric   // Override the collection's distance metric
+	Normalize    *bool             // Normalize the query vector first (default: off; ignored for cosine)
+	DedupBy      string            // Collapse results sharing this metadata field's value
+	Boosts       map[string]float64 // Re-rank by score + weight * numeric metadata field
+	Timeout      time.Duration     // Return partial results once this elapses (0 = no limit)
+	Scanned      int               // Set by the query processor: results ranked before applying Offset
+	Partial      bool              // Set by the query processor: the search timed out and results are partial
 	
 	// Grouping parameters
 	GroupBy      string            // Field to group results by
@@ -339,9 +1595,15 @@ type QueryRequest struct {
 type RecommendParams struct {
 	Positive []string  // IDs of positive examples
 	Negative []string  // IDs of negative examples
-	Strategy string    // Recommendation strategy (average, weighted, etc.)
+	Strategy string    // RecommendAverage (default) or RecommendArithmetic
 }
 
+// Recommendation strategies, deciding how examples are combined into a query
+const (
+	RecommendAverage    = "average"    // Positive centroid minus negative centroid
+	RecommendArithmetic = "arithmetic" // Sum of positives minus sum of negatives, for analogies
+)
+
 // ScrollParams controls scrolling through all vectors
 type ScrollParams struct {
 	Offset string    // Pagination cursor
@@ -0,0 +1,1150 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockIndex is a minimal VectorIndex used to exercise VectorCollection
+// without depending on a concrete index implementation
+type mockIndex struct {
+	dimension int
+	vectors   map[string]*Vector
+	results   []SearchResult
+	searches  int // Number of Search calls
+}
+
+func newMockIndex(dimension int) *mockIndex {
+	return &mockIndex{
+		dimension: dimension,
+		vectors:   make(map[string]*Vector),
+	}
+}
+
+func (m *mockIndex) Insert(vector *Vector) error {
+	m.vectors[vector.ID] = vector
+	return nil
+}
+
+func (m *mockIndex) Search(query []float32, k int, filter *MetadataFilter, params *SearchParams) ([]SearchResult, error) {
+	m.searches++
+	results := make([]SearchResult, len(m.results))
+	copy(results, m.results)
+	return results, nil
+}
+
+func (m *mockIndex) Delete(id string) error {
+	delete(m.vectors, id)
+	return nil
+}
+
+func (m *mockIndex) BatchInsert(vectors []*Vector) error {
+	for _, v := range vectors {
+		m.vectors[v.ID] = v
+	}
+	return nil
+}
+
+func (m *mockIndex) Scan(fn func(vector *Vector) bool) {
+	for _, v := range m.vectors {
+		if !fn(v) {
+			return
+		}
+	}
+}
+
+func (m *mockIndex) Size() int      { return len(m.vectors) }
+func (m *mockIndex) Dimension() int { return m.dimension }
+func (m *mockIndex) Load() error    { return nil }
+func (m *mockIndex) Save() error    { return nil }
+
+func approxEqual(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-6
+}
+
+func TestDistanceToScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   DistanceMetric
+		distance float32
+		expected float32
+	}{
+		{"CosineIdentical", Cosine, 1, 1},
+		{"CosineOrthogonal", Cosine, 0, 0.5},
+		{"CosineOpposite", Cosine, -1, 0},
+		{"DotProductNegative", DotProduct, -3, 0},
+		{"DotProductInRange", DotProduct, 0.25, 0.25},
+		{"DotProductClamped", DotProduct, 7, 1},
+		{"EuclideanZero", Euclidean, 0, 1},
+		{"EuclideanDecay", Euclidean, 2, float32(math.Exp(-2))},
+		{"ManhattanZero", Manhattan, 0, 1},
+		{"ManhattanDecay", Manhattan, 2, float32(math.Exp(-1))},
+		{"Unknown", DistanceMetric(42), 3, 0.5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DistanceToScore(tc.distance, tc.metric); !approxEqual(got, tc.expected) {
+				t.Errorf("DistanceToScore(%v, %s) = %v, expected %v",
+					tc.distance, tc.metric, got, tc.expected)
+			}
+		})
+	}
+
+	// Distance metrics must score closer vectors higher
+	if DistanceToScore(0.5, Euclidean) <= DistanceToScore(1.5, Euclidean) {
+		t.Errorf("Expected Euclidean score to decrease with distance")
+	}
+}
+
+func TestSearchPopulatesScore(t *testing.T) {
+	collection := NewVectorCollection("test", 2, Euclidean)
+	index := newMockIndex(2)
+	index.results = []SearchResult{
+		{ID: "v1", Distance: 0},
+		{ID: "v2", Distance: 1},
+		{ID: "v3", Distance: 2, Score: 0.9},
+	}
+	if err := collection.AddIndex("mock", index); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	results, err := collection.Search([]float32{0, 0}, 3, nil, nil)
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+
+	for _, res := range results[:2] {
+		expected := DistanceToScore(res.Distance, Euclidean)
+		if !approxEqual(res.Score, expected) {
+			t.Errorf("Result %s: expected score %v, got %v", res.ID, expected, res.Score)
+		}
+	}
+
+	// Scores reported by the index are left untouched
+	if results[2].Score != 0.9 {
+		t.Errorf("Expected index-provided score 0.9 to be preserved, got %v", results[2].Score)
+	}
+}
+
+// newTestCollection creates a collection backed by a mock index and seeded with vectors
+func newTestCollection(t *testing.T, vectors ...*Vector) *VectorCollection {
+	collection := NewVectorCollection("test", 2, Cosine)
+	if err := collection.AddIndex("mock", newMockIndex(2)); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	for _, v := range vectors {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+	return collection
+}
+
+func TestMigrateSchema(t *testing.T) {
+	t.Run("AddOptionalField", func(t *testing.T) {
+		collection := newTestCollection(t,
+			NewVector("v1", []float32{1, 0}, map[string]interface{}{"category": "A"}),
+			NewVector("v2", []float32{0, 1}, map[string]interface{}{"category": "B"}),
+		)
+
+		schema := NewMetadataSchema()
+		schema.AddField("category", StringField)
+		schema.AddField("rating", NumberField)
+
+		violations, err := collection.MigrateSchema(schema, true)
+		if err != nil {
+			t.Fatalf("Expected migration to succeed, got %v", err)
+		}
+		if len(violations) != 0 {
+			t.Errorf("Expected no violations, got %v", violations)
+		}
+		if collection.MetadataSchema.Fields["rating"] != NumberField {
+			t.Errorf("Expected new field to be part of the schema")
+		}
+
+		// The new field is enforced for subsequent inserts
+		err = collection.Insert(NewVector("v3", []float32{1, 1}, map[string]interface{}{"rating": "high"}))
+		if err == nil {
+			t.Errorf("Expected insert with wrongly typed new field to fail")
+		}
+	})
+
+	t.Run("RemoveField", func(t *testing.T) {
+		collection := newTestCollection(t)
+		collection.MetadataSchema.AddField("price", NumberField)
+
+		schema := collection.MetadataSchema.Copy()
+		schema.RemoveField("price")
+		if _, err := collection.MigrateSchema(schema, true); err != nil {
+			t.Fatalf("Expected migration to succeed, got %v", err)
+		}
+
+		err := collection.Insert(NewVector("v1", []float32{1, 0}, map[string]interface{}{"price": "free"}))
+		if err != nil {
+			t.Errorf("Expected removed field to no longer be validated, got %v", err)
+		}
+	})
+
+	t.Run("StrictRejectsIncompatibleData", func(t *testing.T) {
+		collection := newTestCollection(t,
+			NewVector("v1", []float32{1, 0}, map[string]interface{}{"price": 10.0}),
+			NewVector("v2", []float32{0, 1}, map[string]interface{}{"price": "cheap"}),
+		)
+		collection.MetadataSchema.AddField("price", StringField)
+		original := collection.MetadataSchema
+
+		schema := original.Copy()
+		if err := schema.RetypeField("price", NumberField); err != nil {
+			t.Fatalf("Failed to retype field: %v", err)
+		}
+
+		violations, err := collection.MigrateSchema(schema, true)
+		if err == nil {
+			t.Fatalf("Expected strict migration to fail")
+		}
+		if len(violations) != 1 || violations[0].VectorID != "v2" {
+			t.Errorf("Expected a single violation for v2, got %v", violations)
+		}
+		if collection.MetadataSchema != original {
+			t.Errorf("Expected original schema to be kept after a rejected migration")
+		}
+
+		// Non-strict mode applies the schema and only flags the violation
+		violations, err = collection.MigrateSchema(schema, false)
+		if err != nil {
+			t.Fatalf("Expected non-strict migration to succeed, got %v", err)
+		}
+		if len(violations) != 1 {
+			t.Errorf("Expected the violation to be reported, got %v", violations)
+		}
+		if collection.MetadataSchema.Fields["price"] != NumberField {
+			t.Errorf("Expected price to be retyped to a number field")
+		}
+		if collection.Size() != 2 {
+			t.Errorf("Expected violating vectors to be kept, got size %d", collection.Size())
+		}
+	})
+
+	t.Run("RequiredField", func(t *testing.T) {
+		collection := newTestCollection(t,
+			NewVector("v1", []float32{1, 0}, map[string]interface{}{"category": "A"}),
+			NewVector("v2", []float32{0, 1}, nil),
+		)
+
+		schema := NewMetadataSchema()
+		schema.AddRequiredField("category", StringField)
+		violations, err := collection.MigrateSchema(schema, true)
+		if !errors.Is(err, ErrSchemaViolation) {
+			t.Fatalf("Expected ErrSchemaViolation, got %v", err)
+		}
+		if len(violations) != 1 || violations[0].VectorID != "v2" {
+			t.Errorf("Expected a single violation for v2, got %v", violations)
+		}
+
+		if _, err := collection.MigrateSchema(schema, false); err != nil {
+			t.Fatalf("Expected non-strict migration to succeed, got %v", err)
+		}
+		err = collection.Insert(NewVector("v3", []float32{1, 1}, map[string]interface{}{"rating": 3}))
+		if err == nil || !strings.Contains(err.Error(), "required") {
+			t.Errorf("Expected insert without the required field to fail, got %v", err)
+		}
+	})
+
+	t.Run("RetypeUnknownField", func(t *testing.T) {
+		err := NewMetadataSchema().RetypeField("missing", NumberField)
+		if err == nil || !strings.Contains(err.Error(), "not defined") {
+			t.Errorf("Expected error retyping an undefined field, got %v", err)
+		}
+	})
+}
+
+func TestDistinctValues(t *testing.T) {
+	collection := newTestCollection(t,
+		NewVector("v1", []float32{1, 0}, map[string]interface{}{"category": "books", "info": map[string]interface{}{"lang": "en"}}),
+		NewVector("v2", []float32{0, 1}, map[string]interface{}{"category": "books", "info": map[string]interface{}{"lang": "fr"}}),
+		NewVector("v3", []float32{1, 1}, map[string]interface{}{"category": "music", "info": map[string]interface{}{"lang": "en"}}),
+		NewVector("v4", []float32{1, 2}, map[string]interface{}{"category": "books"}),
+		NewVector("v5", []float32{2, 1}, map[string]interface{}{"tags": []interface{}{"x"}}),
+	)
+
+	counts, err := collection.DistinctValues("category")
+	if err != nil {
+		t.Fatalf("DistinctValues failed: %v", err)
+	}
+	if len(counts) != 2 || counts["books"] != 3 || counts["music"] != 1 {
+		t.Errorf("Unexpected category counts: %v", counts)
+	}
+
+	nested, err := collection.DistinctValues("info.lang")
+	if err != nil {
+		t.Fatalf("DistinctValues on nested field failed: %v", err)
+	}
+	if len(nested) != 2 || nested["en"] != 2 || nested["fr"] != 1 {
+		t.Errorf("Unexpected nested counts: %v", nested)
+	}
+
+	// Unhashable values are skipped rather than panicking
+	tags, err := collection.DistinctValues("tags")
+	if err != nil {
+		t.Fatalf("DistinctValues on array field failed: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("Expected array values to be skipped, got %v", tags)
+	}
+
+	if _, err := collection.DistinctValues(""); err == nil {
+		t.Errorf("Expected error for an empty field name")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	collection := newTestCollection(t,
+		NewVector("v1", []float32{1, 0}, map[string]interface{}{"category": "books", "price": 10.0}),
+		NewVector("v2", []float32{0, 1}, map[string]interface{}{"category": "books", "price": 30}),
+		NewVector("v3", []float32{1, 1}, map[string]interface{}{"category": "music", "price": 5.0}),
+		NewVector("v4", []float32{1, 2}, map[string]interface{}{"category": "music", "price": "n/a"}),
+		NewVector("v5", []float32{2, 1}, map[string]interface{}{"category": "music"}),
+		NewVector("v6", []float32{2, 2}, map[string]interface{}{"stats": map[string]interface{}{"rating": 4.0}}),
+	)
+	books := NewAndFilter(NewEqualsCondition("category", "books"))
+
+	tests := []struct {
+		op       string
+		filter   *MetadataFilter
+		expected float64
+	}{
+		{"min", nil, 5},
+		{"max", nil, 30},
+		{"sum", nil, 45},
+		{"avg", nil, 15},
+		{"count", nil, 3},
+		{"min", books, 10},
+		{"max", books, 30},
+		{"sum", books, 40},
+		{"avg", books, 20},
+		{"count", books, 2},
+	}
+
+	for _, tc := range tests {
+		name := tc.op
+		if tc.filter != nil {
+			name += "Filtered"
+		}
+		t.Run(name, func(t *testing.T) {
+			value, err := collection.Aggregate("price", tc.op, tc.filter)
+			if err != nil {
+				t.Fatalf("Aggregate failed: %v", err)
+			}
+			if value != tc.expected {
+				t.Errorf("Expected %s(price) = %v, got %v", tc.op, tc.expected, value)
+			}
+		})
+	}
+
+	t.Run("NestedField", func(t *testing.T) {
+		value, err := collection.Aggregate("stats.rating", "max", nil)
+		if err != nil || value != 4 {
+			t.Errorf("Expected max(stats.rating) = 4, got %v (err: %v)", value, err)
+		}
+	})
+
+	t.Run("UnknownOp", func(t *testing.T) {
+		if _, err := collection.Aggregate("price", "median", nil); err == nil {
+			t.Errorf("Expected error for an unknown aggregation")
+		}
+	})
+
+	t.Run("NonNumericField", func(t *testing.T) {
+		if _, err := collection.Aggregate("category", "sum", nil); err == nil {
+			t.Errorf("Expected error aggregating a non-numeric field")
+		}
+	})
+}
+
+func TestVersioning(t *testing.T) {
+	collection := newTestCollection(t)
+
+	v := NewVector("v1", []float32{1, 0}, map[string]interface{}{"rev": "a"})
+	if err := collection.InsertIfVersion(v, 0); err != nil {
+		t.Fatalf("Expected create-only insert to succeed, got %v", err)
+	}
+	if v.Version != 1 {
+		t.Errorf("Expected version 1 after first insert, got %d", v.Version)
+	}
+
+	// A successful versioned update bumps the version
+	update := NewVector("v1", []float32{0, 1}, map[string]interface{}{"rev": "b"})
+	if err := collection.InsertIfVersion(update, 1); err != nil {
+		t.Fatalf("Expected versioned update to succeed, got %v", err)
+	}
+	if update.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", update.Version)
+	}
+
+	// A stale writer is rejected
+	stale := NewVector("v1", []float32{1, 1}, map[string]interface{}{"rev": "stale"})
+	err := collection.InsertIfVersion(stale, 1)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale update, got %v", err)
+	}
+	if _, err := collection.UpdateMetadata("v1", map[string]interface{}{"rev": "stale"}, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale metadata update, got %v", err)
+	}
+
+	updated, err := collection.UpdateMetadata("v1", map[string]interface{}{"rev": "c"}, 2)
+	if err != nil {
+		t.Fatalf("Expected metadata update to succeed, got %v", err)
+	}
+	if updated.Version != 3 || updated.Metadata["rev"] != "c" {
+		t.Errorf("Unexpected vector after metadata update: %+v", updated)
+	}
+
+	stored, _ := collection.GetByID("v1")
+	if stored.Version != 3 || stored.Metadata["rev"] != "c" || stored.Values[1] != 1 {
+		t.Errorf("Stored vector does not reflect the updates: %+v", stored)
+	}
+
+	// A re-created ID continues from its last version, so an If-Match taken
+	// before the delete cannot match the new vector
+	if err := collection.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	recreated := NewVector("v1", []float32{1, 0}, nil)
+	if err := collection.InsertIfVersion(recreated, 0); err != nil {
+		t.Fatalf("Expected create-only insert of a deleted ID to succeed, got %v", err)
+	}
+	if recreated.Version != 4 {
+		t.Errorf("Expected the re-created vector at version 4, got %d", recreated.Version)
+	}
+	if _, err := collection.UpdateMetadata("v1", nil, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a version of the deleted vector, got %v", err)
+	}
+	if _, _, err := collection.DeleteBatch([]string{"v1"}); err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if err := collection.BatchInsert([]*Vector{NewVector("v1", []float32{1, 0}, nil)}); err != nil {
+		t.Fatalf("BatchInsert failed: %v", err)
+	}
+	if stored, _ := collection.GetByID("v1"); stored.Version != 5 {
+		t.Errorf("Expected the batch re-created vector at version 5, got %d", stored.Version)
+	}
+}
+
+func TestMaxVectors(t *testing.T) {
+	collection := newTestCollection(t)
+	collection.MaxVectors = 2
+
+	for _, id := range []string{"v1", "v2"} {
+		if err := collection.Insert(NewVector(id, []float32{1, 0}, nil)); err != nil {
+			t.Fatalf("Expected %s to fit within capacity: %v", id, err)
+		}
+	}
+
+	err := collection.Insert(NewVector("v3", []float32{0, 1}, nil))
+	if !errors.Is(err, ErrCapacityExceeded) {
+		t.Fatalf("Expected ErrCapacityExceeded, got %v", err)
+	}
+
+	// Overwriting an existing vector does not consume capacity
+	if err := collection.Insert(NewVector("v1", []float32{0, 1}, nil)); err != nil {
+		t.Errorf("Expected update of existing vector to succeed: %v", err)
+	}
+
+	err = collection.BatchInsert([]*Vector{NewVector("v3", []float32{0, 1}, nil)})
+	if !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Expected batch insert to be rejected, got %v", err)
+	}
+
+	// Deleting frees capacity
+	if err := collection.Delete("v2"); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+	if err := collection.Insert(NewVector("v3", []float32{0, 1}, nil)); err != nil {
+		t.Errorf("Expected insert after delete to succeed: %v", err)
+	}
+}
+
+func TestVectorIDValidation(t *testing.T) {
+	t.Run("RejectsEmptyID", func(t *testing.T) {
+		collection := newTestCollection(t)
+		err := collection.Insert(NewVector("", []float32{1, 0}, nil))
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("RejectsOverlongID", func(t *testing.T) {
+		collection := newTestCollection(t)
+		id := strings.Repeat("x", MaxVectorIDLength+1)
+		err := collection.Insert(NewVector(id, []float32{1, 0}, nil))
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("AutoGeneratesUniqueIDs", func(t *testing.T) {
+		collection := newTestCollection(t)
+		collection.AutoGenerateID = true
+
+		seen := make(map[string]bool)
+		for i := 0; i < 10; i++ {
+			v := NewVector("", []float32{1, 0}, nil)
+			if err := collection.Insert(v); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			if len(v.ID) != 36 || seen[v.ID] {
+				t.Fatalf("Expected a fresh UUID, got %q", v.ID)
+			}
+			seen[v.ID] = true
+		}
+		if size := collection.Size(); size != 10 {
+			t.Errorf("Expected 10 vectors, got %d", size)
+		}
+	})
+
+	t.Run("InsertNewRejectsDuplicates", func(t *testing.T) {
+		collection := newTestCollection(t, NewVector("v1", []float32{1, 0}, nil))
+		err := collection.InsertNew(NewVector("v1", []float32{0, 1}, nil))
+		if !errors.Is(err, ErrDuplicateID) {
+			t.Errorf("Expected ErrDuplicateID, got %v", err)
+		}
+		if err := collection.Insert(NewVector("v1", []float32{0, 1}, nil)); err != nil {
+			t.Errorf("Expected upsert to succeed, got %v", err)
+		}
+	})
+}
+
+func TestCount(t *testing.T) {
+	collection := newTestCollection(t,
+		NewVector("v1", []float32{1, 0}, map[string]interface{}{"category": "books", "price": 10.0}),
+		NewVector("v2", []float32{0, 1}, map[string]interface{}{"category": "books", "price": 30.0}),
+		NewVector("v3", []float32{1, 1}, map[string]interface{}{"category": "music", "price": 5.0}),
+		NewVector("v4", []float32{1, 2}, map[string]interface{}{"category": "music"}),
+	)
+
+	tests := []struct {
+		name     string
+		filter   *MetadataFilter
+		expected int
+	}{
+		{"NoFilter", nil, 4},
+		{"Equals", NewAndFilter(NewEqualsCondition("category", "music")), 2},
+		{"Range", NewAndFilter(NewRangeCondition("price", 5.0, 10.0)), 2},
+		{"NoMatch", NewAndFilter(NewEqualsCondition("category", "films")), 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := collection.Count(tc.filter)
+			if err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			if count != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, count)
+			}
+		})
+	}
+
+	if err := collection.Delete("v3"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if count, _ := collection.Count(NewAndFilter(NewEqualsCondition("category", "music"))); count != 1 {
+		t.Errorf("Expected deleted vectors not to be counted, got %d", count)
+	}
+
+	// With the field indexed, candidates are still checked against the
+	// conditions the index cannot answer
+	if err := collection.SetIndexedFields([]string{"category"}); err != nil {
+		t.Fatalf("SetIndexedFields failed: %v", err)
+	}
+	filter := NewAndFilter(NewEqualsCondition("category", "books"), NewRangeCondition("price", 0.0, 20.0))
+	if count, _ := collection.Count(filter); count != 1 {
+		t.Errorf("Expected 1 cheap book, got %d", count)
+	}
+	if count, _ := collection.Count(NewAndFilter(NewEqualsCondition("category", "music"))); count != 1 {
+		t.Errorf("Expected 1 indexed music vector, got %d", count)
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	collection := newTestCollection(t,
+		NewVector("v1", []float32{1, 0}, nil),
+		NewVector("v2", []float32{0, 1}, nil),
+		NewVector("v3", []float32{1, 1}, nil),
+	)
+
+	deleted, notFound, err := collection.DeleteBatch([]string{"v1", "missing", "v3", "v1"})
+	if err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 vectors deleted, got %d", deleted)
+	}
+	if len(notFound) != 2 || notFound[0] != "missing" || notFound[1] != "v1" {
+		t.Errorf("Expected missing and the repeated v1 to be reported, got %v", notFound)
+	}
+	if size := collection.Size(); size != 1 {
+		t.Errorf("Expected 1 vector left, got %d", size)
+	}
+	if _, ok := collection.GetByID("v2"); !ok {
+		t.Errorf("Expected v2 to survive the batch")
+	}
+}
+
+// failingIndex accepts the first limit vectors of a batch and fails on the
+// rest, leaving a partial batch behind
+type failingIndex struct {
+	*mockIndex
+	limit int
+}
+
+func (f *failingIndex) BatchInsert(vectors []*Vector) error {
+	for i, v := range vectors {
+		if i == f.limit {
+			return errors.New("index full")
+		}
+		f.vectors[v.ID] = v
+	}
+	return nil
+}
+
+func TestBatchInsertRollsBackOnIndexFailure(t *testing.T) {
+	collection := newTestCollection(t, NewVector("v1", []float32{1, 0}, nil))
+	failing := &failingIndex{mockIndex: newMockIndex(2), limit: 1}
+	failing.vectors["v1"] = NewVector("v1", []float32{1, 0}, nil)
+	if err := collection.AddIndex("zfailing", failing); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	err := collection.BatchInsert([]*Vector{
+		NewVector("v1", []float32{0, 1}, nil), // Overwrite
+		NewVector("v2", []float32{1, 1}, nil),
+	})
+	if err == nil {
+		t.Fatalf("Expected batch insert to fail")
+	}
+	if !strings.Contains(err.Error(), "zfailing") {
+		t.Errorf("Expected the error to name the failing index, got %v", err)
+	}
+
+	for name, index := range collection.Indexes {
+		mock, ok := index.(*mockIndex)
+		if f, isFailing := index.(*failingIndex); isFailing {
+			mock, ok = f.mockIndex, true
+		}
+		if !ok {
+			t.Fatalf("Unexpected index type %T", index)
+		}
+		if len(mock.vectors) != 1 {
+			t.Errorf("Index %s holds %d vectors, expected 1", name, len(mock.vectors))
+		}
+		if v := mock.vectors["v1"]; v == nil || v.Values[0] != 1 || v.Values[1] != 0 {
+			t.Errorf("Index %s did not restore v1, got %v", name, v)
+		}
+	}
+	if size := collection.Size(); size != 1 {
+		t.Errorf("Expected size 1 after rollback, got %d", size)
+	}
+	if v, ok := collection.GetByID("v1"); !ok || v.Version != 1 {
+		t.Errorf("Expected v1 to keep version 1, got %v", v)
+	}
+}
+
+func TestRejectZeroVectors(t *testing.T) {
+	collection := newTestCollection(t)
+	zero := NewVector("zero", []float32{0, 0}, nil)
+	if err := collection.Insert(zero); err != nil {
+		t.Fatalf("Expected zero vectors to be accepted by default, got %v", err)
+	}
+
+	collection.RejectZeroVectors = true
+	if err := collection.Insert(NewVector("zero2", []float32{0, 0}, nil)); !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Expected ErrZeroVector from Insert, got %v", err)
+	}
+	err := collection.BatchInsert([]*Vector{
+		NewVector("ok", []float32{1, 0}, nil),
+		NewVector("zero3", []float32{0, 0}, nil),
+	})
+	if !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Expected ErrZeroVector from BatchInsert, got %v", err)
+	}
+	if size := collection.Size(); size != 1 {
+		t.Errorf("Expected only the first zero vector to be stored, got size %d", size)
+	}
+}
+
+func TestAllowedMetadataKeys(t *testing.T) {
+	collection := newTestCollection(t)
+	collection.AllowedMetadataKeys = []string{"category", "rating"}
+
+	if err := collection.Insert(NewVector("v1", []float32{1, 0},
+		map[string]interface{}{"category": "A", "rating": 4})); err != nil {
+		t.Errorf("Expected allowed keys to be accepted, got %v", err)
+	}
+	if err := collection.Insert(NewVector("v2", []float32{0, 1}, nil)); err != nil {
+		t.Errorf("Expected a vector without metadata to be accepted, got %v", err)
+	}
+
+	err := collection.Insert(NewVector("v3", []float32{1, 1},
+		map[string]interface{}{"category": "A", "color": "red"}))
+	if err == nil || !strings.Contains(err.Error(), "color") {
+		t.Errorf("Expected an error naming the disallowed key color, got %v", err)
+	}
+	if err := collection.BatchInsert([]*Vector{
+		NewVector("v4", []float32{1, 0}, map[string]interface{}{"size": 3}),
+	}); err == nil {
+		t.Errorf("Expected BatchInsert to reject a disallowed key")
+	}
+	if _, err := collection.UpdateMetadata("v1", map[string]interface{}{"owner": "x"}, 0); err == nil {
+		t.Errorf("Expected UpdateMetadata to reject a disallowed key")
+	}
+	if size := collection.Size(); size != 2 {
+		t.Errorf("Expected 2 vectors stored, got %d", size)
+	}
+}
+
+func TestBulkUpsertIdempotency(t *testing.T) {
+	collection := newTestCollection(t)
+	collection.SetIdempotencyTTL(time.Minute)
+	now := time.Unix(1000, 0)
+	collection.idempotency.now = func() time.Time { return now }
+
+	batch := func() []UpsertItem {
+		return []UpsertItem{
+			{Vector: NewVector("v1", []float32{1, 0}, nil), IdempotencyKey: "k1"},
+			{Vector: NewVector("v2", []float32{0, 1}, nil), IdempotencyKey: "k2"},
+			{Vector: NewVector("bad", []float32{1}, nil), IdempotencyKey: "k3"},
+		}
+	}
+	statuses := func(results []UpsertResult) string {
+		var parts []string
+		for _, r := range results {
+			parts = append(parts, r.Status)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	if got := statuses(collection.BulkUpsert(batch())); got != "applied,applied,error" {
+		t.Fatalf("First batch: expected applied,applied,error, got %s", got)
+	}
+	first, _ := collection.GetByID("v1")
+
+	// Resending the batch changes nothing; the failed item is retried
+	if got := statuses(collection.BulkUpsert(batch())); got != "duplicate,duplicate,error" {
+		t.Errorf("Second batch: expected duplicate,duplicate,error, got %s", got)
+	}
+	if again, _ := collection.GetByID("v1"); again.Version != first.Version {
+		t.Errorf("Expected v1 to stay at version %d, got %d", first.Version, again.Version)
+	}
+	if size := collection.Size(); size != 2 {
+		t.Errorf("Expected 2 vectors, got %d", size)
+	}
+
+	// Items without a key are always applied, and keys expire after the TTL
+	results := collection.BulkUpsert([]UpsertItem{{Vector: NewVector("v1", []float32{1, 1}, nil)}})
+	if statuses(results) != "applied" {
+		t.Errorf("Expected an item without a key to be applied, got %s", statuses(results))
+	}
+	now = now.Add(2 * time.Minute)
+	if got := statuses(collection.BulkUpsert(batch()[:1])); got != "applied" {
+		t.Errorf("Expected an expired key to be applied again, got %s", got)
+	}
+	if len(collection.idempotency.expires) != 1 {
+		t.Errorf("Expected expired keys to be purged, %d remain", len(collection.idempotency.expires))
+	}
+}
+
+func TestSizeWithMultipleIndexes(t *testing.T) {
+	collection := newTestCollection(t)
+	if err := collection.AddIndex("second", newMockIndex(2)); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	for _, id := range []string{"v1", "v2", "v3"} {
+		if err := collection.Insert(NewVector(id, []float32{1, 0}, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := collection.BatchInsert([]*Vector{
+		NewVector("v3", []float32{0, 1}, nil), // Overwrite, not a new vector
+		NewVector("v4", []float32{0, 1}, nil),
+	}); err != nil {
+		t.Fatalf("Batch insert failed: %v", err)
+	}
+	if err := collection.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if size := collection.Size(); size != 3 {
+			t.Fatalf("Expected size 3, got %d", size)
+		}
+	}
+
+	sizes := collection.IndexSizes()
+	if len(sizes) != 2 || sizes["mock"] != 3 || sizes["second"] != 3 {
+		t.Errorf("Expected both indexes to hold 3 vectors, got %v", sizes)
+	}
+}
+
+func TestValidateCollection(t *testing.T) {
+	tests := []struct {
+		name      string
+		dimension int
+		metric    DistanceMetric
+		valid     bool
+	}{
+		{"Valid", 3, Euclidean, true},
+		{"ZeroDimension", 0, Cosine, false},
+		{"NegativeDimension", -2, Cosine, false},
+		{"InvalidMetric", 3, DistanceMetric(99), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewVectorCollection("test", tc.dimension, tc.metric).Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected collection to be valid, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected a validation error")
+			}
+		})
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	collection := NewVectorCollection("test", 2, Euclidean)
+	index := newMockIndex(2)
+	index.results = []SearchResult{{ID: "v1", Distance: 0.5}}
+	if err := collection.AddIndex("mock", index); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	collection.EnableSearchCache(2)
+
+	query := []float32{1, 0}
+	filter := NewAndFilter(NewEqualsCondition("category", "a"))
+	search := func(q []float32, f *MetadataFilter) []SearchResult {
+		results, err := collection.Search(q, 5, f, nil)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return results
+	}
+
+	// Repeated searches are served from the cache
+	first := search(query, filter)
+	first[0].ID = "modified" // Callers must not be able to corrupt the cache
+	second := search(query, filter)
+	if index.searches != 1 {
+		t.Errorf("Expected 1 index search, got %d", index.searches)
+	}
+	if second[0].ID != "v1" {
+		t.Errorf("Cached result was modified: %+v", second)
+	}
+	if hits, misses := collection.SearchCacheStats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d hits and %d misses", hits, misses)
+	}
+
+	// A different filter is a different cache entry
+	search(query, nil)
+	if index.searches != 2 {
+		t.Errorf("Expected a different filter to miss the cache, got %d index searches", index.searches)
+	}
+
+	// Writes invalidate the cache
+	if err := collection.Insert(NewVector("v2", []float32{0, 1}, nil)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	search(query, filter)
+	if index.searches != 3 {
+		t.Errorf("Expected an insert to invalidate the cache, got %d index searches", index.searches)
+	}
+	if err := collection.Delete("v2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	search(query, filter)
+	if index.searches != 4 {
+		t.Errorf("Expected a delete to invalidate the cache, got %d index searches", index.searches)
+	}
+
+	// The least recently used entry is evicted once the cache is full
+	search(query, nil)
+	search([]float32{0, 1}, nil)
+	search(query, filter)
+	if index.searches != 7 {
+		t.Errorf("Expected the oldest entry to be evicted, got %d index searches", index.searches)
+	}
+
+	// Disabling the cache sends every search to the index
+	collection.EnableSearchCache(0)
+	search(query, filter)
+	search(query, filter)
+	if index.searches != 9 {
+		t.Errorf("Expected a disabled cache to be bypassed, got %d index searches", index.searches)
+	}
+}
+
+func TestObservers(t *testing.T) {
+	collection := newTestCollection(t)
+	events := make(chan VectorEvent, 10)
+	collection.AddObserver(func(VectorEvent) { panic("observer failure") })
+	collection.AddObserver(func(event VectorEvent) { events <- event })
+
+	if err := collection.Insert(NewVector("v1", []float32{1, 0}, nil)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := collection.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := collection.Insert(NewVector("bad", []float32{1}, nil)); err == nil {
+		t.Fatalf("Expected a dimension mismatch")
+	}
+
+	// Deliveries of separate operations may interleave, so compare as a set
+	got := make(map[string]VectorEvent)
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			got[event.Operation] = event
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for events, got %v", got)
+		}
+	}
+	if event := got[VectorInserted]; event.ID != "v1" || event.Version != 1 || event.Collection != "test" || event.Timestamp.IsZero() {
+		t.Errorf("Unexpected insert event %+v", event)
+	}
+	if event := got[VectorDeleted]; event.ID != "v1" {
+		t.Errorf("Unexpected delete event %+v", event)
+	}
+
+	// The failed insert is not reported
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMetadataIndexTracksWrites(t *testing.T) {
+	collection := newTestCollection(t,
+		NewVector("v1", []float32{1, 0}, map[string]interface{}{"tag": "a", "info": map[string]interface{}{"rank": 1}}),
+		NewVector("v2", []float32{0, 1}, map[string]interface{}{"tag": "b", "info": map[string]interface{}{"rank": 2.0}}),
+	)
+	// Vectors stored before the declaration are indexed too
+	if err := collection.SetIndexedFields([]string{"tag", "info.rank"}); err != nil {
+		t.Fatalf("SetIndexedFields failed: %v", err)
+	}
+	collection.BatchInsert([]*Vector{
+		NewVector("v3", []float32{1, 1}, map[string]interface{}{"tag": "a"}),
+		NewVector("v4", []float32{1, 1}, map[string]interface{}{"tag": []interface{}{"a"}}),
+	})
+	if _, err := collection.UpdateMetadata("v1", map[string]interface{}{"tag": "b"}, 0); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if err := collection.Delete("v2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	tests := []struct {
+		filter   *MetadataFilter
+		expected []string
+		usable   bool
+	}{
+		{NewAndFilter(NewEqualsCondition("tag", "a")), []string{"v3"}, true},
+		{NewAndFilter(NewEqualsCondition("tag", "b")), []string{"v1"}, true},
+		{NewAndFilter(NewEqualsCondition("info.rank", 2)), nil, true},
+		{NewOrFilter(NewEqualsCondition("tag", "a"), NewEqualsCondition("tag", "b")), []string{"v1", "v3"}, true},
+		{NewAndFilter(NewEqualsCondition("tag", "a"), NewExistsCondition("info", false)), []string{"v3"}, true},
+		{NewOrFilter(NewEqualsCondition("tag", "a"), NewExistsCondition("info", false)), nil, false},
+		{NewAndFilter(NewPrefixCondition("tag", "a")), nil, false},
+	}
+	for i, test := range tests {
+		ids, usable := collection.metadata.candidates(test.filter)
+		if usable != test.usable || !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("Filter %d: expected %v (usable %v), got %v (usable %v)", i, test.expected, test.usable, ids, usable)
+		}
+	}
+
+	// The mock index cannot search a subset, so searches keep scanning
+	collection.Search([]float32{1, 0}, 2, NewAndFilter(NewEqualsCondition("tag", "a")), nil)
+	if probes := collection.IndexedFilterSearches(); probes != 0 {
+		t.Errorf("Expected no indexed searches without a CandidateSearcher, got %d", probes)
+	}
+}
+
+func TestInsertSparse(t *testing.T) {
+	collection := newTestCollection(t)
+
+	sparse := NewSparseVector("s1", []int{1}, []float32{0.5}, map[string]interface{}{"kind": "sparse"})
+	sparse.Dimension = 2
+	if err := collection.InsertSparse(sparse); err != nil {
+		t.Fatalf("InsertSparse failed: %v", err)
+	}
+	stored, ok := collection.GetByID("s1")
+	if !ok {
+		t.Fatalf("Expected the sparse vector to be stored")
+	}
+	if !reflect.DeepEqual(stored.Values, []float32{0, 0.5}) || stored.Metadata["kind"] != "sparse" {
+		t.Errorf("Expected the dense equivalent with its metadata, got %+v", stored)
+	}
+
+	tests := []struct {
+		name      string
+		sparse    *SparseVector
+		dimension bool // Whether the error wraps ErrDimensionMismatch
+	}{
+		{"WrongDimension", &SparseVector{ID: "s2", Indices: []int{0}, Values: []float32{1}, Dimension: 3}, true},
+		{"IndexOutOfRange", &SparseVector{ID: "s2", Indices: []int{2}, Values: []float32{1}, Dimension: 2}, true},
+		{"LengthMismatch", &SparseVector{ID: "s2", Indices: []int{0, 1}, Values: []float32{1}, Dimension: 2}, false},
+		{"DuplicateIndex", &SparseVector{ID: "s2", Indices: []int{0, 0}, Values: []float32{1, 2}, Dimension: 2}, false},
+	}
+	for _, test := range tests {
+		err := collection.InsertSparse(test.sparse)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if errors.Is(err, ErrDimensionMismatch) != test.dimension {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if collection.Size() != 1 {
+		t.Errorf("Expected rejected sparse vectors not to be stored, got %d vectors", collection.Size())
+	}
+}
+
+func TestSearchScoreFloor(t *testing.T) {
+	collection := NewVectorCollection("test", 2, Euclidean)
+	index := newMockIndex(2)
+	index.results = []SearchResult{
+		{ID: "v1", Score: 0.9},
+		{ID: "v2", Score: 0.5},
+		{ID: "v3", Score: 0.1},
+	}
+	if err := collection.AddIndex("mock", index); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	collection.MinScore = 0.4
+
+	tests := []struct {
+		name      string
+		threshold float32
+		expected  int
+	}{
+		{"CollectionDefault", 0, 2},
+		{"StricterQuery", 0.8, 1},
+		{"LooserQuery", 0.05, 3},
+		{"Disabled", -1, 3},
+	}
+	for _, test := range tests {
+		results, err := collection.Search([]float32{0, 0}, 3, nil, &SearchParams{ScoreThreshold: test.threshold})
+		if err != nil {
+			t.Fatalf("%s: error searching: %v", test.name, err)
+		}
+		if len(results) != test.expected {
+			t.Errorf("%s: expected %d results, got %d", test.name, test.expected, len(results))
+		}
+	}
+}
+
+// exactMatchIndex is a mockIndex whose searches return the stored vectors
+// equal to the query, calling onSearch first if set
+type exactMatchIndex struct {
+	*mockIndex
+	onSearch func()
+}
+
+func (m *exactMatchIndex) Search(query []float32, k int, filter *MetadataFilter, params *SearchParams) ([]SearchResult, error) {
+	if m.onSearch != nil {
+		m.onSearch()
+	}
+	var results []SearchResult
+	for id, v := range m.vectors {
+		if reflect.DeepEqual(v.Values, query) {
+			results = append(results, SearchResult{ID: id, Score: 1})
+		}
+	}
+	return results, nil
+}
+
+func TestDeduplicateKeepFirstSkipsChangedClusters(t *testing.T) {
+	index := &exactMatchIndex{mockIndex: newMockIndex(2)}
+	collection := NewVectorCollection("test", 2, Cosine)
+	if err := collection.AddIndex("mock", index); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	for _, v := range []*Vector{
+		NewVector("a1", []float32{1, 0}, nil),
+		NewVector("a2", []float32{1, 0}, nil),
+		NewVector("b1", []float32{0, 1}, nil),
+		NewVector("b2", []float32{0, 1}, nil),
+	} {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+
+	// Simulate a write to b2 landing before the deletes take the write lock
+	index.onSearch = func() {
+		collection.versions["b2"]++
+		index.onSearch = nil
+	}
+
+	clusters, err := collection.DeduplicateKeepFirst(0.9)
+	if err != nil {
+		t.Fatalf("DeduplicateKeepFirst failed: %v", err)
+	}
+	if want := [][]string{{"a1", "a2"}}; !reflect.DeepEqual(clusters, want) {
+		t.Errorf("Expected only the unchanged cluster %v, got %v", want, clusters)
+	}
+	for id, live := range map[string]bool{"a1": true, "a2": false, "b1": true, "b2": true} {
+		if _, ok := collection.GetByID(id); ok != live {
+			t.Errorf("Expected %s live=%v after dedup, got %v", id, live, ok)
+		}
+	}
+}
+
+// partialIndex is a mockIndex whose searches report that they timed out
+type partialIndex struct {
+	*mockIndex
+}
+
+func (m *partialIndex) Search(query []float32, k int, filter *MetadataFilter, params *SearchParams) ([]SearchResult, error) {
+	params.Partial = true
+	return nil, nil
+}
+
+func TestSearchPartialLeavesParamsUnchanged(t *testing.T) {
+	collection := NewVectorCollection("test", 2, Cosine)
+	if err := collection.AddIndex("mock", &partialIndex{newMockIndex(2)}); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	// One params value shared by concurrent searches, as presets are
+	params := NewSearchParams()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, partial, err := collection.SearchPartial([]float32{1, 0}, 1, nil, params)
+			if err != nil {
+				t.Errorf("Search failed: %v", err)
+			}
+			if !partial {
+				t.Errorf("Expected the search to be reported as partial")
+			}
+		}()
+	}
+	wg.Wait()
+	if params.Partial {
+		t.Errorf("Expected the caller's params to be left unchanged")
+	}
+}
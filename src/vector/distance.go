@@ -2,6 +2,7 @@ package vector
 
 import (
 	"errors"
+	"fmt"
 	"math"
 
 	"course/models"
@@ -10,20 +11,15 @@ import (
 // DistanceFunc is a function type that calculates distance between two vectors
 type DistanceFunc func(a, b []float32) float32
 
-// GetDistanceFunc returns the appropriate distance function for the given metric
+// GetDistanceFunc returns the appropriate distance function for the given metric.
+// Metrics are resolved through DefaultRegistry, so custom metrics registered
+// with RegisterMetric are supported as well as the built-in ones.
 func GetDistanceFunc(metric models.DistanceMetric) (DistanceFunc, error) {
-	switch metric {
-	case models.Cosine:
-		return CosineSimilarity, nil
-	case models.DotProduct:
-		return DotProduct, nil
-	case models.Euclidean:
-		return EuclideanDistance, nil
-	case models.Manhattan:
-		return ManhattanDistance, nil
-	default:
+	def, ok := DefaultRegistry.Get(metric)
+	if !ok {
 		return nil, errors.New("unsupported distance metric")
 	}
+	return def.Func, nil
 }
 
 // CosineSimilarity calculates the cosine similarity between two vectors
@@ -41,7 +37,7 @@ func CosineSimilarity(a, b []float32) float32 {
 	}
 	
 	if normA == 0 || normB == 0 {
-		return 0 // Handle zero vectors
+		return models.ZeroVectorCosineSimilarity
 	}
 	
 	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
@@ -123,6 +119,24 @@ func PrecomputeNorms(vectors [][]float32) []float32 {
 	return norms
 }
 
+// NormalizeBatch normalizes every vector in-place to unit length and returns
+// their original L2 norms, so callers can recover the raw values if needed.
+// Zero vectors are left untouched and report a norm of 0.
+func NormalizeBatch(vectors [][]float32) []float32 {
+	norms := PrecomputeNorms(vectors)
+
+	for i, vec := range vectors {
+		if norms[i] == 0 {
+			continue
+		}
+		for j := range vec {
+			vec[j] /= norms[i]
+		}
+	}
+
+	return norms
+}
+
 // CosineSimilarityWithNorms calculates cosine similarity using precomputed norms
 func CosineSimilarityWithNorms(a, b []float32, normA, normB float32) float32 {
 	if len(a) != len(b) {
@@ -135,7 +149,7 @@ func CosineSimilarityWithNorms(a, b []float32, normA, normB float32) float32 {
 	}
 	
 	if normA == 0 || normB == 0 {
-		return 0 // Handle zero vectors
+		return models.ZeroVectorCosineSimilarity
 	}
 	
 	return dotProduct / (normA * normB)
@@ -171,44 +185,57 @@ func BatchDistance(query []float32, vectors [][]float32, metric models.DistanceM
 	return results, nil
 }
 
+// BatchCosineDistance calculates the cosine distance (1 - similarity) between
+// one query vector and multiple vectors, computing the query's norm only once
+func BatchCosineDistance(query []float32, vectors [][]float32) []float32 {
+	queryNorm := PrecomputeNorms([][]float32{query})[0]
+	
+	results := make([]float32, len(vectors))
+	for i, vec := range vectors {
+		norm := PrecomputeNorms([][]float32{vec})[0]
+		results[i] = 1 - CosineSimilarityWithNorms(query, vec, queryNorm, norm)
+	}
+	
+	return results
+}
+
+// DistanceMatrix calculates the pairwise distances between all of the given vectors,
+// returning an NxN matrix where entry [i][j] is the distance from vector i to vector j
+func DistanceMatrix(vectors [][]float32, metric models.DistanceMetric) ([][]float32, error) {
+	for i := 1; i < len(vectors); i++ {
+		if len(vectors[i]) != len(vectors[0]) {
+			return nil, fmt.Errorf("vector %d: dimension %d does not match dimension %d: %w",
+				i, len(vectors[i]), len(vectors[0]), models.ErrDimensionMismatch)
+		}
+	}
+	
+	matrix := make([][]float32, len(vectors))
+	for i, vec := range vectors {
+		row, err := BatchDistance(vec, vectors, metric)
+		if err != nil {
+			return nil, err
+		}
+		matrix[i] = row
+	}
+	
+	return matrix, nil
+}
+
 // IsHigherBetter returns true if a higher value is better for the given metric
 // Used for scoring and sorting search results
 func IsHigherBetter(metric models.DistanceMetric) bool {
-	switch metric {
-	case models.Cosine, models.DotProduct:
-		return true // Higher is better for similarity metrics
-	case models.Euclidean, models.Manhattan:
-		return false // Lower is better for distance metrics
-	default:
-		return true // Default assumption
+	if def, ok := DefaultRegistry.Get(metric); ok {
+		return def.HigherIsBetter
 	}
+	return true // Default assumption
 }
 
 // NormalizeScore converts a raw distance/similarity value to a normalized score (0-1)
-// where 1 is the best match and 0 is the worst
+// where 1 is the best match and 0 is the worst. See models.DistanceToScore for
+// the mapping of built-in metrics; custom metrics use their registered Score.
 func NormalizeScore(rawValue float32, metric models.DistanceMetric) float32 {
-	switch metric {
-	case models.Cosine:
-		// Convert from [-1,1] to [0,1]
-		return (rawValue + 1) / 2
-	case models.DotProduct:
-		// DotProduct can be unbounded, so this is a simplification
-		// In a real implementation, you'd normalize based on vector magnitudes
-		if rawValue <= 0 {
-			return 0
-		}
-		if rawValue >= 1 {
-			return 1
-		}
-		return rawValue
-	case models.Euclidean:
-		// Euclidean distance is unbounded above, so we need some heuristic
-		// One approach is to use an exponential decay function
-		return float32(math.Exp(-float64(rawValue)))
-	case models.Manhattan:
-		// Similar to Euclidean
-		return float32(math.Exp(-float64(rawValue) * 0.5))
-	default:
-		return 0.5 // Default value if unknown metric
-	}
-}
\ No newline at end of file
+	if def, ok := DefaultRegistry.Get(metric); ok {
+		return def.Score(rawValue)
+	}
+	return models.DistanceToScore(rawValue, metric)
+}
@@ -0,0 +1,258 @@
+package vector
+
+import (
+	"math"
+
+	"course/models"
+)
+
+const (
+	// blockedCacheBytes is the cache budget of one tile. It is a conservative
+	// L2 size so a tile fits on most CPUs.
+	blockedCacheBytes = 256 << 10
+
+	// blockedDims is the number of dimensions processed per pass over a tile.
+	// 4096 float32s (16 KiB) of the query fit in L1 alongside the vector data
+	// being streamed, so typical embeddings are handled in a single pass.
+	blockedDims = 4096
+)
+
+// BatchDistanceBlocked calculates the same distances as BatchDistance, but
+// processes the vectors in tiles sized to fit the L2 cache. Within a tile
+// each pass covers one block of dimensions for every vector, so that block of
+// the query stays cached rather than being evicted by the vectors streaming
+// past. For cosine the query's norm is computed once instead of per vector.
+// Custom metrics fall back to BatchDistance.
+func BatchDistanceBlocked(query []float32, vectors [][]float32, metric models.DistanceMetric) ([]float32, error) {
+	switch metric {
+	case models.Cosine, models.DotProduct, models.Euclidean, models.Manhattan:
+	default:
+		return BatchDistance(query, vectors, metric)
+	}
+	distFunc, err := GetDistanceFunc(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	dim := len(query)
+	results := make([]float32, len(vectors))
+	if dim == 0 {
+		for i, vec := range vectors {
+			results[i] = distFunc(query, vec)
+		}
+		return results, nil
+	}
+
+	block := blockedDims
+	if block > dim {
+		block = dim
+	}
+	tile := blockedCacheBytes / (4 * block)
+
+	var queryNorm float32
+	if metric == models.Cosine {
+		queryNorm = PrecomputeNorms([][]float32{query})[0]
+	}
+
+	sums := make([]float32, tile)
+	norms := make([]float32, tile)
+	for start := 0; start < len(vectors); start += tile {
+		end := start + tile
+		if end > len(vectors) {
+			end = len(vectors)
+		}
+		chunk := vectors[start:end]
+		for i := range chunk {
+			sums[i], norms[i] = 0, 0
+		}
+
+		for lo := 0; lo < dim; lo += block {
+			hi := lo + block
+			if hi > dim {
+				hi = dim
+			}
+			q := query[lo:hi]
+
+			switch metric {
+			case models.Cosine:
+				blockedCosine(q, chunk, dim, lo, hi, sums, norms)
+			case models.DotProduct:
+				blockedDot(q, chunk, dim, lo, hi, sums)
+			case models.Euclidean:
+				blockedSquaredL2(q, chunk, dim, lo, hi, sums)
+			case models.Manhattan:
+				blockedL1(q, chunk, dim, lo, hi, sums)
+			}
+		}
+
+		for i, vec := range chunk {
+			if len(vec) != dim {
+				results[start+i] = distFunc(query, vec)
+				continue
+			}
+			switch metric {
+			case models.Cosine:
+				norm := float32(math.Sqrt(float64(norms[i])))
+				if queryNorm == 0 || norm == 0 {
+					results[start+i] = models.ZeroVectorCosineSimilarity
+				} else {
+					results[start+i] = sums[i] / (queryNorm * norm)
+				}
+			case models.Euclidean:
+				results[start+i] = float32(math.Sqrt(float64(sums[i])))
+			default:
+				results[start+i] = sums[i]
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// BatchDistanceMany calculates the distance from every query to every vector,
+// returning one row of distances per query. The vectors are processed in
+// tiles sized to fit the L2 cache and every query is run against a tile
+// before moving on to the next, so each vector is loaded from memory once for
+// the whole batch rather than once per query.
+func BatchDistanceMany(queries, vectors [][]float32, metric models.DistanceMetric) ([][]float32, error) {
+	distFunc, err := GetDistanceFunc(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]float32, len(queries))
+	for q := range rows {
+		rows[q] = make([]float32, len(vectors))
+	}
+	if len(queries) == 0 {
+		return rows, nil
+	}
+
+	tile := len(vectors)
+	if dim := len(queries[0]); dim > 0 {
+		tile = blockedCacheBytes / (4 * dim)
+		if tile < 1 {
+			tile = 1
+		}
+	}
+	for start := 0; start < len(vectors); start += tile {
+		end := start + tile
+		if end > len(vectors) {
+			end = len(vectors)
+		}
+		for q, query := range queries {
+			row := rows[q]
+			for i := start; i < end; i++ {
+				row[i] = distFunc(query, vectors[i])
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// The blocked kernels below accumulate one block of dimensions [lo, hi) of
+// every vector in a tile. Vectors whose dimension differs from the query's
+// are skipped; the caller computes them with the scalar functions. Each
+// kernel keeps four independent partial sums, so the additions do not wait on
+// one another, and adds the leftover dimensions at the end. Results can differ
+// from the scalar functions in the last bits because of the summation order.
+
+func blockedCosine(q []float32, tile [][]float32, dim, lo, hi int, sums, norms []float32) {
+	n := len(q) &^ 3
+	for i, vec := range tile {
+		if len(vec) != dim {
+			continue
+		}
+		v := vec[lo:hi]
+		v = v[:len(q)]
+		var s0, s1, s2, s3, n0, n1, n2, n3 float32
+		for j := 0; j < n; j += 4 {
+			s0 += q[j] * v[j]
+			s1 += q[j+1] * v[j+1]
+			s2 += q[j+2] * v[j+2]
+			s3 += q[j+3] * v[j+3]
+			n0 += v[j] * v[j]
+			n1 += v[j+1] * v[j+1]
+			n2 += v[j+2] * v[j+2]
+			n3 += v[j+3] * v[j+3]
+		}
+		for j := n; j < len(q); j++ {
+			s0 += q[j] * v[j]
+			n0 += v[j] * v[j]
+		}
+		sums[i] += (s0 + s1) + (s2 + s3)
+		norms[i] += (n0 + n1) + (n2 + n3)
+	}
+}
+
+func blockedDot(q []float32, tile [][]float32, dim, lo, hi int, sums []float32) {
+	n := len(q) &^ 3
+	for i, vec := range tile {
+		if len(vec) != dim {
+			continue
+		}
+		v := vec[lo:hi]
+		v = v[:len(q)]
+		var s0, s1, s2, s3 float32
+		for j := 0; j < n; j += 4 {
+			s0 += q[j] * v[j]
+			s1 += q[j+1] * v[j+1]
+			s2 += q[j+2] * v[j+2]
+			s3 += q[j+3] * v[j+3]
+		}
+		for j := n; j < len(q); j++ {
+			s0 += q[j] * v[j]
+		}
+		sums[i] += (s0 + s1) + (s2 + s3)
+	}
+}
+
+func blockedSquaredL2(q []float32, tile [][]float32, dim, lo, hi int, sums []float32) {
+	n := len(q) &^ 3
+	for i, vec := range tile {
+		if len(vec) != dim {
+			continue
+		}
+		v := vec[lo:hi]
+		v = v[:len(q)]
+		var s0, s1, s2, s3 float32
+		for j := 0; j < n; j += 4 {
+			d0 := q[j] - v[j]
+			d1 := q[j+1] - v[j+1]
+			d2 := q[j+2] - v[j+2]
+			d3 := q[j+3] - v[j+3]
+			s0 += d0 * d0
+			s1 += d1 * d1
+			s2 += d2 * d2
+			s3 += d3 * d3
+		}
+		for j := n; j < len(q); j++ {
+			d := q[j] - v[j]
+			s0 += d * d
+		}
+		sums[i] += (s0 + s1) + (s2 + s3)
+	}
+}
+
+func blockedL1(q []float32, tile [][]float32, dim, lo, hi int, sums []float32) {
+	n := len(q) &^ 3
+	for i, vec := range tile {
+		if len(vec) != dim {
+			continue
+		}
+		v := vec[lo:hi]
+		v = v[:len(q)]
+		var s0, s1, s2, s3 float32
+		for j := 0; j < n; j += 4 {
+			s0 += float32(math.Abs(float64(q[j] - v[j])))
+			s1 += float32(math.Abs(float64(q[j+1] - v[j+1])))
+			s2 += float32(math.Abs(float64(q[j+2] - v[j+2])))
+			s3 += float32(math.Abs(float64(q[j+3] - v[j+3])))
+		}
+		for j := n; j < len(q); j++ {
+			s0 += float32(math.Abs(float64(q[j] - v[j])))
+		}
+		sums[i] += (s0 + s1) + (s2 + s3)
+	}
+}
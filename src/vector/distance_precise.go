@@ -0,0 +1,130 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"course/models"
+)
+
+// Precision selects the type distance computations accumulate in. Summing
+// thousands of float32 products loses low-order bits at every step, which can
+// reorder near-tied results on high-dimensional vectors. Accumulating in
+// float64 keeps the sums exact to well beyond float32 resolution at a modest
+// cost in speed; results are returned as float32 either way.
+type Precision int
+
+const (
+	Float32Accumulation Precision = iota // Accumulate in float32 (default)
+	Float64Accumulation                  // Accumulate in float64
+)
+
+// String returns the name accepted by ParsePrecision
+func (p Precision) String() string {
+	if p == Float64Accumulation {
+		return "float64"
+	}
+	return "float32"
+}
+
+// ParsePrecision resolves a precision by name ("float32" or "float64"). An
+// empty name selects Float32Accumulation.
+func ParsePrecision(name string) (Precision, error) {
+	switch strings.ToLower(name) {
+	case "", "float32":
+		return Float32Accumulation, nil
+	case "float64":
+		return Float64Accumulation, nil
+	default:
+		return 0, fmt.Errorf("unknown precision %s", name)
+	}
+}
+
+// GetDistanceFuncWithPrecision returns the distance function for the metric,
+// accumulating in the given precision. Custom metrics always use their
+// registered function.
+func GetDistanceFuncWithPrecision(metric models.DistanceMetric, precision Precision) (DistanceFunc, error) {
+	if precision == Float64Accumulation {
+		switch metric {
+		case models.Cosine:
+			return CosineSimilarity64, nil
+		case models.DotProduct:
+			return DotProduct64, nil
+		case models.Euclidean:
+			return EuclideanDistance64, nil
+		case models.Manhattan:
+			return ManhattanDistance64, nil
+		}
+	}
+	return GetDistanceFunc(metric)
+}
+
+// CosineSimilarity64 is CosineSimilarity accumulated in float64
+func CosineSimilarity64(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return -1 // Error case, different dimensions
+	}
+
+	var dotProduct, normA, normB float64
+	for i := 0; i < len(a); i++ {
+		x, y := float64(a[i]), float64(b[i])
+		dotProduct += x * y
+		normA += x * x
+		normB += y * y
+	}
+
+	if normA == 0 || normB == 0 {
+		return models.ZeroVectorCosineSimilarity
+	}
+
+	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// CosineSimilarityNormalized64 is CosineSimilarityNormalized accumulated in float64
+func CosineSimilarityNormalized64(a, b []float32) float32 {
+	return DotProduct64(a, b)
+}
+
+// DotProduct64 is DotProduct accumulated in float64
+func DotProduct64(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0 // Error case, different dimensions
+	}
+
+	var dotProduct float64
+	for i := 0; i < len(a); i++ {
+		dotProduct += float64(a[i]) * float64(b[i])
+	}
+
+	return float32(dotProduct)
+}
+
+// EuclideanDistance64 is EuclideanDistance accumulated in float64
+func EuclideanDistance64(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return float32(math.Inf(1)) // Error case, different dimensions
+	}
+
+	var sumSquares float64
+	for i := 0; i < len(a); i++ {
+		diff := float64(a[i]) - float64(b[i])
+		sumSquares += diff * diff
+	}
+
+	return float32(math.Sqrt(sumSquares))
+}
+
+// ManhattanDistance64 is ManhattanDistance accumulated in float64
+func ManhattanDistance64(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return float32(math.Inf(1)) // Error case, different dimensions
+	}
+
+	var sumAbsDiff float64
+	for i := 0; i < len(a); i++ {
+		sumAbsDiff += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+
+	return float32(sumAbsDiff)
+}
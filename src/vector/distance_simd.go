@@ -1,8 +1,11 @@
 package vector
 
 import (
+	"math"
 	"runtime"
 	"unsafe"
+
+	"course/models"
 )
 
 // isAVXSupported checks if the CPU supports AVX instructions
@@ -107,6 +110,48 @@ func batchCosineSimilaritySIMD(query []float32, vectors [][]float32) []float32 {
 	return results
 }
 
+// SIMDCosineDistance calculates the cosine distance (1 - similarity) using
+// SIMD instructions when available
+func SIMDCosineDistance(a, b []float32) float32 {
+	return 1 - CosineSimilaritySIMD(a, b)
+}
+
+// SIMDEuclideanDistance calculates the Euclidean distance using SIMD
+// instructions when available
+func SIMDEuclideanDistance(a, b []float32) float32 {
+	return EuclideanDistanceSIMD(a, b)
+}
+
+// SIMDBatchCosineDistance calculates the cosine distances (1 - similarity)
+// between a query vector and multiple vectors. With SIMD enabled the query is
+// aligned and its norm computed once, and each distance comes from the SIMD
+// dot-product kernel; otherwise it falls back to BatchCosineDistance.
+func SIMDBatchCosineDistance(query []float32, vectors [][]float32) []float32 {
+	if !UseSimdAcceleration || !isAVXSupported || len(query) < 4 {
+		return BatchCosineDistance(query, vectors)
+	}
+	
+	alignedQuery := alignVector(query)
+	queryNorm := float32(math.Sqrt(float64(DotProductSIMD(alignedQuery, alignedQuery))))
+	
+	results := make([]float32, len(vectors))
+	for i, vec := range vectors {
+		if len(vec) != len(query) {
+			results[i] = 1 - CosineSimilarity(query, vec) // Same sentinel as the scalar path
+			continue
+		}
+		alignedVec := alignVector(vec)
+		norm := float32(math.Sqrt(float64(DotProductSIMD(alignedVec, alignedVec))))
+		if queryNorm == 0 || norm == 0 {
+			results[i] = 1 - models.ZeroVectorCosineSimilarity
+			continue
+		}
+		results[i] = 1 - DotProductSIMD(alignedQuery, alignedVec)/(queryNorm*norm)
+	}
+	
+	return results
+}
+
 // GetOptimizedDistanceFunc returns the most optimized distance function for the given parameters
 // It chooses between scalar and SIMD implementations based on hardware capabilities
 func GetOptimizedDistanceFunc(useSimd bool, dimension int) DistanceFunc {
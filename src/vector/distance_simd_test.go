@@ -0,0 +1,50 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomBatch generates n pseudo-random vectors in [-1,1)
+func randomBatch(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return vectors
+}
+
+func TestSIMDBatchCosineDistance(t *testing.T) {
+	vectors := randomBatch(100, 64, 1)
+	vectors = append(vectors, make([]float32, 64)) // Zero vector
+	query := randomBatch(1, 64, 2)[0]
+
+	expected := BatchCosineDistance(query, vectors)
+	for _, simd := range []bool{true, false} {
+		UseSimdAcceleration = simd
+		got := SIMDBatchCosineDistance(query, vectors)
+		if len(got) != len(expected) {
+			t.Fatalf("Expected %d distances, got %d", len(expected), len(got))
+		}
+		for i := range got {
+			if math.Abs(float64(got[i]-expected[i])) > 1e-4 {
+				t.Errorf("SIMD=%v, vector %d: expected %f, got %f", simd, i, expected[i], got[i])
+			}
+		}
+	}
+	UseSimdAcceleration = true
+
+	for i, vec := range vectors[:10] {
+		if d := SIMDCosineDistance(query, vec); math.Abs(float64(d-expected[i])) > 1e-4 {
+			t.Errorf("SIMDCosineDistance for vector %d: expected %f, got %f", i, expected[i], d)
+		}
+		if d, want := SIMDEuclideanDistance(query, vec), EuclideanDistance(query, vec); math.Abs(float64(d-want)) > 1e-4 {
+			t.Errorf("SIMDEuclideanDistance for vector %d: expected %f, got %f", i, want, d)
+		}
+	}
+}
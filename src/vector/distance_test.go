@@ -0,0 +1,195 @@
+package vector
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"course/models"
+)
+
+func TestGetDistanceFunc(t *testing.T) {
+	a := []float32{1, 2, 3}
+	b := []float32{4, 0, -1}
+
+	tests := []struct {
+		metric   models.DistanceMetric
+		expected DistanceFunc
+	}{
+		{models.Cosine, CosineSimilarity},
+		{models.DotProduct, DotProduct},
+		{models.Euclidean, EuclideanDistance},
+		{models.Manhattan, ManhattanDistance},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.metric.String(), func(t *testing.T) {
+			fn, err := GetDistanceFunc(tc.metric)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got, want := fn(a, b), tc.expected(a, b); got != want {
+				t.Errorf("Expected %f, got %f", want, got)
+			}
+		})
+	}
+
+	if _, err := GetDistanceFunc(models.DistanceMetric(-1)); err == nil {
+		t.Errorf("Expected an error for an unknown metric")
+	}
+}
+
+func TestBatchDistanceBlocked(t *testing.T) {
+	// More dimensions than one block and more vectors than one tile
+	vectors := randomBatch(1500, 600, 1)
+	vectors = append(vectors, make([]float32, 600), []float32{1, 2, 3})
+	query := randomBatch(1, 600, 2)[0]
+
+	for _, metric := range []models.DistanceMetric{models.Cosine, models.DotProduct, models.Euclidean, models.Manhattan} {
+		t.Run(metric.String(), func(t *testing.T) {
+			expected, err := BatchDistance(query, vectors, metric)
+			if err != nil {
+				t.Fatalf("BatchDistance failed: %v", err)
+			}
+			got, err := BatchDistanceBlocked(query, vectors, metric)
+			if err != nil {
+				t.Fatalf("BatchDistanceBlocked failed: %v", err)
+			}
+			for i := range expected {
+				if diff := math.Abs(float64(got[i] - expected[i])); diff > 1e-4*math.Max(1, math.Abs(float64(expected[i]))) && !(math.IsInf(float64(got[i]), 1) && math.IsInf(float64(expected[i]), 1)) {
+					t.Fatalf("Vector %d: expected %f, got %f", i, expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBatchDistance(b *testing.B) {
+	vectors := randomBatch(50000, 768, 1)
+	query := randomBatch(1, 768, 2)[0]
+
+	for _, metric := range []models.DistanceMetric{models.Cosine, models.Euclidean} {
+		b.Run(metric.String()+"/Naive", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BatchDistance(query, vectors, metric)
+			}
+		})
+		b.Run(metric.String()+"/Blocked", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BatchDistanceBlocked(query, vectors, metric)
+			}
+		})
+	}
+}
+
+func TestZeroVectorCosinePolicy(t *testing.T) {
+	zero := []float32{0, 0, 0, 0, 0}
+	other := []float32{1, -2, 3, 0.5, 4}
+	similarity := models.ZeroVectorCosineSimilarity
+	distance := 1 - similarity
+
+	for name, got := range map[string]float32{
+		"CosineSimilarity":          CosineSimilarity(zero, other),
+		"CosineSimilarityReversed":  CosineSimilarity(other, zero),
+		"CosineSimilarityBothZero":  CosineSimilarity(zero, zero),
+		"CosineSimilarityWithNorms": CosineSimilarityWithNorms(zero, other, 0, PrecomputeNorms([][]float32{other})[0]),
+		"CosineSimilaritySIMD":      CosineSimilaritySIMD(zero, other),
+		"CosineSimilarity64":        CosineSimilarity64(zero, other),
+	} {
+		if got != similarity {
+			t.Errorf("%s: got similarity %v, expected %v", name, got, similarity)
+		}
+	}
+
+	for name, got := range map[string][]float32{
+		"BatchCosineDistance":     BatchCosineDistance(zero, [][]float32{other, zero}),
+		"SIMDBatchCosineDistance": SIMDBatchCosineDistance(zero, [][]float32{other, zero}),
+	} {
+		for i, d := range got {
+			if d != distance {
+				t.Errorf("%s[%d]: got distance %v, expected %v", name, i, d, distance)
+			}
+		}
+	}
+
+	blocked, err := BatchDistanceBlocked(other, [][]float32{zero}, models.Cosine)
+	if err != nil {
+		t.Fatalf("BatchDistanceBlocked failed: %v", err)
+	}
+	if blocked[0] != similarity {
+		t.Errorf("BatchDistanceBlocked: got similarity %v, expected %v", blocked[0], similarity)
+	}
+
+	if score := NormalizeScore(similarity, models.Cosine); score != models.DistanceToScore(similarity, models.Cosine) || score != 0.5 {
+		t.Errorf("Expected zero vectors to score 0.5 in both packages, got %v", score)
+	}
+}
+
+// exactDot computes the dot product in arbitrary precision as a reference
+func exactDot(a, b []float32) float64 {
+	sum := new(big.Float).SetPrec(256)
+	for i := range a {
+		// The product of two float32 values is exact in float64
+		sum.Add(sum, new(big.Float).SetPrec(256).SetFloat64(float64(a[i])*float64(b[i])))
+	}
+	exact, _ := sum.Float64()
+	return exact
+}
+
+func TestFloat64AccumulationError(t *testing.T) {
+	const dim, trials = 4096, 50
+	rng := rand.New(rand.NewSource(42))
+	random := func() []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64()) * float32(1+rng.Intn(100))
+		}
+		return v
+	}
+
+	var err32, err64 float64
+	for trial := 0; trial < trials; trial++ {
+		a, b := random(), random()
+		exact := exactDot(a, b)
+
+		err32 += math.Abs(float64(DotProduct(a, b)) - exact)
+		got := DotProduct64(a, b)
+		err64 += math.Abs(float64(got) - exact)
+		if got != float32(exact) {
+			t.Errorf("Trial %d: float64 accumulation gave %v, expected the correctly rounded %v", trial, got, float32(exact))
+		}
+	}
+	if err64 >= err32 {
+		t.Errorf("Expected float64 accumulation to be more accurate, got total error %v vs %v for float32", err64, err32)
+	}
+	t.Logf("Mean absolute error over %d-dim dot products: float32 %.4g, float64 %.4g", dim, err32/trials, err64/trials)
+}
+
+func TestGetDistanceFuncWithPrecision(t *testing.T) {
+	a := []float32{1, 2, 3}
+	b := []float32{4, 0, -1}
+
+	for _, metric := range []models.DistanceMetric{models.Cosine, models.DotProduct, models.Euclidean, models.Manhattan} {
+		single, err := GetDistanceFuncWithPrecision(metric, Float32Accumulation)
+		if err != nil {
+			t.Fatalf("%v: %v", metric, err)
+		}
+		double, err := GetDistanceFuncWithPrecision(metric, Float64Accumulation)
+		if err != nil {
+			t.Fatalf("%v: %v", metric, err)
+		}
+		if got, want := double(a, b), single(a, b); math.Abs(float64(got-want)) > 1e-6 {
+			t.Errorf("%v: float64 accumulation gave %v, float32 gave %v", metric, got, want)
+		}
+	}
+
+	for _, name := range []string{"", "float32", "FLOAT64"} {
+		if _, err := ParsePrecision(name); err != nil {
+			t.Errorf("ParsePrecision(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParsePrecision("float16"); err == nil {
+		t.Error("Expected an unknown precision to be rejected")
+	}
+}
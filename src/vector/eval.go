@@ -0,0 +1,135 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+
+	"course/models"
+)
+
+// RecallAtK returns the average, over queries, of the fraction of the exact
+// top-k IDs that also appear in the approximate top k. approx and exact hold
+// one ranked ID list per query. Queries without exact results are skipped.
+func RecallAtK(approx, exact [][]string, k int) float64 {
+	var total float64
+	counted := 0
+	for q := range exact {
+		truth := truncate(exact[q], k)
+		if len(truth) == 0 {
+			continue
+		}
+		counted++
+		if q >= len(approx) {
+			continue
+		}
+
+		found := make(map[string]bool, k)
+		for _, id := range truncate(approx[q], k) {
+			found[id] = true
+		}
+		hits := 0
+		for _, id := range truth {
+			if found[id] {
+				hits++
+			}
+		}
+		total += float64(hits) / float64(len(truth))
+	}
+
+	if counted == 0 {
+		return 0
+	}
+	return total / float64(counted)
+}
+
+// NDCG returns the average normalized discounted cumulative gain at k of the
+// approximate rankings. The exact result at rank r (0-based) has relevance
+// k-r, so getting the nearest neighbors right, and in order, matters most.
+// Queries without exact results are skipped.
+func NDCG(approx, exact [][]string, k int) float64 {
+	var total float64
+	counted := 0
+	for q := range exact {
+		truth := truncate(exact[q], k)
+		if len(truth) == 0 {
+			continue
+		}
+		counted++
+		if q >= len(approx) {
+			continue
+		}
+
+		relevance := make(map[string]float64, len(truth))
+		var ideal float64
+		for r, id := range truth {
+			relevance[id] = float64(k - r)
+			ideal += float64(k-r) / math.Log2(float64(r+2))
+		}
+		var gain float64
+		for r, id := range truncate(approx[q], k) {
+			gain += relevance[id] / math.Log2(float64(r+2))
+		}
+		total += gain / ideal
+	}
+
+	if counted == 0 {
+		return 0
+	}
+	return total / float64(counted)
+}
+
+// truncate returns at most the first k IDs
+func truncate(ids []string, k int) []string {
+	if len(ids) > k {
+		return ids[:k]
+	}
+	return ids
+}
+
+// IndexEvaluation summarizes how closely an index reproduces a baseline
+type IndexEvaluation struct {
+	Queries int     // Number of queries run
+	K       int     // Results compared per query
+	Recall  float64 // Average recall@k
+	NDCG    float64 // Average NDCG@k
+}
+
+// EvaluateIndex runs the queries against the baseline (typically a brute-force
+// linear index, taken as ground truth) and the candidate index, comparing the
+// top-k results. params are passed to the candidate only.
+func EvaluateIndex(baseline, candidate models.VectorIndex, queries [][]float32, k int, params *models.SearchParams) (IndexEvaluation, error) {
+	if k <= 0 {
+		return IndexEvaluation{}, fmt.Errorf("k must be positive, got %d", k)
+	}
+
+	exact := make([][]string, len(queries))
+	approx := make([][]string, len(queries))
+	for q, query := range queries {
+		truth, err := baseline.Search(query, k, nil, &models.SearchParams{})
+		if err != nil {
+			return IndexEvaluation{}, fmt.Errorf("baseline search for query %d: %w", q, err)
+		}
+		got, err := candidate.Search(query, k, nil, params)
+		if err != nil {
+			return IndexEvaluation{}, fmt.Errorf("candidate search for query %d: %w", q, err)
+		}
+		exact[q] = resultIDs(truth)
+		approx[q] = resultIDs(got)
+	}
+
+	return IndexEvaluation{
+		Queries: len(queries),
+		K:       k,
+		Recall:  RecallAtK(approx, exact, k),
+		NDCG:    NDCG(approx, exact, k),
+	}, nil
+}
+
+// resultIDs returns the IDs of ranked search results
+func resultIDs(results []models.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.ID
+	}
+	return ids
+}
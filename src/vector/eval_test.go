@@ -0,0 +1,73 @@
+package vector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRecallAtK(t *testing.T) {
+	exact := [][]string{
+		{"a", "b", "c", "d"},
+		{"e", "f", "g"},
+	}
+
+	tests := []struct {
+		name     string
+		approx   [][]string
+		k        int
+		expected float64
+	}{
+		{"Perfect", [][]string{{"a", "b", "c"}, {"e", "f", "g"}}, 3, 1},
+		{"OrderIgnored", [][]string{{"c", "a", "b"}, {"g", "e", "f"}}, 3, 1},
+		{"Partial", [][]string{{"a", "x", "y"}, {"e", "f", "z"}}, 3, (1.0/3 + 2.0/3) / 2},
+		{"BeyondKIgnored", [][]string{{"x", "y", "a"}, {"e", "f", "g"}}, 2, (0 + 1) / 2.0},
+		{"MissingQuery", [][]string{{"a", "b", "c"}}, 3, 0.5},
+		{"Empty", [][]string{{}, {}}, 3, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RecallAtK(tc.approx, exact, tc.k); math.Abs(got-tc.expected) > 1e-9 {
+				t.Errorf("RecallAtK = %f, expected %f", got, tc.expected)
+			}
+		})
+	}
+
+	if got := RecallAtK(nil, nil, 3); got != 0 {
+		t.Errorf("Expected 0 recall without queries, got %f", got)
+	}
+}
+
+func TestNDCG(t *testing.T) {
+	exact := [][]string{{"a", "b", "c"}}
+
+	// With k=3 the relevances are a=3, b=2, c=1 and the ideal DCG is
+	// 3/log2(2) + 2/log2(3) + 1/log2(4)
+	tests := []struct {
+		name     string
+		approx   []string
+		expected float64
+	}{
+		{"Perfect", []string{"a", "b", "c"}, 1},
+		{"SwappedTop", []string{"b", "a", "x"}, 0.8174935137996165},
+		{"MissingSecond", []string{"a", "c", "x"}, 0.7625024946924551},
+		{"NoneFound", []string{"x", "y", "z"}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NDCG([][]string{tc.approx}, exact, 3); math.Abs(got-tc.expected) > 1e-9 {
+				t.Errorf("NDCG = %f, expected %f", got, tc.expected)
+			}
+		})
+	}
+
+	// Order matters for NDCG but not for recall
+	reversed := [][]string{{"c", "b", "a"}}
+	if RecallAtK(reversed, exact, 3) != 1 {
+		t.Errorf("Expected perfect recall for a reversed ranking")
+	}
+	if NDCG(reversed, exact, 3) >= 1 {
+		t.Errorf("Expected a reversed ranking to lose NDCG")
+	}
+}
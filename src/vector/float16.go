@@ -0,0 +1,110 @@
+package vector
+
+import (
+	"math"
+	"sync"
+)
+
+// MaxFloat16 is the largest finite value representable as a float16
+const MaxFloat16 = 65504
+
+// Float16 conversion keeps an 11-bit significand, so each value is stored
+// with a relative error of at most 2^-11 (about 0.05%). Magnitudes above
+// MaxFloat16 become infinite and those below about 6e-5 lose precision
+// gradually until they flush to zero below 6e-8. Unit vectors, as stored for
+// cosine similarity, are well inside that range; for them the error in a
+// similarity is typically around 1e-4, enough to swap only near-tied results.
+
+// ToFloat16 converts f to the nearest IEEE 754 half-precision value, rounding
+// ties to even, and returns its bits
+func ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff { // Infinity or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+
+	e := exp - 127 + 15
+	switch {
+	case e >= 0x1f: // Too large, so infinite
+		return sign | 0x7c00
+	case e <= 0: // Subnormal, or too small and flushed to zero
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		if midpoint := uint32(1) << (shift - 1); rem > midpoint || (rem == midpoint && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	// A carry out of the mantissa correctly bumps the exponent (or reaches infinity)
+	half := uint32(e)<<10 | mant>>13
+	if rem := mant & 0x1fff; rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// FromFloat16 converts the bits of a half-precision value to float32. The
+// conversion is exact.
+func FromFloat16(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0: // Zero or subnormal
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f: // Infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+var (
+	float16Once  sync.Once
+	float16Table []float32 // float16Table[h] == FromFloat16(h)
+)
+
+// EncodeFloat16 converts values to half precision
+func EncodeFloat16(values []float32) []uint16 {
+	encoded := make([]uint16, len(values))
+	for i, val := range values {
+		encoded[i] = ToFloat16(val)
+	}
+	return encoded
+}
+
+// DecodeFloat16 converts half-precision values into dst, which must be at
+// least as long as src, and returns dst[:len(src)]. Decoding is a table
+// lookup, so distances over float16 storage can decode into a reused buffer
+// right before each computation at little cost.
+func DecodeFloat16(dst []float32, src []uint16) []float32 {
+	float16Once.Do(func() {
+		float16Table = make([]float32, 1<<16)
+		for h := range float16Table {
+			float16Table[h] = FromFloat16(uint16(h))
+		}
+	})
+
+	dst = dst[:len(src)]
+	for i, h := range src {
+		dst[i] = float16Table[h]
+	}
+	return dst
+}
@@ -0,0 +1,48 @@
+package vector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		value float32
+		bits  uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},
+		{1e6, 0x7c00},                           // Overflows to infinity
+		{float32(math.Pow(2, -24)), 0x0001},     // Smallest subnormal
+		{float32(math.Pow(2, -26)), 0x0000},     // Flushed to zero
+		{1 + float32(math.Pow(2, -11)), 0x3c00}, // Tie rounds to even
+		{1 + 3*float32(math.Pow(2, -11)), 0x3c02},
+	}
+	for _, tt := range tests {
+		if got := ToFloat16(tt.value); got != tt.bits {
+			t.Errorf("ToFloat16(%g) = %#04x, expected %#04x", tt.value, got, tt.bits)
+		}
+	}
+
+	// Every finite half-precision value must survive a round trip
+	for h := 0; h < 1<<16; h++ {
+		if h&0x7c00 == 0x7c00 {
+			continue // Infinity and NaN
+		}
+		f := FromFloat16(uint16(h))
+		if back := ToFloat16(f); back != uint16(h) && !(f == 0 && back&0x7fff == 0) {
+			t.Fatalf("Round trip of %#04x gave %#04x (via %g)", h, back, f)
+		}
+	}
+
+	values := []float32{0.1, -0.25, 3.14159, 1000}
+	decoded := DecodeFloat16(make([]float32, len(values)), EncodeFloat16(values))
+	for i, val := range values {
+		if rel := math.Abs(float64(decoded[i]-val) / float64(val)); rel > 1.0/2048 {
+			t.Errorf("%g decoded as %g, relative error %g exceeds 2^-11", val, decoded[i], rel)
+		}
+	}
+}
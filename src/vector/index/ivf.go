@@ -0,0 +1,317 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"course/models"
+	"course/vector"
+)
+
+const (
+	// ivfTrainIterations bounds the k-means iterations used to place centroids
+	ivfTrainIterations = 25
+
+	// defaultIVFNprobe is the number of cells probed when SearchParams does
+	// not specify one
+	defaultIVFNprobe = 4
+)
+
+// IVFIndex is an inverted-file index. Training partitions the space into nlist
+// Voronoi cells around k-means centroids; every vector is stored in the cell of
+// its nearest centroid, and a search only scans the nprobe cells closest to
+// the query. Larger nprobe values trade speed for recall.
+type IVFIndex struct {
+	dimension      int
+	metric         models.DistanceMetric
+	nlist          int
+	keepNormalized bool
+	centroids      [][]float32
+	cells          []map[string]*models.Vector // Vectors assigned to each cell
+	assignments    map[string]int              // Cell of every stored vector
+	rng            *rand.Rand
+	mu             sync.RWMutex
+}
+
+// NewIVFIndex creates an untrained IVF index with nlist cells
+func NewIVFIndex(dimension int, metric models.DistanceMetric, nlist int) (*IVFIndex, error) {
+	if nlist <= 0 {
+		return nil, fmt.Errorf("nlist must be positive, got %d", nlist)
+	}
+	if _, err := vector.GetDistanceFunc(metric); err != nil {
+		return nil, err
+	}
+
+	return &IVFIndex{
+		dimension:      dimension,
+		metric:         metric,
+		nlist:          nlist,
+		keepNormalized: metric == models.Cosine,
+		assignments:    make(map[string]int),
+		rng:            rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+// Train places the cell centroids by running k-means over the given samples.
+// Retraining discards any vectors already stored.
+func (idx *IVFIndex) Train(samples [][]float32) error {
+	if len(samples) == 0 {
+		return errors.New("no training samples")
+	}
+
+	prepared := make([][]float32, len(samples))
+	for i, s := range samples {
+		if len(s) != idx.dimension {
+			return fmt.Errorf("sample %d: dimension %d does not match index dimension %d: %w",
+				i, len(s), idx.dimension, models.ErrDimensionMismatch)
+		}
+		prepared[i] = idx.prepare(s)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.centroids = kmeans(prepared, idx.nlist, ivfTrainIterations, idx.rng)
+	idx.cells = make([]map[string]*models.Vector, len(idx.centroids))
+	for i := range idx.cells {
+		idx.cells[i] = make(map[string]*models.Vector)
+	}
+	idx.assignments = make(map[string]int)
+	return nil
+}
+
+// prepare returns a copy of values normalized for cosine indexes
+func (idx *IVFIndex) prepare(values []float32) []float32 {
+	prepared := append([]float32(nil), values...)
+	if idx.keepNormalized {
+		vector.NormalizeVector(prepared)
+	}
+	return prepared
+}
+
+// Insert adds a vector to the cell of its nearest centroid
+func (idx *IVFIndex) Insert(v *models.Vector) error {
+	if len(v.Values) != idx.dimension {
+		return fmt.Errorf("vector dimension %d does not match index dimension %d: %w",
+			len(v.Values), idx.dimension, models.ErrDimensionMismatch)
+	}
+
+	vectorCopy := v.Copy()
+	vectorCopy.Values = idx.prepare(v.Values)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.centroids == nil {
+		return errors.New("IVF index must be trained before inserting vectors")
+	}
+
+	// Move the vector if it was previously stored in another cell
+	if cell, exists := idx.assignments[v.ID]; exists {
+		delete(idx.cells[cell], v.ID)
+	}
+
+	cell := nearestCentroid(vectorCopy.Values, idx.centroids)
+	idx.cells[cell][v.ID] = vectorCopy
+	idx.assignments[v.ID] = cell
+	return nil
+}
+
+// BatchInsert adds multiple vectors to the index
+func (idx *IVFIndex) BatchInsert(vectors []*models.Vector) error {
+	for _, v := range vectors {
+		if err := idx.Insert(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search scans the nprobe cells nearest to the query. The number of cells is
+// taken from params.IvfNprobe, defaulting to defaultIVFNprobe.
+func (idx *IVFIndex) Search(
+	query []float32,
+	k int,
+	filter *models.MetadataFilter,
+	params *models.SearchParams,
+) ([]models.SearchResult, error) {
+	if len(query) != idx.dimension {
+		return nil, fmt.Errorf("query dimension %d does not match index dimension %d: %w",
+			len(query), idx.dimension, models.ErrDimensionMismatch)
+	}
+	if params != nil && params.Metric != nil && *params.Metric != idx.metric {
+		return nil, errors.New("the IVF index does not support metric overrides")
+	}
+
+	prepared := idx.prepare(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.centroids == nil {
+		return nil, errors.New("IVF index has not been trained")
+	}
+
+	if k <= 0 {
+		k = 10 // Default to 10 results
+	}
+
+	nprobe := defaultIVFNprobe
+	var scoreThreshold float32
+	if params != nil {
+		if params.IvfNprobe > 0 {
+			nprobe = params.IvfNprobe
+		}
+		scoreThreshold = params.ScoreThreshold
+	}
+	if nprobe > len(idx.centroids) {
+		nprobe = len(idx.centroids)
+	}
+
+	// Rank the cells by how close their centroid is to the query
+	cells := make([]int, len(idx.centroids))
+	centroidDistances := make([]float64, len(idx.centroids))
+	for i, centroid := range idx.centroids {
+		cells[i] = i
+		centroidDistances[i] = squaredDistance(prepared, centroid)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if centroidDistances[cells[i]] != centroidDistances[cells[j]] {
+			return centroidDistances[cells[i]] < centroidDistances[cells[j]]
+		}
+		return cells[i] < cells[j]
+	})
+
+	// Gather the candidates of the probed cells, then score them in one
+	// cache-blocked batch
+	var candidates []*models.Vector
+	var values [][]float32
+	for _, cell := range cells[:nprobe] {
+		for _, vec := range idx.cells[cell] {
+			if filter != nil && !filter.MatchVector(vec) {
+				continue
+			}
+			candidates = append(candidates, vec)
+			values = append(values, vec.Values)
+		}
+	}
+	distances, err := vector.BatchDistanceBlocked(prepared, values, idx.metric)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.SearchResult
+	for i, vec := range candidates {
+		score := vector.NormalizeScore(distances[i], idx.metric)
+		if scoreThreshold > 0 && score < scoreThreshold {
+			continue
+		}
+
+		results = append(results, models.SearchResult{
+			ID:       vec.ID,
+			Distance: distances[i],
+			Vector:   vec,
+			Score:    score,
+		})
+	}
+
+	if vector.IsHigherBetter(idx.metric) {
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance > results[j].Distance
+			}
+			return results[i].ID < results[j].ID
+		})
+	} else {
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance < results[j].Distance
+			}
+			return results[i].ID < results[j].ID
+		})
+	}
+
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Delete removes a vector from the index
+func (idx *IVFIndex) Delete(id string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	cell, exists := idx.assignments[id]
+	if !exists {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+	delete(idx.cells[cell], id)
+	delete(idx.assignments, id)
+	return nil
+}
+
+// UpdateMetadata replaces the metadata, version and timestamp of a stored
+// vector without re-encoding its values
+func (idx *IVFIndex) UpdateMetadata(id string, metadata map[string]interface{}, version uint64, timestamp int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	cell, exists := idx.assignments[id]
+	var vec *models.Vector
+	if exists {
+		vec = idx.cells[cell][id]
+	}
+	if vec == nil {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+
+	updated := *vec
+	updated.Metadata = make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		updated.Metadata[k] = v
+	}
+	updated.Version = version
+	updated.Timestamp = timestamp
+	idx.cells[cell][id] = &updated
+	return nil
+}
+
+// Scan calls fn for every vector in the index until fn returns false
+func (idx *IVFIndex) Scan(fn func(vector *models.Vector) bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for _, cell := range idx.cells {
+		for _, vec := range cell {
+			if !fn(vec) {
+				return
+			}
+		}
+	}
+}
+
+// Size returns the number of vectors in the index
+func (idx *IVFIndex) Size() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.assignments)
+}
+
+// Dimension returns the dimensionality of the index
+func (idx *IVFIndex) Dimension() int {
+	return idx.dimension
+}
+
+// Load always fails: the IVF index cannot be persisted
+func (idx *IVFIndex) Load() error {
+	return errors.New("the IVF index does not support persistence")
+}
+
+// Save always fails: the IVF index cannot be persisted
+func (idx *IVFIndex) Save() error {
+	return errors.New("the IVF index does not support persistence")
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"course/models"
+)
+
+// newTrainedIVF builds an IVF index and a matching linear index over data
+func newTrainedIVF(t testing.TB, data [][]float32, metric models.DistanceMetric, nlist int) (*IVFIndex, *LinearIndex) {
+	dim := len(data[0])
+	ivf, err := NewIVFIndex(dim, metric, nlist)
+	if err != nil {
+		t.Fatalf("Failed to create IVF index: %v", err)
+	}
+	if err := ivf.Train(data); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	linear, _ := NewLinearIndex(dim, metric)
+	for i, values := range data {
+		category := "even"
+		if i%2 == 1 {
+			category = "odd"
+		}
+		v := models.NewVector(fmt.Sprintf("v%d", i), values, map[string]interface{}{"category": category})
+		if err := ivf.Insert(v); err != nil {
+			t.Fatalf("IVF insert failed: %v", err)
+		}
+		linear.Insert(v)
+	}
+	return ivf, linear
+}
+
+func TestIVFIndexRecallIncreasesWithNprobe(t *testing.T) {
+	data := randomVectors(3000, 16, 1)
+	ivf, linear := newTrainedIVF(t, data, models.Euclidean, 32)
+	queries := randomVectors(20, 16, 2)
+
+	previous := -1.0
+	for _, nprobe := range []int{1, 4, 32} {
+		recall := recallAtK(t, linear, ivf, queries, 10, &models.SearchParams{IvfNprobe: nprobe})
+		t.Logf("nprobe=%d recall@10=%.2f", nprobe, recall)
+
+		if recall < previous {
+			t.Errorf("Recall dropped from %.2f to %.2f when raising nprobe to %d", previous, recall, nprobe)
+		}
+		previous = recall
+	}
+
+	// Probing every cell is an exhaustive search
+	if previous != 1 {
+		t.Errorf("Expected perfect recall when probing all cells, got %.2f", previous)
+	}
+}
+
+func TestIVFIndexFilterAndThreshold(t *testing.T) {
+	data := randomVectors(500, 8, 3)
+	ivf, _ := newTrainedIVF(t, data, models.Cosine, 8)
+
+	filter := models.NewAndFilter(models.NewEqualsCondition("category", "odd"))
+	params := &models.SearchParams{IvfNprobe: 8, ScoreThreshold: 0.6}
+	results, err := ivf.Search(data[1], 20, filter, params)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) == 0 || results[0].ID != "v1" {
+		t.Fatalf("Expected the query vector itself first, got %+v", results)
+	}
+	for _, res := range results {
+		if res.Vector.Metadata["category"] != "odd" {
+			t.Errorf("Result %s does not match the filter", res.ID)
+		}
+		if res.Score < 0.6 {
+			t.Errorf("Result %s has score %f below the threshold", res.ID, res.Score)
+		}
+	}
+
+	if err := ivf.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if size := ivf.Size(); size != len(data)-1 {
+		t.Errorf("Expected size %d after delete, got %d", len(data)-1, size)
+	}
+}
+
+func BenchmarkIVFSearch(b *testing.B) {
+	data := randomVectors(10000, 64, 1)
+	ivf, linear := newTrainedIVF(b, data, models.Euclidean, 64)
+	query := randomVectors(1, 64, 2)[0]
+
+	b.Run("Linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linear.Search(query, 10, nil, &models.SearchParams{})
+		}
+	})
+	b.Run("IVF", func(b *testing.B) {
+		params := &models.SearchParams{IvfNprobe: 4}
+		for i := 0; i < b.N; i++ {
+			ivf.Search(query, 10, nil, params)
+		}
+	})
+}
+
+func TestIVFIndexTiesAndPersistence(t *testing.T) {
+	ivf, err := NewIVFIndex(2, models.Euclidean, 2)
+	if err != nil {
+		t.Fatalf("Failed to create IVF index: %v", err)
+	}
+	if err := ivf.Train([][]float32{{1, 0}, {0, 1}}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	for _, id := range []string{"c", "a", "b"} {
+		if err := ivf.Insert(models.NewVector(id, []float32{1, 0}, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	results, err := ivf.Search([]float32{1, 0}, 2, nil, &models.SearchParams{IvfNprobe: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Errorf("Expected ties to be broken by ID, got %+v", results)
+	}
+
+	if err := ivf.Save(); err == nil {
+		t.Errorf("Expected Save to report that persistence is unsupported")
+	}
+	if err := ivf.Load(); err == nil {
+		t.Errorf("Expected Load to report that persistence is unsupported")
+	}
+}
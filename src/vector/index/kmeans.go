@@ -0,0 +1,87 @@
+package index
+
+import (
+	"math"
+	"math/rand"
+)
+
+// kmeans clusters points into k centroids using Lloyd's algorithm, seeding the
+// centroids with randomly chosen points. It returns fewer than k centroids if
+// there are fewer than k points. Clusters that end up empty keep their
+// previous centroid.
+func kmeans(points [][]float32, k, iterations int, rng *rand.Rand) [][]float32 {
+	if k > len(points) {
+		k = len(points)
+	}
+	if k == 0 {
+		return nil
+	}
+	dim := len(points[0])
+
+	centroids := make([][]float32, k)
+	for i, p := range rng.Perm(len(points))[:k] {
+		centroids[i] = append([]float32(nil), points[p]...)
+	}
+
+	assignments := make([]int, len(points))
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, p := range points {
+			nearest := nearestCentroid(p, centroids)
+			if nearest != assignments[i] {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+		if iter > 0 && !changed {
+			break
+		}
+
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for c := range sums {
+			sums[c] = make([]float64, dim)
+		}
+		for i, p := range points {
+			c := assignments[i]
+			counts[c]++
+			for j, val := range p {
+				sums[c][j] += float64(val)
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range centroids[c] {
+				centroids[c][j] = float32(sums[c][j] / float64(counts[c]))
+			}
+		}
+	}
+
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid closest to p by squared
+// Euclidean distance
+func nearestCentroid(p []float32, centroids [][]float32) int {
+	nearest := 0
+	best := math.MaxFloat64
+	for c, centroid := range centroids {
+		if d := squaredDistance(p, centroid); d < best {
+			best = d
+			nearest = c
+		}
+	}
+	return nearest
+}
+
+// squaredDistance returns the squared Euclidean distance between two vectors
+func squaredDistance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		diff := float64(a[i] - b[i])
+		sum += diff * diff
+	}
+	return sum
+}
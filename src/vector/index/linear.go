@@ -1,12 +1,23 @@
 package index
 
 import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"course/models"
 	"course/vector"
+	"course/vector/storage"
 )
 
 // LinearIndex is a simple brute-force index that performs linear (exhaustive) search
@@ -14,68 +25,240 @@ import (
 type LinearIndex struct {
 	dimension     int
 	distanceFunc  vector.DistanceFunc
+	precision     vector.Precision   // Type distance computations accumulate in
 	metric        models.DistanceMetric
 	vectors       map[string]*models.Vector
 	keepNormalized bool
+	norms         map[string]float32 // Original L2 norms (cosine and pruning indexes only)
+	pruning       bool               // Skip vectors whose norm bound rules them out
+	discardNorms  bool               // Cosine only: keep unit vectors without their norms
+	normKey       string             // Metadata key holding the discarded norm ("" = not kept)
+	halves        map[string][]uint16 // Float16 storage: values by ID, in place of Vector.Values (nil = float32 storage)
+	computations  uint64             // Full distance computations performed by searches
+	mismatches    uint64             // Stored vectors skipped for having the wrong dimension
+	workers       int                // Goroutines used to compute distances
+	parallelThreshold int            // Below this many vectors, search on one goroutine
+	backend       storage.PersistenceBackend // Where Save and Load persist the index
+	key           string             // Key of the index in the backend
 	mu            sync.RWMutex
 }
 
+// LinearIndexConfig controls how a LinearIndex parallelizes searches and
+// where it persists itself
+type LinearIndexConfig struct {
+	Workers           int // Goroutines used to compute distances (default runtime.NumCPU())
+	ParallelThreshold int // Indexes with fewer vectors are searched on one goroutine
+
+	// PruneWithNorms precomputes vector norms so searches can skip vectors
+	// that cannot make the top k: by the triangle inequality for Euclidean
+	// distance and by Cauchy-Schwarz for dot products. Other metrics ignore it.
+	PruneWithNorms bool
+
+	// DiscardNorms makes cosine indexes keep only the unit-length vectors,
+	// saving the stored norm per vector. Metric overrides then see the unit
+	// vectors, unless NormMetadataKey names a metadata field in which each
+	// vector's original magnitude is recorded. Other metrics ignore both.
+	DiscardNorms    bool
+	NormMetadataKey string
+
+	// Precision selects float32 (default) or float64 accumulation of distance
+	// computations. Float64 trades a little speed for stable rankings on
+	// high-dimensional vectors.
+	Precision vector.Precision
+
+	// Float16Storage keeps vector values in half precision, halving their
+	// memory. Values are decoded as each distance is computed, and queries
+	// stay float32. See vector.ToFloat16 for the accuracy impact; insert
+	// rejects values beyond vector.MaxFloat16.
+	Float16Storage bool
+
+	// Backend and Key tell Save and Load where to persist the index. Without
+	// a backend, Save and Load do nothing.
+	Backend storage.PersistenceBackend
+	Key     string
+}
+
+// DefaultLinearIndexConfig returns the configuration used by NewLinearIndex
+func DefaultLinearIndexConfig() LinearIndexConfig {
+	return LinearIndexConfig{
+		Workers:           runtime.NumCPU(),
+		ParallelThreshold: 1000,
+	}
+}
+
 // NewLinearIndex creates a new brute-force search index
 func NewLinearIndex(dimension int, metric models.DistanceMetric) (*LinearIndex, error) {
-	distFunc, err := vector.GetDistanceFunc(metric)
+	return NewLinearIndexWithConfig(dimension, metric, DefaultLinearIndexConfig())
+}
+
+// NewLinearIndexWithConfig creates a brute-force search index with explicit
+// parallelism settings. A non-positive worker count means runtime.NumCPU().
+func NewLinearIndexWithConfig(dimension int, metric models.DistanceMetric, config LinearIndexConfig) (*LinearIndex, error) {
+	distFunc, err := vector.GetDistanceFuncWithPrecision(metric, config.Precision)
 	if err != nil {
 		return nil, err
 	}
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
 
-	return &LinearIndex{
+	idx := &LinearIndex{
+		workers:       config.Workers,
+		parallelThreshold: config.ParallelThreshold,
+		backend:       config.Backend,
+		key:           config.Key,
 		dimension:     dimension,
 		distanceFunc:  distFunc,
+		precision:     config.Precision,
 		metric:        metric,
 		vectors:       make(map[string]*models.Vector),
 		keepNormalized: metric == models.Cosine, // Precompute normalization for cosine
-	}, nil
+		pruning:       config.PruneWithNorms && (metric == models.Euclidean || metric == models.DotProduct),
+		discardNorms:  config.DiscardNorms && metric == models.Cosine,
+		normKey:       config.NormMetadataKey,
+		norms:         make(map[string]float32),
+	}
+	if config.Float16Storage {
+		idx.halves = make(map[string][]uint16)
+	}
+	return idx, nil
 }
 
 // Insert adds a vector to the index
 func (idx *LinearIndex) Insert(v *models.Vector) error {
 	if len(v.Values) != idx.dimension {
-		return fmt.Errorf("vector dimension %d does not match index dimension %d", 
-			len(v.Values), idx.dimension)
+		return fmt.Errorf("vector dimension %d does not match index dimension %d: %w", 
+			len(v.Values), idx.dimension, models.ErrDimensionMismatch)
 	}
 
 	// Create a copy to avoid external modifications
 	vectorCopy := v.Copy()
 	
-	// Normalize if needed (for cosine similarity)
+	// Round to float16 up front so norms describe the values actually stored
+	if idx.halves != nil {
+		for _, val := range vectorCopy.Values {
+			if math.Abs(float64(val)) > vector.MaxFloat16 {
+				return fmt.Errorf("value %g of vector %s is out of float16 range: %w", val, v.ID, models.ErrInvalidArgument)
+			}
+		}
+		vector.DecodeFloat16(vectorCopy.Values, vector.EncodeFloat16(vectorCopy.Values))
+	}
+	
+	// Normalize if needed (for cosine similarity), remembering the original
+	// norm so the raw vector can be recovered for other metrics
+	var norm float32
+	if idx.keepNormalized || idx.pruning {
+		norm = vector.PrecomputeNorms([][]float32{vectorCopy.Values})[0]
+	}
 	if idx.keepNormalized {
 		vectorCopy.Normalize()
 	}
+	if idx.discardNorms && idx.normKey != "" {
+		vectorCopy.Metadata[idx.normKey] = float64(norm)
+	}
 
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
 	idx.vectors[v.ID] = vectorCopy
+	if idx.storesNorms() {
+		idx.norms[v.ID] = norm
+	}
+	idx.compact(vectorCopy)
 	return nil
 }
 
+// compact moves a stored vector's values into float16 storage, if the index
+// uses it. Callers must hold the write lock.
+func (idx *LinearIndex) compact(vec *models.Vector) {
+	if idx.halves != nil {
+		idx.halves[vec.ID] = vector.EncodeFloat16(vec.Values)
+		vec.Values = nil
+	}
+}
+
+// valuesOf returns the stored values of vec, decoding float16 storage into buf
+// (which must hold the index dimension). Callers must hold the lock.
+func (idx *LinearIndex) valuesOf(vec *models.Vector, buf []float32) []float32 {
+	if idx.halves == nil {
+		return vec.Values
+	}
+	return vector.DecodeFloat16(buf, idx.halves[vec.ID])
+}
+
+// decoded returns vec itself, or with float16 storage a copy carrying its
+// decoded values. Callers must hold the lock.
+func (idx *LinearIndex) decoded(vec *models.Vector) *models.Vector {
+	if idx.halves == nil {
+		return vec
+	}
+	copied := vec.Copy()
+	copied.Values = idx.valuesOf(vec, make([]float32, idx.dimension))
+	return copied
+}
+
 // Search performs a brute-force search for the nearest neighbors
 func (idx *LinearIndex) Search(
 	query []float32, 
 	k int, 
 	filter *models.MetadataFilter, 
 	params *models.SearchParams,
+) ([]models.SearchResult, error) {
+	return idx.search(query, k, filter, params, nil, false)
+}
+
+// SearchCandidates searches only the vectors with the given IDs, skipping
+// IDs the index does not hold
+func (idx *LinearIndex) SearchCandidates(
+	query []float32,
+	k int,
+	ids []string,
+	filter *models.MetadataFilter,
+	params *models.SearchParams,
+) ([]models.SearchResult, error) {
+	return idx.search(query, k, filter, params, ids, true)
+}
+
+// search scans the stored vectors, or only those listed in ids if restricted
+func (idx *LinearIndex) search(
+	query []float32,
+	k int,
+	filter *models.MetadataFilter,
+	params *models.SearchParams,
+	ids []string,
+	restricted bool,
 ) ([]models.SearchResult, error) {
 	if len(query) != idx.dimension {
-		return nil, fmt.Errorf("query dimension %d does not match index dimension %d",
-			len(query), idx.dimension)
+		return nil, fmt.Errorf("query dimension %d does not match index dimension %d: %w",
+			len(query), idx.dimension, models.ErrDimensionMismatch)
+	}
+
+	// Resolve a per-search metric override. Stored vectors are normalized
+	// for cosine, so other metrics must rescale them back to their raw values.
+	metric := idx.metric
+	distanceFunc := idx.distanceFunc
+	rescale := false
+	if params != nil && params.Metric != nil && *params.Metric != idx.metric {
+		var err error
+		metric = *params.Metric
+		if distanceFunc, err = vector.GetDistanceFuncWithPrecision(metric, idx.precision); err != nil {
+			return nil, err
+		}
+		rescale = idx.keepNormalized && (!idx.discardNorms || idx.normKey != "")
 	}
 
 	// Normalize the query if needed
 	queryCopy := make([]float32, len(query))
 	copy(queryCopy, query)
 	
-	if idx.keepNormalized {
+	// Stored vectors are unit length for cosine, so once the query is
+	// normalized the similarity reduces to a plain dot product
+	if idx.keepNormalized && metric == models.Cosine {
 		vector.NormalizeVector(queryCopy)
+		distanceFunc = vector.CosineSimilarityNormalized
+		if idx.precision == vector.Float64Accumulation {
+			distanceFunc = vector.CosineSimilarityNormalized64
+		}
 	}
 
 	idx.mu.RLock()
@@ -89,109 +272,313 @@ func (idx *LinearIndex) Search(
 		k = len(idx.vectors)
 	}
 
-	// Calculate distances for all vectors
-	results := make([]models.SearchResult, 0, len(idx.vectors))
 	var scoreThreshold float32 = -1
 	if params != nil && params.ScoreThreshold > 0 {
 		scoreThreshold = params.ScoreThreshold
 	}
 
-	// We use a channel to process vectors in parallel
-	type distanceResult struct {
-		id       string
-		vector   *models.Vector
-		distance float32
+	// Stop scanning once the search deadline passes, if one was given
+	var deadline time.Time
+	if params != nil && params.Timeout > 0 {
+		deadline = time.Now().Add(params.Timeout)
 	}
+	var partial int32
 
-	// Choose how many goroutines to use
-	numWorkers := 4
-	if len(idx.vectors) < 1000 {
-		numWorkers = 1 // Use single-threaded for small datasets
+	// Norm-based pruning only holds for the index's own metric, where the
+	// stored values are the raw vectors
+	prune := idx.pruning && metric == idx.metric
+	var queryNorm float32
+	if prune {
+		queryNorm = vector.PrecomputeNorms([][]float32{queryCopy})[0]
 	}
 
-	// Create a channel for distributing work and collecting results
-	workCh := make(chan *models.Vector, numWorkers)
-	resultCh := make(chan distanceResult, numWorkers)
-
-	// Start worker goroutines
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for vec := range workCh {
-				// Skip deleted vectors
-				if vec.Deleted {
-					continue
-				}
+	// Snapshot the vectors so they can be split into contiguous chunks
+	var snapshot []*models.Vector
+	if restricted {
+		snapshot = make([]*models.Vector, 0, len(ids))
+		for _, id := range ids {
+			if vec, ok := idx.vectors[id]; ok {
+				snapshot = append(snapshot, vec)
+			}
+		}
+	} else {
+		snapshot = make([]*models.Vector, 0, len(idx.vectors))
+		for _, vec := range idx.vectors {
+			snapshot = append(snapshot, vec)
+		}
+	}
 
-				// Apply filter if provided
-				if filter != nil && !filter.MatchVector(vec) {
-					continue
-				}
+	// scanChunk computes the results for one chunk of the snapshot
+	scanChunk := func(chunk []*models.Vector) []models.SearchResult {
+		chunkResults := make([]models.SearchResult, 0, len(chunk))
+		var bounds *boundHeap
+		if prune {
+			bounds = &boundHeap{higherIsBetter: vector.IsHigherBetter(metric)}
+		}
+		var computed uint64
+		defer func() { atomic.AddUint64(&idx.computations, computed) }()
+		var buf []float32
+		if idx.halves != nil {
+			buf = make([]float32, idx.dimension)
+		}
+		
+		for _, vec := range chunk {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				atomic.StoreInt32(&partial, 1)
+				break
+			}
 
-				// Calculate distance
-				distance := idx.distanceFunc(queryCopy, vec.Values)
-				
-				resultCh <- distanceResult{
-					id:       vec.ID,
-					vector:   vec,
-					distance: distance,
-				}
+			// Skip deleted vectors
+			if vec.Deleted {
+				continue
 			}
-		}()
-	}
 
-	// Close results channel when all workers are done
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
+			// Insert enforces the dimension, so a mismatch here means the
+			// index is corrupted. Report it rather than letting the distance
+			// function's sentinel value rank the vector last.
+			stored := len(vec.Values)
+			if idx.halves != nil {
+				stored = len(idx.halves[vec.ID])
+			}
+			if stored != idx.dimension {
+				atomic.AddUint64(&idx.mismatches, 1)
+				log.Printf("linear index: skipping corrupted vector %s: dimension %d does not match index dimension %d",
+					vec.ID, stored, idx.dimension)
+				continue
+			}
 
-	// Feed vectors to workers
-	go func() {
-		for _, vec := range idx.vectors {
-			workCh <- vec
+			// Skip vectors whose norm bound can't beat the current k-th best
+			if prune && bounds.Len() == k && bounds.excludes(metric, queryNorm, idx.norms[vec.ID]) {
+				continue
+			}
+
+			// Apply filter if provided
+			if filter != nil && !filter.MatchVector(vec) {
+				continue
+			}
+
+			// Calculate distance
+			values := idx.valuesOf(vec, buf)
+			if rescale {
+				values = scaleVector(values, idx.normOf(vec))
+			}
+			distance := distanceFunc(queryCopy, values)
+			score := vector.NormalizeScore(distance, metric)
+			computed++
+
+			// Apply score threshold if provided
+			if scoreThreshold > 0 && score < scoreThreshold {
+				continue
+			}
+			if prune {
+				bounds.add(distance, k)
+			}
+
+			chunkResults = append(chunkResults, models.SearchResult{
+				ID:       vec.ID,
+				Distance: distance,
+				Vector:   vec,
+				Score:    score,
+			})
 		}
-		close(workCh)
-	}()
+		return chunkResults
+	}
 
-	// Collect results
-	for res := range resultCh {
-		score := vector.NormalizeScore(res.distance, idx.metric)
-		
-		// Apply score threshold if provided
-		if scoreThreshold > 0 && score < scoreThreshold {
-			continue
+	higherIsBetter := vector.IsHigherBetter(metric)
+
+	// Choose how many goroutines to use
+	numWorkers := idx.workers
+	if len(snapshot) < idx.parallelThreshold {
+		numWorkers = 1 // Use single-threaded for small datasets
+	}
+	if numWorkers > len(snapshot) {
+		numWorkers = len(snapshot)
+	}
+
+	// Small searches run inline; larger ones give each worker a contiguous
+	// chunk and its own result slice, so no channels or helper goroutines are
+	// needed and every worker has exited by the time Search returns
+	var results []models.SearchResult
+	if numWorkers <= 1 {
+		results = topResults(scanChunk(snapshot), k, higherIsBetter)
+	} else {
+		chunkSize := (len(snapshot) + numWorkers - 1) / numWorkers
+		numWorkers = (len(snapshot) + chunkSize - 1) / chunkSize // Drop empty trailing chunks
+		chunkResults := make([][]models.SearchResult, numWorkers)
+
+		var wg sync.WaitGroup
+		for i := 0; i < numWorkers; i++ {
+			begin := i * chunkSize
+			end := begin + chunkSize
+			if end > len(snapshot) {
+				end = len(snapshot)
+			}
+			wg.Add(1)
+			go func(i int, chunk []*models.Vector) {
+				defer wg.Done()
+				// Only a chunk's own top k can make the overall top k
+				chunkResults[i] = topResults(scanChunk(chunk), k, higherIsBetter)
+			}(i, snapshot[begin:end])
 		}
-		
-		results = append(results, models.SearchResult{
-			ID:       res.id,
-			Distance: res.distance,
-			Vector:   res.vector,
-			Score:    score,
-		})
+		wg.Wait()
+
+		// Merge the sorted per-chunk results into the overall top k
+		results = models.MergeSearchResults(chunkResults, k, higherIsBetter)
 	}
+	
+	if params != nil {
+		params.Partial = atomic.LoadInt32(&partial) == 1
+	}
+	for i := range results {
+		results[i].Vector = idx.decoded(results[i].Vector)
+	}
+
+	return results, nil
+}
+
+// storesNorms reports whether the index keeps each vector's norm: for cosine
+// (unless discarded) to recover raw values, and for pruning
+func (idx *LinearIndex) storesNorms() bool {
+	return (idx.keepNormalized && !idx.discardNorms) || idx.pruning
+}
+
+// normOf returns the original norm of a stored vector, read from metadata
+// when norms are discarded. Vectors without a recorded norm report 1.
+func (idx *LinearIndex) normOf(vec *models.Vector) float32 {
+	if !idx.discardNorms {
+		return idx.norms[vec.ID]
+	}
+	if norm, ok := models.NumericValue(vec.Metadata[idx.normKey]); ok {
+		return float32(norm)
+	}
+	return 1
+}
+
+// pruneSlack widens the norm bounds slightly so float32 rounding in the
+// distance functions never prunes a vector that would tie the k-th best
+const pruneSlack = 1e-4
+
+// boundHeap holds the k best distances seen so far with the worst of them on
+// top, giving the bound a candidate must beat to enter the top k
+type boundHeap struct {
+	values         []float32
+	higherIsBetter bool
+}
+
+func (h *boundHeap) Len() int { return len(h.values) }
+
+func (h *boundHeap) Less(i, j int) bool {
+	if h.higherIsBetter {
+		return h.values[i] < h.values[j]
+	}
+	return h.values[i] > h.values[j]
+}
+
+func (h *boundHeap) Swap(i, j int) { h.values[i], h.values[j] = h.values[j], h.values[i] }
+
+func (h *boundHeap) Push(x interface{}) { h.values = append(h.values, x.(float32)) }
+
+func (h *boundHeap) Pop() interface{} {
+	last := h.values[len(h.values)-1]
+	h.values = h.values[:len(h.values)-1]
+	return last
+}
+
+// add records a distance, keeping only the k best
+func (h *boundHeap) add(distance float32, k int) {
+	if h.Len() < k {
+		heap.Push(h, distance)
+		return
+	}
+	if (h.higherIsBetter && distance > h.values[0]) || (!h.higherIsBetter && distance < h.values[0]) {
+		h.values[0] = distance
+		heap.Fix(h, 0)
+	}
+}
+
+// excludes reports whether a vector with the given norm cannot beat the
+// current worst of the top k. For Euclidean distance |‖q‖ - ‖v‖| is a lower
+// bound on ‖q - v‖; for dot products ‖q‖‖v‖ is an upper bound on q·v.
+func (h *boundHeap) excludes(metric models.DistanceMetric, queryNorm, norm float32) bool {
+	worst := h.values[0]
+	slack := pruneSlack * (float32(math.Abs(float64(worst))) + 1)
+	if metric == models.Euclidean {
+		return float32(math.Abs(float64(queryNorm-norm))) > worst+slack
+	}
+	return queryNorm*norm < worst-slack
+}
 
-	// Sort the results
-	if vector.IsHigherBetter(idx.metric) {
+// topResults sorts results best-first and truncates them to k. Ties are
+// broken by ID so the order does not depend on map iteration or on how the
+// work was split between workers.
+func topResults(results []models.SearchResult, k int, higherIsBetter bool) []models.SearchResult {
+	if higherIsBetter {
 		// Sort by distance in descending order for similarity metrics
 		sort.Slice(results, func(i, j int) bool {
-			return results[i].Distance > results[j].Distance
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance > results[j].Distance
+			}
+			return results[i].ID < results[j].ID
 		})
 	} else {
 		// Sort by distance in ascending order for distance metrics
 		sort.Slice(results, func(i, j int) bool {
-			return results[i].Distance < results[j].Distance
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance < results[j].Distance
+			}
+			return results[i].ID < results[j].ID
 		})
 	}
 
-	// Return the top k results
 	if len(results) > k {
 		results = results[:k]
 	}
+	return results
+}
 
-	return results, nil
+// Warmup walks every stored vector so subsequent searches hit warm caches.
+// For cosine indexes it also precomputes any missing norms (e.g. for vectors
+// restored by Load) and normalizes those vectors; pruning indexes get their
+// missing norms too. Optionally runs a number of
+// dummy searches using stored vectors as queries. Calling it again is a no-op
+// apart from the dummy searches.
+func (idx *LinearIndex) Warmup(dummySearches int) error {
+	idx.mu.Lock()
+	queries := make([][]float32, 0, dummySearches)
+	for id, vec := range idx.vectors {
+		if idx.storesNorms() {
+			if _, ok := idx.norms[id]; !ok {
+				values := idx.valuesOf(vec, make([]float32, idx.dimension))
+				idx.norms[id] = vector.PrecomputeNorms([][]float32{values})[0]
+				if idx.keepNormalized {
+					vector.NormalizeVector(values)
+					if idx.halves != nil {
+						idx.halves[id] = vector.EncodeFloat16(values)
+					}
+				}
+			}
+		}
+		if len(queries) < dummySearches && !vec.Deleted {
+			queries = append(queries, idx.valuesOf(vec, make([]float32, idx.dimension)))
+		}
+	}
+	idx.mu.Unlock()
+	
+	for _, query := range queries {
+		if _, err := idx.Search(query, 1, nil, nil); err != nil {
+			return fmt.Errorf("warmup search failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// scaleVector returns a copy of v multiplied by factor
+func scaleVector(v []float32, factor float32) []float32 {
+	scaled := make([]float32, len(v))
+	for i, val := range v {
+		scaled[i] = val * factor
+	}
+	return scaled
 }
 
 // Delete removes a vector from the index
@@ -208,7 +595,110 @@ func (idx *LinearIndex) Delete(id string) error {
 	// Alternatively, we could do a hard deletion
 	// delete(idx.vectors, id)
 	
-	return fmt.Errorf("vector with ID %s not found", id)
+	return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+}
+
+// compactChunk bounds how many tombstones Compact purges per acquisition of
+// the write lock, so searches wait for at most one chunk
+const compactChunk = 1024
+
+// Compact purges vectors deleted at least retention ago, along with their
+// stored norms and float16 values. Tombstones are found under the read lock
+// and purged in chunks of compactChunk under the write lock; vectors
+// re-inserted in between are left alone.
+func (idx *LinearIndex) Compact(retention time.Duration) models.CompactionStats {
+	cutoff := time.Now().Add(-retention).UnixNano()
+	
+	idx.mu.RLock()
+	var ids []string
+	for id, vec := range idx.vectors {
+		if vec.Deleted && vec.Timestamp <= cutoff {
+			ids = append(ids, id)
+		}
+	}
+	idx.mu.RUnlock()
+	
+	var stats models.CompactionStats
+	for start := 0; start < len(ids); start += compactChunk {
+		end := start + compactChunk
+		if end > len(ids) {
+			end = len(ids)
+		}
+		
+		idx.mu.Lock()
+		for _, id := range ids[start:end] {
+			vec, ok := idx.vectors[id]
+			if !ok || !vec.Deleted {
+				continue
+			}
+			stats.BytesReclaimed += int64(vec.Size())
+			if halves, ok := idx.halves[id]; ok {
+				stats.BytesReclaimed += int64(2 * len(halves))
+				delete(idx.halves, id)
+			}
+			if _, ok := idx.norms[id]; ok {
+				stats.BytesReclaimed += 4
+				delete(idx.norms, id)
+			}
+			delete(idx.vectors, id)
+			stats.TombstonesPurged++
+		}
+		idx.mu.Unlock()
+	}
+	return stats
+}
+
+// Get returns the stored vector with the given ID, treating soft-deleted
+// vectors as missing. Normalized vectors are scaled back to their original
+// magnitude unless the index discards norms.
+func (idx *LinearIndex) Get(id string) (*models.Vector, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	
+	vec, exists := idx.vectors[id]
+	if !exists || vec.Deleted {
+		return nil, false
+	}
+	found := idx.decoded(vec)
+	if idx.keepNormalized && !idx.discardNorms {
+		if norm := idx.norms[vec.ID]; norm > 0 && norm != 1 {
+			if found == vec {
+				found = vec.Copy()
+			}
+			for i := range found.Values {
+				found.Values[i] *= norm
+			}
+		}
+	}
+	return found, true
+}
+
+// UpdateMetadata replaces the metadata, version and timestamp of a stored
+// vector, keeping its values and norm (including a norm kept in metadata)
+func (idx *LinearIndex) UpdateMetadata(id string, metadata map[string]interface{}, version uint64, timestamp int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	
+	vec, exists := idx.vectors[id]
+	if !exists || vec.Deleted {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+	
+	// Replace rather than mutate the stored vector, which searches may hold
+	updated := *vec
+	updated.Metadata = make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		updated.Metadata[k] = v
+	}
+	if idx.discardNorms && idx.normKey != "" {
+		if norm, ok := vec.Metadata[idx.normKey]; ok {
+			updated.Metadata[idx.normKey] = norm
+		}
+	}
+	updated.Version = version
+	updated.Timestamp = timestamp
+	idx.vectors[id] = &updated
+	return nil
 }
 
 // BatchInsert adds multiple vectors to the index
@@ -221,6 +711,27 @@ func (idx *LinearIndex) BatchInsert(vectors []*models.Vector) error {
 	return nil
 }
 
+// Scan calls fn for every live vector in the index until fn returns false
+func (idx *LinearIndex) Scan(fn func(vector *models.Vector) bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	
+	for _, vec := range idx.vectors {
+		if vec.Deleted {
+			continue
+		}
+		if !fn(idx.decoded(vec)) {
+			return
+		}
+	}
+}
+
+// DimensionMismatches returns how many times a search skipped a stored vector
+// whose dimension did not match the index dimension
+func (idx *LinearIndex) DimensionMismatches() uint64 {
+	return atomic.LoadUint64(&idx.mismatches)
+}
+
 // Size returns the number of vectors in the index
 func (idx *LinearIndex) Size() int {
 	idx.mu.RLock()
@@ -242,14 +753,165 @@ func (idx *LinearIndex) Dimension() int {
 	return idx.dimension
 }
 
-// Load loads the index from disk (stub implementation)
+// Metric returns the distance metric the index ranks by
+func (idx *LinearIndex) Metric() models.DistanceMetric {
+	return idx.metric
+}
+
+// Config returns the configuration the index was created with, for building
+// another index that behaves the same way
+func (idx *LinearIndex) Config() LinearIndexConfig {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return LinearIndexConfig{
+		Workers:           idx.workers,
+		ParallelThreshold: idx.parallelThreshold,
+		PruneWithNorms:    idx.pruning,
+		DiscardNorms:      idx.discardNorms,
+		NormMetadataKey:   idx.normKey,
+		Precision:         idx.precision,
+		Float16Storage:    idx.halves != nil,
+		Backend:           idx.backend,
+		Key:               idx.key,
+	}
+}
+
+// Precision returns the type the index accumulates distance computations in
+func (idx *LinearIndex) Precision() vector.Precision {
+	return idx.precision
+}
+
+// Exact reports that linear searches compare the query against every vector
+func (idx *LinearIndex) Exact() bool {
+	return true
+}
+
+// linearIndexFormat identifies the layout written by Save
+const linearIndexFormat uint32 = 1
+
+// maxVectorRecordSize bounds the encoded size of one stored vector that Load
+// accepts, so a corrupt length prefix cannot make it allocate gigabytes
+const maxVectorRecordSize = 64 << 20 // 64 MiB
+
+// Load replaces the contents of the index with the copy stored in the
+// configured backend. It does nothing if no backend is configured.
 func (idx *LinearIndex) Load() error {
-	// Not implemented for the linear index
+	if idx.backend == nil {
+		return nil
+	}
+	
+	rc, err := idx.backend.Read(idx.key)
+	if err != nil {
+		return fmt.Errorf("failed to read index %s: %w", idx.key, err)
+	}
+	defer rc.Close()
+	r := bufio.NewReader(rc)
+	
+	var header [3]uint32 // Format, dimension, vector count
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("failed to read index header: %w", err)
+	}
+	if header[0] != linearIndexFormat {
+		return fmt.Errorf("unsupported index format %d", header[0])
+	}
+	if int(header[1]) != idx.dimension {
+		return fmt.Errorf("stored index dimension %d does not match index dimension %d: %w",
+			header[1], idx.dimension, models.ErrDimensionMismatch)
+	}
+	
+	// The count is not trusted for sizing: a corrupt header could claim
+	// billions of vectors
+	vectors := make(map[string]*models.Vector)
+	norms := make(map[string]float32)
+	var halves map[string][]uint16
+	if idx.halves != nil {
+		halves = make(map[string][]uint16)
+	}
+	for i := uint32(0); i < header[2]; i++ {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return fmt.Errorf("failed to read vector %d: %w", i, err)
+		}
+		if size > maxVectorRecordSize {
+			return fmt.Errorf("vector %d claims %d bytes, more than the limit of %d", i, size, maxVectorRecordSize)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("failed to read vector %d: %w", i, err)
+		}
+		vec, err := models.DeserializeVector(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode vector %d: %w", i, err)
+		}
+		if len(vec.Values) != idx.dimension {
+			return fmt.Errorf("stored vector %s has dimension %d, expected %d: %w",
+				vec.ID, len(vec.Values), idx.dimension, models.ErrDimensionMismatch)
+		}
+		
+		if halves != nil {
+			vector.DecodeFloat16(vec.Values, vector.EncodeFloat16(vec.Values))
+		}
+		if idx.storesNorms() {
+			norms[vec.ID] = vector.PrecomputeNorms([][]float32{vec.Values})[0]
+		}
+		if idx.keepNormalized {
+			vec.Normalize()
+		}
+		vectors[vec.ID] = vec
+		if halves != nil {
+			halves[vec.ID] = vector.EncodeFloat16(vec.Values)
+			vec.Values = nil
+		}
+	}
+	
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.vectors = vectors
+	idx.norms = norms
+	idx.halves = halves
 	return nil
 }
 
-// Save saves the index to disk (stub implementation)
+// Save writes the live vectors, with their original (unnormalized) values, to
+// the configured backend. Indexes that discard norms rescale from the norm
+// kept in metadata, or write the unit vectors if none is kept. It does nothing
+// if no backend is configured.
 func (idx *LinearIndex) Save() error {
-	// Not implemented for the linear index
+	if idx.backend == nil {
+		return nil
+	}
+	
+	var buf bytes.Buffer
+	idx.mu.RLock()
+	live := make([]*models.Vector, 0, len(idx.vectors))
+	for _, vec := range idx.vectors {
+		if !vec.Deleted {
+			live = append(live, vec)
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, [3]uint32{linearIndexFormat, uint32(idx.dimension), uint32(len(live))})
+	for _, vec := range live {
+		vec = idx.decoded(vec)
+		if idx.keepNormalized {
+			if norm := idx.normOf(vec); norm != 1 {
+				original := vec.Copy()
+				original.Values = scaleVector(vec.Values, norm)
+				vec = original
+			}
+		}
+		data, err := vec.Serialize()
+		if err != nil {
+			idx.mu.RUnlock()
+			return fmt.Errorf("failed to encode index %s: %w", idx.key, err)
+		}
+		binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+	idx.mu.RUnlock()
+	
+	if err := idx.backend.Write(idx.key, &buf); err != nil {
+		return fmt.Errorf("failed to write index %s: %w", idx.key, err)
+	}
 	return nil
-}
\ No newline at end of file
+}
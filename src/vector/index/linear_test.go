@@ -1,10 +1,21 @@
 package index
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"course/models"
 	"course/vector"
+	"course/vector/storage"
 )
 
 func TestLinearIndex(t *testing.T) {
@@ -128,7 +139,7 @@ func TestDifferentMetrics(t *testing.T) {
 		expected string // ID of the vector expected to be the closest match
 	}{
 		{models.Cosine, "v4"},     // Cosine: v4 has the most similar direction
-		{models.DotProduct, "v4"}, // Dot product: v4 has highest dot product with query
+		{models.DotProduct, "v1"}, // Dot product: v1 and v4 tie at 0.7, ties break by ID
 		{models.Euclidean, "v4"},  // Euclidean: v4 is closest in Euclidean space
 		{models.Manhattan, "v4"},  // Manhattan: v4 is closest in Manhattan distance
 	}
@@ -252,10 +263,863 @@ func BenchmarkLinearSearch(b *testing.B) {
 	}
 
 	// Reset timer to exclude setup time
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	// Benchmark search operation
 	for i := 0; i < b.N; i++ {
 		idx.Search(query, 10, nil, &models.SearchParams{})
 	}
-}
\ No newline at end of file
+}
+func TestWarmup(t *testing.T) {
+	idx, err := NewLinearIndex(2, models.Cosine)
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	for _, v := range []*models.Vector{
+		models.NewVector("v1", []float32{3, 4}, nil),
+		models.NewVector("v2", []float32{0, 2}, nil),
+	} {
+		if err := idx.Insert(v); err != nil {
+			t.Fatalf("Error inserting vector: %v", err)
+		}
+	}
+
+	// Simulate vectors restored from disk: raw values and no cached norms
+	idx.vectors["v1"].Values = []float32{3, 4}
+	idx.norms = make(map[string]float32)
+
+	if err := idx.Warmup(2); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+
+	if norm, ok := idx.norms["v1"]; !ok || norm != 5 {
+		t.Errorf("Expected norm 5 for v1 after warmup, got %v (present: %v)", norm, ok)
+	}
+	if _, ok := idx.norms["v2"]; !ok {
+		t.Errorf("Expected norm for v2 after warmup")
+	}
+	if values := idx.vectors["v1"].Values; values[0] != 0.6 || values[1] != 0.8 {
+		t.Errorf("Expected v1 to be normalized after warmup, got %v", values)
+	}
+
+	// A second warmup changes nothing
+	if err := idx.Warmup(2); err != nil {
+		t.Fatalf("Second warmup failed: %v", err)
+	}
+	if norm := idx.norms["v1"]; norm != 5 {
+		t.Errorf("Expected warmup to be idempotent, norm changed to %v", norm)
+	}
+	if values := idx.vectors["v1"].Values; values[0] != 0.6 || values[1] != 0.8 {
+		t.Errorf("Expected warmup to be idempotent, values changed to %v", values)
+	}
+}
+
+func TestSearchReportsDimensionMismatch(t *testing.T) {
+	idx, err := NewLinearIndex(3, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	if err := idx.Insert(models.NewVector("good", []float32{1, 0, 0}, nil)); err != nil {
+		t.Fatalf("Error inserting vector: %v", err)
+	}
+
+	// Bypass Insert to simulate a corrupted entry
+	idx.vectors["bad"] = models.NewVector("bad", []float32{1, 0}, nil)
+
+	results, err := idx.Search([]float32{1, 0, 0}, 10, nil, &models.SearchParams{})
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "good" {
+		t.Errorf("Expected only the valid vector to be ranked, got %+v", results)
+	}
+	if n := idx.DimensionMismatches(); n != 1 {
+		t.Errorf("Expected 1 reported mismatch, got %d", n)
+	}
+}
+
+func TestSearchTimeoutReturnsPartialResults(t *testing.T) {
+	idx, err := NewLinearIndex(2, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("v%d", i)
+		if err := idx.Insert(models.NewVector(id, []float32{float32(i), 0}, nil)); err != nil {
+			t.Fatalf("Error inserting vector %s: %v", id, err)
+		}
+	}
+
+	// Slow every distance computation down so the deadline hits mid-scan
+	distanceFunc := idx.distanceFunc
+	idx.distanceFunc = func(a, b []float32) float32 {
+		time.Sleep(2 * time.Millisecond)
+		return distanceFunc(a, b)
+	}
+
+	params := &models.SearchParams{Timeout: 10 * time.Millisecond}
+	results, err := idx.Search([]float32{0, 0}, 50, nil, params)
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if !params.Partial {
+		t.Errorf("Expected search to be flagged as partial")
+	}
+	if len(results) == 0 || len(results) >= 50 {
+		t.Errorf("Expected a non-empty subset of results, got %d", len(results))
+	}
+
+	// Reusing the params for a search that completes clears the flag
+	idx.distanceFunc = distanceFunc
+	params.Timeout = time.Minute
+	if _, err := idx.Search([]float32{0, 0}, 50, nil, params); err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if params.Partial {
+		t.Errorf("Expected a completed search to clear Partial")
+	}
+}
+
+func TestCosineSearchMatchesFullCosine(t *testing.T) {
+	dim := 16
+	idx, err := NewLinearIndex(dim, models.Cosine)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	randomVector := func() []float32 {
+		values := make([]float32, dim)
+		for i := range values {
+			values[i] = rng.Float32()*2 - 1
+		}
+		return values
+	}
+
+	raw := make(map[string][]float32)
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("v%d", i)
+		raw[id] = randomVector()
+		if err := idx.Insert(models.NewVector(id, raw[id], nil)); err != nil {
+			t.Fatalf("Error inserting vector %s: %v", id, err)
+		}
+	}
+
+	query := randomVector()
+	results, err := idx.Search(query, 20, nil, &models.SearchParams{})
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+
+	// Rank with the full cosine similarity on the raw vectors
+	ids := make([]string, 0, len(raw))
+	for id := range raw {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return vector.CosineSimilarity(query, raw[ids[i]]) > vector.CosineSimilarity(query, raw[ids[j]])
+	})
+
+	for i, res := range results {
+		expected := vector.CosineSimilarity(query, raw[ids[i]])
+		if math.Abs(float64(res.Distance-expected)) > 1e-5 {
+			t.Errorf("Rank %d: expected similarity %f (%s), got %f (%s)",
+				i, expected, ids[i], res.Distance, res.ID)
+		}
+	}
+}
+
+func BenchmarkCosineSimilarity(b *testing.B) {
+	dim := 128
+	x := make([]float32, dim)
+	y := make([]float32, dim)
+	for i := 0; i < dim; i++ {
+		x[i] = float32(i%10) / 10.0
+		y[i] = float32((i+3)%10) / 10.0
+	}
+
+	b.Run("Full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vector.CosineSimilarity(x, y)
+		}
+	})
+
+	vector.NormalizeVector(x)
+	vector.NormalizeVector(y)
+	b.Run("Normalized", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vector.CosineSimilarityNormalized(x, y)
+		}
+	})
+}
+
+func TestGetSkipsDeletedVectors(t *testing.T) {
+	idx, err := NewLinearIndex(2, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	collection := models.NewVectorCollection("test", 2, models.Euclidean)
+	if err := collection.AddIndex("linear", idx); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	for _, id := range []string{"live", "deleted"} {
+		if err := collection.Insert(models.NewVector(id, []float32{1, 2}, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := collection.Delete("deleted"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// The index keeps a tombstone for the deleted vector
+	if _, ok := idx.vectors["deleted"]; !ok {
+		t.Fatalf("Expected the deleted vector to be soft-deleted")
+	}
+	if _, ok := idx.Get("deleted"); ok {
+		t.Errorf("Expected Get to skip the soft-deleted vector")
+	}
+	if _, ok := collection.GetByID("deleted"); ok {
+		t.Errorf("Expected GetByID to skip the soft-deleted vector")
+	}
+
+	v, ok := collection.GetByID("live")
+	if !ok || v.ID != "live" || v.Values[1] != 2 {
+		t.Errorf("Expected GetByID to return the live vector, got %+v", v)
+	}
+}
+
+func TestUpdateMetadataKeepsMagnitude(t *testing.T) {
+	idx, err := NewLinearIndex(2, models.Cosine)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	collection := models.NewVectorCollection("test", 2, models.Cosine)
+	if err := collection.AddIndex("linear", idx); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	if err := collection.Insert(models.NewVector("v1", []float32{3, 4}, map[string]interface{}{"rev": "a"})); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	euclidean := models.Euclidean
+	params := &models.SearchParams{Metric: &euclidean}
+	before, err := collection.Search([]float32{0, 0}, 1, nil, params)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	updated, err := collection.UpdateMetadata("v1", map[string]interface{}{"rev": "b"}, 0)
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if updated.Version != 2 || updated.Metadata["rev"] != "b" {
+		t.Errorf("Unexpected vector after metadata update: %+v", updated)
+	}
+
+	stored, ok := collection.GetByID("v1")
+	if !ok || math.Abs(float64(stored.Values[0]-3)) > 1e-5 || math.Abs(float64(stored.Values[1]-4)) > 1e-5 {
+		t.Errorf("Expected GetByID to return [3 4] after the update, got %v", stored)
+	}
+	if norm := idx.norms["v1"]; math.Abs(float64(norm-5)) > 1e-5 {
+		t.Errorf("Expected the stored norm to stay 5, got %f", norm)
+	}
+
+	after, err := collection.Search([]float32{0, 0}, 1, nil, params)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(after) != 1 || math.Abs(float64(after[0].Distance-before[0].Distance)) > 1e-5 {
+		t.Errorf("Expected the euclidean distance to stay %f, got %v", before[0].Distance, after)
+	}
+}
+
+func TestBatchRollbackKeepsMagnitude(t *testing.T) {
+	config := DefaultLinearIndexConfig()
+	config.Float16Storage = true
+	idx, err := NewLinearIndexWithConfig(2, models.Cosine, config)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	collection := models.NewVectorCollection("test", 2, models.Cosine)
+	if err := collection.AddIndex("linear", idx); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	if err := collection.Insert(models.NewVector("v1", []float32{3, 4}, nil)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// The out-of-range value fails the batch after v1 was overwritten
+	err = collection.BatchInsert([]*models.Vector{
+		models.NewVector("v1", []float32{1, 0}, nil),
+		models.NewVector("v2", []float32{1e6, 0}, nil),
+	})
+	if err == nil {
+		t.Fatalf("Expected the batch to fail")
+	}
+
+	stored, ok := collection.GetByID("v1")
+	if !ok || math.Abs(float64(stored.Values[0]-3)) > 1e-2 || math.Abs(float64(stored.Values[1]-4)) > 1e-2 {
+		t.Errorf("Expected the rollback to restore [3 4], got %v", stored)
+	}
+	if norm := idx.norms["v1"]; math.Abs(float64(norm-5)) > 1e-2 {
+		t.Errorf("Expected the rollback to restore norm 5, got %f", norm)
+	}
+	if _, ok := collection.GetByID("v2"); ok {
+		t.Errorf("Expected v2 to be rolled back")
+	}
+}
+
+func TestWorkerCountsProduceIdenticalResults(t *testing.T) {
+	data := randomVectors(2000, 8, 7)
+	// Duplicate some vectors so ties have to be broken consistently
+	for i := 0; i < 50; i++ {
+		data = append(data, data[i])
+	}
+	query := randomVectors(1, 8, 8)[0]
+
+	var baseline []models.SearchResult
+	for _, workers := range []int{1, 2, 3, 8, 7000} {
+		idx, err := NewLinearIndexWithConfig(8, models.Euclidean, LinearIndexConfig{Workers: workers})
+		if err != nil {
+			t.Fatalf("Failed to create linear index: %v", err)
+		}
+		for i, values := range data {
+			idx.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		}
+
+		results, err := idx.Search(query, 100, nil, &models.SearchParams{})
+		if err != nil {
+			t.Fatalf("Error searching with %d workers: %v", workers, err)
+		}
+		if baseline == nil {
+			baseline = results
+			continue
+		}
+		for i := range results {
+			if results[i].ID != baseline[i].ID || results[i].Distance != baseline[i].Distance {
+				t.Fatalf("Workers=%d: result %d is %s (%f), expected %s (%f)", workers, i,
+					results[i].ID, results[i].Distance, baseline[i].ID, baseline[i].Distance)
+			}
+		}
+	}
+}
+
+func BenchmarkLinearSearchWorkers(b *testing.B) {
+	data := randomVectors(20000, 128, 1)
+	query := randomVectors(1, 128, 2)[0]
+
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		idx, _ := NewLinearIndexWithConfig(128, models.Euclidean, LinearIndexConfig{Workers: workers})
+		for i, values := range data {
+			idx.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		}
+
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				idx.Search(query, 10, nil, &models.SearchParams{})
+			}
+		})
+	}
+}
+
+func TestSearchDoesNotLeakGoroutines(t *testing.T) {
+	idx, err := NewLinearIndexWithConfig(8, models.Euclidean, LinearIndexConfig{Workers: 4})
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	for i, values := range randomVectors(2000, 8, 1) {
+		idx.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+	}
+	query := randomVectors(1, 8, 2)[0]
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 200; i++ {
+		// Alternate between full scans and scans cut short by a deadline
+		params := &models.SearchParams{}
+		if i%2 == 1 {
+			params.Timeout = time.Nanosecond
+		}
+		if _, err := idx.Search(query, 10, nil, params); err != nil {
+			t.Fatalf("Error searching: %v", err)
+		}
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no leaked goroutines, had %d before and %d after", before, after)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	config := DefaultLinearIndexConfig()
+	config.Backend = backend
+	config.Key = "collections/test/linear"
+
+	original, _ := NewLinearIndexWithConfig(3, models.Cosine, config)
+	original.Insert(models.NewVector("v1", []float32{3, 0, 4}, map[string]interface{}{"category": "a", "rank": 1.0}))
+	original.Insert(models.NewVector("v2", []float32{0, 2, 0}, map[string]interface{}{"tags": []interface{}{"x", "y"}}))
+	original.Insert(models.NewVector("v3", []float32{1, 1, 1}, nil))
+	original.Delete("v3")
+	if err := original.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored, _ := NewLinearIndexWithConfig(3, models.Cosine, config)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if size := restored.Size(); size != 2 {
+		t.Fatalf("Expected 2 vectors after load, got %d", size)
+	}
+	if _, ok := restored.Get("v3"); ok {
+		t.Errorf("Deleted vector was persisted")
+	}
+
+	v1, ok := restored.Get("v1")
+	if !ok {
+		t.Fatalf("v1 missing after load")
+	}
+	if v1.Metadata["category"] != "a" || v1.Metadata["rank"] != 1.0 {
+		t.Errorf("Metadata not restored: %v", v1.Metadata)
+	}
+
+	// Original norms must survive so metric overrides see the raw values
+	euclidean := models.Euclidean
+	results, err := restored.Search([]float32{3, 0, 4}, 1, nil, &models.SearchParams{Metric: &euclidean})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results[0].ID != "v1" || results[0].Distance > 1e-5 {
+		t.Errorf("Expected v1 at distance 0, got %+v", results[0])
+	}
+
+	// Indexes without a backend keep the old no-op behavior
+	plain, _ := NewLinearIndex(3, models.Cosine)
+	if err := plain.Save(); err != nil {
+		t.Errorf("Save without a backend failed: %v", err)
+	}
+	mismatched, _ := NewLinearIndexWithConfig(4, models.Cosine, config)
+	if err := mismatched.Load(); err == nil {
+		t.Errorf("Expected loading into a different dimension to fail")
+	}
+
+	// Indexes that discard norms save the raw values from the recorded norm
+	discarding := config
+	discarding.DiscardNorms = true
+	discarding.NormMetadataKey = "_norm"
+	unit, _ := NewLinearIndexWithConfig(3, models.Cosine, discarding)
+	unit.Insert(models.NewVector("v1", []float32{3, 0, 4}, nil))
+	if err := unit.Save(); err != nil {
+		t.Fatalf("Save with discarded norms failed: %v", err)
+	}
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	results, err = restored.Search([]float32{3, 0, 4}, 1, nil, &models.SearchParams{Metric: &euclidean})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "v1" || results[0].Distance > 1e-5 {
+		t.Errorf("Expected the saved v1 to keep its norm, got %+v", results)
+	}
+}
+
+func TestLoadRejectsCorruptFiles(t *testing.T) {
+	record := func(v *models.Vector) []byte {
+		data, err := v.Serialize()
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+		return buf.Bytes()
+	}
+	file := func(count uint32, records ...[]byte) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, [3]uint32{linearIndexFormat, 3, count})
+		for _, r := range records {
+			buf.Write(r)
+		}
+		return buf.Bytes()
+	}
+	oversized := make([]byte, 4)
+	binary.LittleEndian.PutUint32(oversized, maxVectorRecordSize+1)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mismatch bool
+	}{
+		{"TruncatedAfterHugeCount", file(1 << 31), false},
+		{"OversizedRecord", file(1, oversized), false},
+		{"WrongDimension", file(1, record(models.NewVector("v1", []float32{1, 2}, nil))), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := storage.NewMemoryBackend()
+			config := DefaultLinearIndexConfig()
+			config.Backend = backend
+			config.Key = "corrupt"
+			if err := backend.Write("corrupt", bytes.NewReader(test.data)); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			idx, _ := NewLinearIndexWithConfig(3, models.Euclidean, config)
+			err := idx.Load()
+			if err == nil {
+				t.Fatalf("Expected Load to reject the file")
+			}
+			if test.mismatch && !errors.Is(err, models.ErrDimensionMismatch) {
+				t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectionLoad(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	config := DefaultLinearIndexConfig()
+	config.Backend = backend
+	config.Key = "collections/test/linear"
+
+	newCollection := func() (*models.VectorCollection, error) {
+		idx, err := NewLinearIndexWithConfig(2, models.Euclidean, config)
+		if err != nil {
+			return nil, err
+		}
+		collection := models.NewVectorCollection("test", 2, models.Euclidean)
+		if err := collection.AddIndex("linear", idx); err != nil {
+			return nil, err
+		}
+		return collection, collection.SetIndexedFields([]string{"region"})
+	}
+	original, err := newCollection()
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	original.Insert(models.NewVector("v1", []float32{1, 0}, map[string]interface{}{"region": "A"}))
+	original.Insert(models.NewVector("v2", []float32{0, 1}, map[string]interface{}{"region": "B"}))
+	original.UpdateMetadata("v2", map[string]interface{}{"region": "A"}, 0)
+	if err := original.Indexes["linear"].Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored, err := newCollection()
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if size := restored.Size(); size != 2 {
+		t.Fatalf("Expected 2 vectors after load, got %d", size)
+	}
+	v2, ok := restored.GetByID("v2")
+	if !ok || v2.Version != 2 || v2.Metadata["region"] != "A" {
+		t.Errorf("Expected v2 at version 2 in region A, got %+v", v2)
+	}
+
+	filter := models.NewAndFilter(models.NewEqualsCondition("region", "A"))
+	results, err := restored.Search([]float32{1, 0}, 5, filter, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected v1 and v2 in region A, got %+v", results)
+	}
+	if restored.IndexedFilterSearches() == 0 {
+		t.Errorf("Expected the filtered search to use the rebuilt metadata index")
+	}
+	if count, err := restored.Count(filter); err != nil || count != 2 {
+		t.Errorf("Expected to count 2 vectors in region A, got %d (%v)", count, err)
+	}
+	if _, err := restored.UpdateMetadata("v2", map[string]interface{}{"region": "B"}, 2); err != nil {
+		t.Errorf("Expected the persisted version to be current, got %v", err)
+	}
+
+	// Metadata JSON cannot encode fails the save instead of being dropped
+	restored.Insert(models.NewVector("v3", []float32{1, 1}, map[string]interface{}{"bad": math.NaN()}))
+	if err := restored.Indexes["linear"].Save(); err == nil {
+		t.Errorf("Expected Save to fail on unencodable metadata")
+	}
+}
+
+// clusteredVectors generates points around centers whose norms range from 1
+// to clusters, so that norm bounds separate the clusters
+func clusteredVectors(n, dim, clusters int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	centers := make([][]float32, clusters)
+	for c := range centers {
+		direction := randomVectors(1, dim, seed+int64(c)+1)[0]
+		vector.NormalizeVector(direction)
+		centers[c] = make([]float32, dim)
+		for d := range direction {
+			centers[c][d] = direction[d] * float32(c+1)
+		}
+	}
+
+	data := make([][]float32, n)
+	for i := range data {
+		center := centers[i%clusters]
+		data[i] = make([]float32, dim)
+		for d := range center {
+			data[i][d] = center[d] + float32(rng.NormFloat64()*0.05)
+		}
+	}
+	return data
+}
+
+func TestNormPruningMatchesUnpruned(t *testing.T) {
+	data := clusteredVectors(3000, 16, 20, 11)
+	queries := clusteredVectors(20, 16, 20, 12)
+
+	for _, metric := range []models.DistanceMetric{models.Euclidean, models.DotProduct} {
+		for _, workers := range []int{1, 4} {
+			plain, _ := NewLinearIndexWithConfig(16, metric, LinearIndexConfig{Workers: workers})
+			pruned, _ := NewLinearIndexWithConfig(16, metric, LinearIndexConfig{Workers: workers, PruneWithNorms: true})
+			for i, values := range data {
+				plain.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+				pruned.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+			}
+
+			for q, query := range queries {
+				expected, err := plain.Search(query, 10, nil, &models.SearchParams{})
+				if err != nil {
+					t.Fatalf("Unpruned search failed: %v", err)
+				}
+				results, err := pruned.Search(query, 10, nil, &models.SearchParams{})
+				if err != nil {
+					t.Fatalf("Pruned search failed: %v", err)
+				}
+				if len(results) != len(expected) {
+					t.Fatalf("%s/%d workers, query %d: got %d results, expected %d",
+						metric, workers, q, len(results), len(expected))
+				}
+				for i := range results {
+					if results[i].ID != expected[i].ID || results[i].Distance != expected[i].Distance {
+						t.Fatalf("%s/%d workers, query %d: result %d is %s (%f), expected %s (%f)",
+							metric, workers, q, i, results[i].ID, results[i].Distance, expected[i].ID, expected[i].Distance)
+					}
+				}
+			}
+
+			if pruned.computations >= plain.computations {
+				t.Errorf("%s/%d workers: pruning computed %d distances, unpruned %d",
+					metric, workers, pruned.computations, plain.computations)
+			}
+		}
+	}
+}
+
+func BenchmarkNormPruning(b *testing.B) {
+	data := clusteredVectors(20000, 128, 50, 1)
+	query := clusteredVectors(1, 128, 50, 2)[0]
+
+	for _, prune := range []bool{false, true} {
+		idx, _ := NewLinearIndexWithConfig(128, models.Euclidean, LinearIndexConfig{Workers: 1, PruneWithNorms: prune})
+		for i, values := range data {
+			idx.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		}
+
+		b.Run(fmt.Sprintf("Prune=%v", prune), func(b *testing.B) {
+			before := atomic.LoadUint64(&idx.computations)
+			for i := 0; i < b.N; i++ {
+				idx.Search(query, 10, nil, &models.SearchParams{})
+			}
+			b.ReportMetric(float64(atomic.LoadUint64(&idx.computations)-before)/float64(b.N), "dists/op")
+		})
+	}
+}
+
+func TestDiscardNorms(t *testing.T) {
+	const dim = 16
+	data := randomVectors(500, dim, 21)
+	for i := range data {
+		scale := float32(i%7 + 1)
+		for j := range data[i] {
+			data[i][j] *= scale
+		}
+	}
+
+	config := DefaultLinearIndexConfig()
+	config.DiscardNorms = true
+	config.NormMetadataKey = "_norm"
+	idx, _ := NewLinearIndexWithConfig(dim, models.Cosine, config)
+	full, _ := NewLinearIndex(dim, models.Cosine)
+	for i, values := range data {
+		idx.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		full.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+	}
+
+	if len(idx.norms) != 0 {
+		t.Errorf("Expected no stored norms, got %d", len(idx.norms))
+	}
+	for i, values := range data {
+		stored, ok := idx.Get(fmt.Sprintf("v%d", i))
+		if !ok {
+			t.Fatalf("Vector v%d missing", i)
+		}
+		if norm := vector.PrecomputeNorms([][]float32{stored.Values})[0]; math.Abs(float64(norm)-1) > 1e-5 {
+			t.Errorf("Vector v%d has norm %f, expected unit length", i, norm)
+		}
+		original := vector.PrecomputeNorms([][]float32{values})[0]
+		if recorded, _ := models.NumericValue(stored.Metadata["_norm"]); math.Abs(recorded-float64(original)) > 1e-4 {
+			t.Errorf("Vector v%d recorded norm %f, expected %f", i, recorded, original)
+		}
+	}
+
+	// Rankings match cosine over the raw vectors
+	for q, query := range randomVectors(10, dim, 22) {
+		expected := make([]int, len(data))
+		for i := range expected {
+			expected[i] = i
+		}
+		sort.Slice(expected, func(a, b int) bool {
+			return vector.CosineSimilarity(query, data[expected[a]]) > vector.CosineSimilarity(query, data[expected[b]])
+		})
+
+		results, err := idx.Search(query, 10, nil, &models.SearchParams{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for i, res := range results {
+			if res.ID != fmt.Sprintf("v%d", expected[i]) {
+				t.Errorf("Query %d: result %d is %s, expected v%d", q, i, res.ID, expected[i])
+			}
+		}
+
+		// Metric overrides recover the raw vectors from the recorded norms
+		euclidean := models.Euclidean
+		got, _ := idx.Search(query, 5, nil, &models.SearchParams{Metric: &euclidean})
+		want, _ := full.Search(query, 5, nil, &models.SearchParams{Metric: &euclidean})
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Errorf("Query %d: Euclidean override result %d is %s, expected %s", q, i, got[i].ID, want[i].ID)
+			}
+		}
+	}
+}
+
+func TestFloat64PrecisionIndex(t *testing.T) {
+	const dim = 64
+	data := randomVectors(300, dim, 5)
+	queries := randomVectors(10, dim, 6)
+
+	for _, metric := range []models.DistanceMetric{models.Cosine, models.Euclidean} {
+		config := DefaultLinearIndexConfig()
+		config.Precision = vector.Float64Accumulation
+		precise, _ := NewLinearIndexWithConfig(dim, metric, config)
+		plain, _ := NewLinearIndex(dim, metric)
+		for i, values := range data {
+			precise.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+			plain.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		}
+		if precise.Precision() != vector.Float64Accumulation {
+			t.Errorf("%v: expected float64 precision, got %v", metric, precise.Precision())
+		}
+
+		for q, query := range queries {
+			got, _ := precise.Search(query, 5, nil, nil)
+			want, _ := plain.Search(query, 5, nil, nil)
+			for i := range want {
+				if math.Abs(float64(got[i].Distance-want[i].Distance)) > 1e-4 {
+					t.Errorf("%v query %d rank %d: distance %v, expected about %v", metric, q, i, got[i].Distance, want[i].Distance)
+				}
+			}
+		}
+	}
+}
+
+func TestFloat16StorageRecall(t *testing.T) {
+	const dim, k = 64, 10
+	data := randomVectors(2000, dim, 11)
+	queries := randomVectors(50, dim, 12)
+
+	full, _ := NewLinearIndex(dim, models.Cosine)
+	config := DefaultLinearIndexConfig()
+	config.Float16Storage = true
+	half, _ := NewLinearIndexWithConfig(dim, models.Cosine, config)
+	for i, values := range data {
+		full.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil))
+		if err := half.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	eval, err := vector.EvaluateIndex(full, half, queries, k, nil)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if eval.Recall < 0.97 {
+		t.Errorf("Expected float16 storage to keep recall@%d within 3%% of float32, got %.3f", k, eval.Recall)
+	}
+
+	// Stored vectors come back decoded
+	stored, ok := half.Get("v0")
+	if !ok || len(stored.Values) != dim {
+		t.Fatalf("Expected v0 with %d values, got %v", dim, stored)
+	}
+	if half.halves == nil || half.vectors["v0"].Values != nil {
+		t.Errorf("Expected values to be held only in float16")
+	}
+
+	if err := half.Insert(models.NewVector("huge", append(make([]float32, dim-1), 1e6), nil)); err == nil {
+		t.Errorf("Expected values beyond the float16 range to be rejected")
+	}
+}
+
+func TestCompactPurgesExpiredTombstones(t *testing.T) {
+	idx, err := NewLinearIndex(2, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	for _, id := range []string{"live", "old", "recent", "reinserted"} {
+		if err := idx.Insert(models.NewVector(id, []float32{1, 2}, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for _, id := range []string{"old", "recent", "reinserted"} {
+		if err := idx.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := idx.Insert(models.NewVector("reinserted", []float32{3, 4}, nil)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	// Age the tombstone of "old" past the retention window
+	idx.vectors["old"].Timestamp = time.Now().Add(-2 * time.Hour).UnixNano()
+
+	stats := idx.Compact(time.Hour)
+	if stats.TombstonesPurged != 1 || stats.BytesReclaimed <= 0 {
+		t.Errorf("Expected one tombstone purged with bytes reclaimed, got %+v", stats)
+	}
+	if _, ok := idx.vectors["old"]; ok {
+		t.Errorf("Expected the expired tombstone to be purged")
+	}
+	if _, ok := idx.vectors["recent"]; !ok {
+		t.Errorf("Expected the tombstone within the retention window to be kept")
+	}
+
+	// Live vectors, including one re-inserted after deletion, are untouched
+	for id, want := range map[string]float32{"live": 2, "reinserted": 4} {
+		v, ok := idx.Get(id)
+		if !ok || v.Values[1] != want {
+			t.Errorf("Expected %s to survive compaction, got %+v", id, v)
+		}
+	}
+	if idx.Size() != 2 {
+		t.Errorf("Expected 2 live vectors, got %d", idx.Size())
+	}
+
+	// Without retention every tombstone goes
+	if stats := idx.Compact(0); stats.TombstonesPurged != 1 {
+		t.Errorf("Expected the remaining tombstone purged, got %+v", stats)
+	}
+	if len(idx.vectors) != 2 {
+		t.Errorf("Expected only live vectors left, got %d entries", len(idx.vectors))
+	}
+}
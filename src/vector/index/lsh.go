@@ -0,0 +1,276 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"course/models"
+	"course/vector"
+)
+
+// LSHIndex is a locality-sensitive hashing index for cosine similarity. Each
+// hash table draws a set of random hyperplanes and hashes a vector to the bit
+// pattern of which side of every hyperplane it falls on, so vectors separated
+// by a small angle usually share a bucket. A search ranks the vectors found in
+// the query's bucket, and in every bucket one bit away from it, of each
+// table. More tables raise recall; more hyperplanes per table make buckets
+// smaller and searches faster.
+type LSHIndex struct {
+	dimension   int
+	tables      int
+	hyperplanes int                          // Hyperplanes (hash bits) per table
+	planes      [][][]float32                // [table][hyperplane][dimension]
+	buckets     []map[uint64]map[string]bool // [table] hash -> vector IDs
+	hashes      map[string][]uint64          // Hash of every stored vector in each table
+	vectors     map[string]*models.Vector    // Stored (normalized) vectors
+	mu          sync.RWMutex
+}
+
+// NewLSHIndex creates an LSH index with the given number of hash tables and
+// hyperplanes per table. Only cosine similarity is supported, and at most 64
+// hyperplanes may be used per table.
+func NewLSHIndex(dimension int, metric models.DistanceMetric, tables, hyperplanes int) (*LSHIndex, error) {
+	if metric != models.Cosine {
+		return nil, fmt.Errorf("metric %s is not supported by the LSH index", vector.MetricName(metric))
+	}
+	if tables <= 0 {
+		return nil, fmt.Errorf("number of hash tables must be positive, got %d", tables)
+	}
+	if hyperplanes <= 0 || hyperplanes > 64 {
+		return nil, fmt.Errorf("hyperplanes per table must be between 1 and 64, got %d", hyperplanes)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	planes := make([][][]float32, tables)
+	buckets := make([]map[uint64]map[string]bool, tables)
+	for t := range planes {
+		planes[t] = make([][]float32, hyperplanes)
+		for h := range planes[t] {
+			plane := make([]float32, dimension)
+			for d := range plane {
+				plane[d] = float32(rng.NormFloat64())
+			}
+			planes[t][h] = plane
+		}
+		buckets[t] = make(map[uint64]map[string]bool)
+	}
+
+	return &LSHIndex{
+		dimension:   dimension,
+		tables:      tables,
+		hyperplanes: hyperplanes,
+		planes:      planes,
+		buckets:     buckets,
+		hashes:      make(map[string][]uint64),
+		vectors:     make(map[string]*models.Vector),
+	}, nil
+}
+
+// hash returns the bucket of values in every table
+func (idx *LSHIndex) hash(values []float32) []uint64 {
+	hashes := make([]uint64, idx.tables)
+	for t, planes := range idx.planes {
+		var h uint64
+		for i, plane := range planes {
+			if vector.DotProduct(values, plane) >= 0 {
+				h |= 1 << uint(i)
+			}
+		}
+		hashes[t] = h
+	}
+	return hashes
+}
+
+// Insert hashes a vector into every table, replacing any vector with the same ID
+func (idx *LSHIndex) Insert(v *models.Vector) error {
+	if len(v.Values) != idx.dimension {
+		return fmt.Errorf("vector dimension %d does not match index dimension %d: %w",
+			len(v.Values), idx.dimension, models.ErrDimensionMismatch)
+	}
+
+	vectorCopy := v.Copy()
+	vectorCopy.Normalize()
+	hashes := idx.hash(vectorCopy.Values)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(v.ID)
+	for t, h := range hashes {
+		bucket, ok := idx.buckets[t][h]
+		if !ok {
+			bucket = make(map[string]bool)
+			idx.buckets[t][h] = bucket
+		}
+		bucket[v.ID] = true
+	}
+	idx.hashes[v.ID] = hashes
+	idx.vectors[v.ID] = vectorCopy
+	return nil
+}
+
+// BatchInsert adds multiple vectors to the index
+func (idx *LSHIndex) BatchInsert(vectors []*models.Vector) error {
+	for _, v := range vectors {
+		if err := idx.Insert(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search ranks the vectors sharing a bucket with the query, or one bit away
+// from it, in any of the hash tables
+func (idx *LSHIndex) Search(
+	query []float32,
+	k int,
+	filter *models.MetadataFilter,
+	params *models.SearchParams,
+) ([]models.SearchResult, error) {
+	if len(query) != idx.dimension {
+		return nil, fmt.Errorf("query dimension %d does not match index dimension %d: %w",
+			len(query), idx.dimension, models.ErrDimensionMismatch)
+	}
+	if params != nil && params.Metric != nil && *params.Metric != models.Cosine {
+		return nil, errors.New("the LSH index does not support metric overrides")
+	}
+
+	if k <= 0 {
+		k = 10 // Default to 10 results
+	}
+	var scoreThreshold float32
+	if params != nil {
+		scoreThreshold = params.ScoreThreshold
+	}
+
+	prepared := append([]float32(nil), query...)
+	vector.NormalizeVector(prepared)
+	hashes := idx.hash(prepared)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var results []models.SearchResult
+	visit := func(bucket map[string]bool) {
+		for id := range bucket {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			vec := idx.vectors[id]
+			if filter != nil && !filter.MatchVector(vec) {
+				continue
+			}
+
+			similarity := vector.CosineSimilarityNormalized(prepared, vec.Values)
+			score := vector.NormalizeScore(similarity, models.Cosine)
+			if scoreThreshold > 0 && score < scoreThreshold {
+				continue
+			}
+
+			results = append(results, models.SearchResult{
+				ID:       id,
+				Distance: similarity,
+				Vector:   vec,
+				Score:    score,
+			})
+		}
+	}
+
+	for t, h := range hashes {
+		visit(idx.buckets[t][h])
+		for bit := 0; bit < idx.hyperplanes; bit++ {
+			visit(idx.buckets[t][h^(1<<uint(bit))])
+		}
+	}
+
+	return topResults(results, k, true), nil
+}
+
+// removeLocked drops a vector from every bucket. Callers must hold the lock.
+func (idx *LSHIndex) removeLocked(id string) bool {
+	hashes, exists := idx.hashes[id]
+	if !exists {
+		return false
+	}
+	for t, h := range hashes {
+		delete(idx.buckets[t][h], id)
+		if len(idx.buckets[t][h]) == 0 {
+			delete(idx.buckets[t], h)
+		}
+	}
+	delete(idx.hashes, id)
+	delete(idx.vectors, id)
+	return true
+}
+
+// Delete removes a vector from the index
+func (idx *LSHIndex) Delete(id string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.removeLocked(id) {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+	return nil
+}
+
+// UpdateMetadata replaces the metadata, version and timestamp of a stored
+// vector without re-encoding its values
+func (idx *LSHIndex) UpdateMetadata(id string, metadata map[string]interface{}, version uint64, timestamp int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	vec, exists := idx.vectors[id]
+	if !exists {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+
+	updated := *vec
+	updated.Metadata = make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		updated.Metadata[k] = v
+	}
+	updated.Version = version
+	updated.Timestamp = timestamp
+	idx.vectors[id] = &updated
+	return nil
+}
+
+// Scan calls fn for every vector in the index until fn returns false
+func (idx *LSHIndex) Scan(fn func(vector *models.Vector) bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for _, vec := range idx.vectors {
+		if !fn(vec) {
+			return
+		}
+	}
+}
+
+// Size returns the number of vectors in the index
+func (idx *LSHIndex) Size() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.vectors)
+}
+
+// Dimension returns the dimensionality of the index
+func (idx *LSHIndex) Dimension() int {
+	return idx.dimension
+}
+
+// Load always fails: the LSH index cannot be persisted
+func (idx *LSHIndex) Load() error {
+	return errors.New("the LSH index does not support persistence")
+}
+
+// Save always fails: the LSH index cannot be persisted
+func (idx *LSHIndex) Save() error {
+	return errors.New("the LSH index does not support persistence")
+}
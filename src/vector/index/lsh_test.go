@@ -0,0 +1,155 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"course/models"
+)
+
+// newLSHWithLinear builds an LSH index and a matching linear index over data
+func newLSHWithLinear(t testing.TB, data [][]float32, tables, hyperplanes int) (*LSHIndex, *LinearIndex) {
+	dim := len(data[0])
+	lsh, err := NewLSHIndex(dim, models.Cosine, tables, hyperplanes)
+	if err != nil {
+		t.Fatalf("Failed to create LSH index: %v", err)
+	}
+
+	linear, _ := NewLinearIndex(dim, models.Cosine)
+	for i, values := range data {
+		category := "even"
+		if i%2 == 1 {
+			category = "odd"
+		}
+		v := models.NewVector(fmt.Sprintf("v%d", i), values, map[string]interface{}{"category": category})
+		if err := lsh.Insert(v); err != nil {
+			t.Fatalf("LSH insert failed: %v", err)
+		}
+		linear.Insert(v)
+	}
+	return lsh, linear
+}
+
+func TestLSHIndexRecallIncreasesWithTables(t *testing.T) {
+	data := randomVectors(3000, 16, 1)
+	queries := randomVectors(20, 16, 2)
+
+	previous := -1.0
+	for _, tables := range []int{1, 4, 16} {
+		lsh, linear := newLSHWithLinear(t, data, tables, 12)
+		recall := recallAtK(t, linear, lsh, queries, 10, &models.SearchParams{})
+		t.Logf("tables=%d recall@10=%.2f", tables, recall)
+
+		if recall <= previous {
+			t.Errorf("Recall did not improve from %.2f when raising tables to %d (got %.2f)", previous, tables, recall)
+		}
+		previous = recall
+	}
+}
+
+func TestLSHIndexFilterAndDelete(t *testing.T) {
+	data := randomVectors(500, 8, 3)
+	lsh, _ := newLSHWithLinear(t, data, 8, 6)
+
+	filter := models.NewAndFilter(models.NewEqualsCondition("category", "odd"))
+	results, err := lsh.Search(data[1], 10, filter, &models.SearchParams{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) == 0 || results[0].ID != "v1" {
+		t.Fatalf("Expected the query vector itself first, got %+v", results)
+	}
+	for i, res := range results {
+		if res.Vector.Metadata["category"] != "odd" {
+			t.Errorf("Result %s does not match the filter", res.ID)
+		}
+		if i > 0 && res.Score > results[i-1].Score {
+			t.Errorf("Results are not sorted by score")
+		}
+	}
+
+	if err := lsh.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := lsh.Delete("v1"); err == nil {
+		t.Errorf("Expected deleting a missing vector to fail")
+	}
+	results, _ = lsh.Search(data[1], 10, nil, &models.SearchParams{})
+	for _, res := range results {
+		if res.ID == "v1" {
+			t.Errorf("Deleted vector was returned")
+		}
+	}
+	if size := lsh.Size(); size != len(data)-1 {
+		t.Errorf("Expected size %d after delete, got %d", len(data)-1, size)
+	}
+
+	if _, err := NewLSHIndex(8, models.Euclidean, 4, 8); err == nil {
+		t.Errorf("Expected non-cosine metrics to be rejected")
+	}
+}
+
+func BenchmarkLSHSearch(b *testing.B) {
+	data := randomVectors(20000, 64, 1)
+	lsh, linear := newLSHWithLinear(b, data, 8, 16)
+	query := randomVectors(1, 64, 2)[0]
+
+	b.Run("Linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linear.Search(query, 10, nil, &models.SearchParams{})
+		}
+	})
+	b.Run("LSH", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lsh.Search(query, 10, nil, &models.SearchParams{})
+		}
+	})
+}
+
+func TestZeroVectorsFollowCosinePolicy(t *testing.T) {
+	zero := models.NewVector("zero", []float32{0, 0, 0, 0}, nil)
+	other := models.NewVector("other", []float32{1, 2, 3, 4}, nil)
+	expectedScore := models.DistanceToScore(models.ZeroVectorCosineSimilarity, models.Cosine)
+
+	linear, _ := NewLinearIndex(4, models.Cosine)
+	lsh, _ := NewLSHIndex(4, models.Cosine, 4, 8)
+	for name, idx := range map[string]models.VectorIndex{"linear": linear, "lsh": lsh} {
+		if err := idx.BatchInsert([]*models.Vector{zero, other}); err != nil {
+			t.Fatalf("%s: insert failed: %v", name, err)
+		}
+
+		// A stored zero vector against a regular query
+		results, err := idx.Search(other.Values, 2, nil, &models.SearchParams{})
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", name, err)
+		}
+		for _, res := range results {
+			if res.ID == "zero" && (res.Distance != models.ZeroVectorCosineSimilarity || res.Score != expectedScore) {
+				t.Errorf("%s: zero vector got distance %v score %v, expected %v and %v",
+					name, res.Distance, res.Score, models.ZeroVectorCosineSimilarity, expectedScore)
+			}
+		}
+
+		// A zero query against everything
+		results, err = idx.Search(zero.Values, 2, nil, &models.SearchParams{})
+		if err != nil {
+			t.Fatalf("%s: zero query failed: %v", name, err)
+		}
+		for _, res := range results {
+			if res.Distance != models.ZeroVectorCosineSimilarity || res.Score != expectedScore {
+				t.Errorf("%s: zero query scored %s at distance %v score %v, expected %v and %v",
+					name, res.ID, res.Distance, res.Score, models.ZeroVectorCosineSimilarity, expectedScore)
+			}
+		}
+	}
+}
+
+func TestLSHIndexPersistenceUnsupported(t *testing.T) {
+	lsh, _ := newLSHWithLinear(t, randomVectors(10, 4, 5), 2, 4)
+	if err := lsh.Save(); err == nil {
+		t.Errorf("Expected Save to report that persistence is unsupported")
+	}
+	if err := lsh.Load(); err == nil {
+		t.Errorf("Expected Load to report that persistence is unsupported")
+	}
+}
@@ -0,0 +1,341 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"course/models"
+	"course/vector"
+)
+
+// pqTrainIterations bounds the k-means iterations run per subspace
+const pqTrainIterations = 25
+
+// PQIndex is a product-quantization index. Each vector is split into a number
+// of equally sized subspaces and every sub-vector is replaced by the ID of its
+// nearest centroid in that subspace's codebook, so a vector is stored in just
+// one byte per subspace. Searches use asymmetric distance computation: the
+// query is kept exact and compared against the codebooks through per-subspace
+// lookup tables.
+//
+// The index must be trained on a representative sample before vectors can be
+// inserted. Only the PQ codes and metadata are kept, so results carry vectors
+// without values.
+type PQIndex struct {
+	dimension int
+	metric    models.DistanceMetric
+	subspaces int                       // Number of subspaces (bytes per vector)
+	centroids int                       // Centroids per subspace codebook (at most 256)
+	subDim    int                       // Dimension of each subspace
+	codebooks [][][]float32             // [subspace][centroid][subDim]
+	codes     map[string][]uint8        // PQ code of every stored vector
+	vectors   map[string]*models.Vector // Metadata of stored vectors (no values)
+	rng       *rand.Rand
+	mu        sync.RWMutex
+}
+
+// NewPQIndex creates an untrained product-quantization index. The dimension
+// must be divisible by the number of subspaces.
+func NewPQIndex(dimension int, metric models.DistanceMetric, subspaces, centroids int) (*PQIndex, error) {
+	if subspaces <= 0 || dimension%subspaces != 0 {
+		return nil, fmt.Errorf("dimension %d is not divisible into %d subspaces", dimension, subspaces)
+	}
+	if centroids <= 0 || centroids > 256 {
+		return nil, fmt.Errorf("centroids per subspace must be between 1 and 256, got %d", centroids)
+	}
+	switch metric {
+	case models.Cosine, models.DotProduct, models.Euclidean, models.Manhattan:
+	default:
+		return nil, fmt.Errorf("metric %s is not supported by the PQ index", vector.MetricName(metric))
+	}
+
+	return &PQIndex{
+		dimension: dimension,
+		metric:    metric,
+		subspaces: subspaces,
+		centroids: centroids,
+		subDim:    dimension / subspaces,
+		codes:     make(map[string][]uint8),
+		vectors:   make(map[string]*models.Vector),
+		rng:       rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+// Train learns the subspace codebooks by running k-means over the given
+// samples. Retraining discards any vectors already stored.
+func (idx *PQIndex) Train(samples [][]float32) error {
+	if len(samples) == 0 {
+		return errors.New("no training samples")
+	}
+	for i, s := range samples {
+		if len(s) != idx.dimension {
+			return fmt.Errorf("sample %d: dimension %d does not match index dimension %d: %w",
+				i, len(s), idx.dimension, models.ErrDimensionMismatch)
+		}
+	}
+
+	prepared := make([][]float32, len(samples))
+	for i, s := range samples {
+		prepared[i] = idx.prepare(s)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	codebooks := make([][][]float32, idx.subspaces)
+	for s := range codebooks {
+		sub := make([][]float32, len(prepared))
+		for i, p := range prepared {
+			sub[i] = p[s*idx.subDim : (s+1)*idx.subDim]
+		}
+		codebooks[s] = kmeans(sub, idx.centroids, pqTrainIterations, idx.rng)
+	}
+
+	idx.codebooks = codebooks
+	idx.codes = make(map[string][]uint8)
+	idx.vectors = make(map[string]*models.Vector)
+	return nil
+}
+
+// Trained reports whether the codebooks have been learned
+func (idx *PQIndex) Trained() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.codebooks != nil
+}
+
+// prepare returns a copy of values ready for quantization (normalized for cosine)
+func (idx *PQIndex) prepare(values []float32) []float32 {
+	prepared := append([]float32(nil), values...)
+	if idx.metric == models.Cosine {
+		vector.NormalizeVector(prepared)
+	}
+	return prepared
+}
+
+// encode maps a prepared vector to its PQ code. Callers must hold the lock.
+func (idx *PQIndex) encode(values []float32) []uint8 {
+	code := make([]uint8, idx.subspaces)
+	for s := range code {
+		sub := values[s*idx.subDim : (s+1)*idx.subDim]
+		code[s] = uint8(nearestCentroid(sub, idx.codebooks[s]))
+	}
+	return code
+}
+
+// Insert quantizes a vector and adds it to the index
+func (idx *PQIndex) Insert(v *models.Vector) error {
+	if len(v.Values) != idx.dimension {
+		return fmt.Errorf("vector dimension %d does not match index dimension %d: %w",
+			len(v.Values), idx.dimension, models.ErrDimensionMismatch)
+	}
+
+	prepared := idx.prepare(v.Values)
+	meta := v.Copy()
+	meta.Values = nil
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.codebooks == nil {
+		return errors.New("PQ index must be trained before inserting vectors")
+	}
+
+	idx.codes[v.ID] = idx.encode(prepared)
+	idx.vectors[v.ID] = meta
+	return nil
+}
+
+// BatchInsert adds multiple vectors to the index
+func (idx *PQIndex) BatchInsert(vectors []*models.Vector) error {
+	for _, v := range vectors {
+		if err := idx.Insert(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search finds the approximate nearest neighbors using asymmetric distance
+// computation against the stored PQ codes
+func (idx *PQIndex) Search(
+	query []float32,
+	k int,
+	filter *models.MetadataFilter,
+	params *models.SearchParams,
+) ([]models.SearchResult, error) {
+	if len(query) != idx.dimension {
+		return nil, fmt.Errorf("query dimension %d does not match index dimension %d: %w",
+			len(query), idx.dimension, models.ErrDimensionMismatch)
+	}
+	if params != nil && params.Metric != nil && *params.Metric != idx.metric {
+		return nil, errors.New("the PQ index does not support metric overrides")
+	}
+
+	prepared := idx.prepare(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.codebooks == nil {
+		return nil, errors.New("PQ index has not been trained")
+	}
+
+	if k <= 0 {
+		k = 10 // Default to 10 results
+	}
+
+	var scoreThreshold float32
+	if params != nil {
+		scoreThreshold = params.ScoreThreshold
+	}
+
+	tables := idx.distanceTables(prepared)
+	results := make([]models.SearchResult, 0, len(idx.codes))
+	for id, code := range idx.codes {
+		vec := idx.vectors[id]
+		if filter != nil && !filter.MatchVector(vec) {
+			continue
+		}
+
+		var sum float32
+		for s, c := range code {
+			sum += tables[s][c]
+		}
+		distance := sum
+		if idx.metric == models.Euclidean {
+			distance = float32(math.Sqrt(float64(sum)))
+		}
+
+		score := vector.NormalizeScore(distance, idx.metric)
+		if scoreThreshold > 0 && score < scoreThreshold {
+			continue
+		}
+
+		results = append(results, models.SearchResult{
+			ID:       id,
+			Distance: distance,
+			Vector:   vec,
+			Score:    score,
+		})
+	}
+
+	if vector.IsHigherBetter(idx.metric) {
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance > results[j].Distance
+			}
+			return results[i].ID < results[j].ID
+		})
+	} else {
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Distance != results[j].Distance {
+				return results[i].Distance < results[j].Distance
+			}
+			return results[i].ID < results[j].ID
+		})
+	}
+
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// distanceTables precomputes, for every subspace, the partial distance from
+// the query's sub-vector to each centroid. Partial distances are additive:
+// squared L2 for Euclidean, L1 for Manhattan and inner products otherwise.
+// Callers must hold the lock.
+func (idx *PQIndex) distanceTables(query []float32) [][]float32 {
+	tables := make([][]float32, idx.subspaces)
+	for s := range tables {
+		sub := query[s*idx.subDim : (s+1)*idx.subDim]
+		tables[s] = make([]float32, len(idx.codebooks[s]))
+		for c, centroid := range idx.codebooks[s] {
+			switch idx.metric {
+			case models.Euclidean:
+				tables[s][c] = float32(squaredDistance(sub, centroid))
+			case models.Manhattan:
+				tables[s][c] = vector.ManhattanDistance(sub, centroid)
+			default:
+				tables[s][c] = vector.DotProduct(sub, centroid)
+			}
+		}
+	}
+	return tables
+}
+
+// Delete removes a vector from the index
+func (idx *PQIndex) Delete(id string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, exists := idx.codes[id]; !exists {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+	delete(idx.codes, id)
+	delete(idx.vectors, id)
+	return nil
+}
+
+// UpdateMetadata replaces the metadata, version and timestamp of a stored
+// vector without re-encoding its values
+func (idx *PQIndex) UpdateMetadata(id string, metadata map[string]interface{}, version uint64, timestamp int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	vec, exists := idx.vectors[id]
+	if !exists {
+		return fmt.Errorf("vector with ID %s: %w", id, models.ErrVectorNotFound)
+	}
+
+	updated := *vec
+	updated.Metadata = make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		updated.Metadata[k] = v
+	}
+	updated.Version = version
+	updated.Timestamp = timestamp
+	idx.vectors[id] = &updated
+	return nil
+}
+
+// Size returns the number of vectors in the index
+func (idx *PQIndex) Size() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.codes)
+}
+
+// Dimension returns the dimensionality of the index
+func (idx *PQIndex) Dimension() int {
+	return idx.dimension
+}
+
+// MemoryUsage estimates the bytes used by the PQ codes and codebooks, for
+// comparison against the 4 bytes per dimension of uncompressed storage.
+// Metadata is not included.
+func (idx *PQIndex) MemoryUsage() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	bytes := len(idx.codes) * idx.subspaces
+	for _, codebook := range idx.codebooks {
+		bytes += len(codebook) * idx.subDim * 4
+	}
+	return bytes
+}
+
+// Load always fails: the PQ index cannot be persisted
+func (idx *PQIndex) Load() error {
+	return errors.New("the PQ index does not support persistence")
+}
+
+// Save always fails: the PQ index cannot be persisted
+func (idx *PQIndex) Save() error {
+	return errors.New("the PQ index does not support persistence")
+}
@@ -0,0 +1,138 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"course/models"
+	"course/vector"
+)
+
+// randomVectors generates n deterministic pseudo-random vectors in [-1,1)
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return vectors
+}
+
+// recallAtK returns the average fraction of the exact top-k neighbors (from a
+// linear index) that the approximate index also returns
+func recallAtK(t *testing.T, exact, approx models.VectorIndex, queries [][]float32, k int, params *models.SearchParams) float64 {
+	eval, err := vector.EvaluateIndex(exact, approx, queries, k, params)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	return eval.Recall
+}
+
+func TestPQIndexRecall(t *testing.T) {
+	dim := 16
+	data := randomVectors(2000, dim, 1)
+
+	pq, err := NewPQIndex(dim, models.Euclidean, 8, 64)
+	if err != nil {
+		t.Fatalf("Failed to create PQ index: %v", err)
+	}
+	if err := pq.Insert(models.NewVector("early", data[0], nil)); err == nil {
+		t.Errorf("Expected insert before training to fail")
+	}
+	if err := pq.Train(data); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	linear, _ := NewLinearIndex(dim, models.Euclidean)
+	for i, values := range data {
+		v := models.NewVector(fmt.Sprintf("v%d", i), values, nil)
+		if err := pq.Insert(v); err != nil {
+			t.Fatalf("PQ insert failed: %v", err)
+		}
+		linear.Insert(v)
+	}
+
+	recall := recallAtK(t, linear, pq, randomVectors(20, dim, 2), 10, nil)
+	if recall < 0.5 {
+		t.Errorf("Expected recall@10 of at least 0.5, got %.2f", recall)
+	}
+
+	// 8 bytes per vector plus codebooks, versus 64 bytes uncompressed
+	if usage, raw := pq.MemoryUsage(), len(data)*dim*4; usage*4 > raw {
+		t.Errorf("Expected at least 4x compression, got %d bytes vs %d raw", usage, raw)
+	}
+	t.Logf("recall@10=%.2f memory=%d bytes (raw %d bytes)", recall, pq.MemoryUsage(), len(data)*dim*4)
+}
+
+func TestPQIndexFilterAndDelete(t *testing.T) {
+	pq, err := NewPQIndex(4, models.Cosine, 2, 4)
+	if err != nil {
+		t.Fatalf("Failed to create PQ index: %v", err)
+	}
+	vectors := []*models.Vector{
+		models.NewVector("v1", []float32{1, 0, 0, 0}, map[string]interface{}{"category": "A"}),
+		models.NewVector("v2", []float32{0.9, 0.1, 0, 0}, map[string]interface{}{"category": "B"}),
+		models.NewVector("v3", []float32{0, 0, 1, 0}, map[string]interface{}{"category": "A"}),
+	}
+	samples := make([][]float32, len(vectors))
+	for i, v := range vectors {
+		samples[i] = v.Values
+	}
+	if err := pq.Train(samples); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if err := pq.BatchInsert(vectors); err != nil {
+		t.Fatalf("Batch insert failed: %v", err)
+	}
+
+	filter := models.NewAndFilter(models.NewEqualsCondition("category", "B"))
+	results, err := pq.Search([]float32{1, 0, 0, 0}, 10, filter, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "v2" {
+		t.Errorf("Expected only v2 to match the filter, got %+v", results)
+	}
+
+	if err := pq.Delete("v1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if size := pq.Size(); size != 2 {
+		t.Errorf("Expected size 2 after delete, got %d", size)
+	}
+}
+
+func TestPQIndexTiesAndPersistence(t *testing.T) {
+	pq, err := NewPQIndex(2, models.Euclidean, 1, 2)
+	if err != nil {
+		t.Fatalf("Failed to create PQ index: %v", err)
+	}
+	if err := pq.Train([][]float32{{1, 0}, {0, 1}}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// Identical vectors encode to the same code, so their distances tie
+	for _, id := range []string{"c", "a", "b"} {
+		if err := pq.Insert(models.NewVector(id, []float32{1, 0}, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	results, err := pq.Search([]float32{1, 0}, 2, nil, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Errorf("Expected ties to be broken by ID, got %+v", results)
+	}
+
+	if err := pq.Save(); err == nil {
+		t.Errorf("Expected Save to report that persistence is unsupported")
+	}
+	if err := pq.Load(); err == nil {
+		t.Errorf("Expected Load to report that persistence is unsupported")
+	}
+}
@@ -2,18 +2,35 @@ package query
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"course/buildinfo"
 	"course/models"
+	"course/vector"
+	"course/vector/index"
 )
 
 // API provides a RESTful interface to the vector store
 type API struct {
+	mu          sync.RWMutex // Guards collections and processors
 	collections map[string]*models.VectorCollection
 	processors  map[string]*Processor
+	readOnly    int32 // Non-zero when writes are refused (accessed atomically)
+	maxBodyBytes int64 // Largest accepted request body (0 = unlimited)
+	searchSlots chan struct{} // Semaphore bounding concurrent searches (nil = unlimited)
+	logger      *log.Logger // Destination of request logs (nil = standard logger)
+	compaction  compactionState // Tombstone compaction settings and totals
 }
 
 // NewAPI creates a new API instance
@@ -21,20 +38,301 @@ func NewAPI() *API {
 	return &API{
 		collections: make(map[string]*models.VectorCollection),
 		processors:  make(map[string]*Processor),
+		compaction:  compactionState{retention: DefaultCompactionRetention},
 	}
 }
 
 // RegisterCollection adds a collection to the API
 func (api *API) RegisterCollection(collection *models.VectorCollection) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.registerLocked(collection)
+}
+
+// registerLocked adds a collection and its processor. Callers must hold the
+// write lock.
+func (api *API) registerLocked(collection *models.VectorCollection) {
 	api.collections[collection.Name] = collection
 	api.processors[collection.Name] = NewProcessor(collection)
 }
 
+// registerNew adds the collections only if none of their names is taken,
+// returning the first name in use otherwise
+func (api *API) registerNew(collections []*models.VectorCollection) (taken string, ok bool) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	
+	for _, collection := range collections {
+		if _, exists := api.collections[collection.Name]; exists {
+			return collection.Name, false
+		}
+	}
+	for _, collection := range collections {
+		api.registerLocked(collection)
+	}
+	return "", true
+}
+
+// exists reports whether a collection of the given name is registered
+func (api *API) exists(name string) bool {
+	_, _, exists := api.lookup(name)
+	return exists
+}
+
+// lookup returns the named collection and its processor
+func (api *API) lookup(name string) (*models.VectorCollection, *Processor, bool) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	collection, exists := api.collections[name]
+	return collection, api.processors[name], exists
+}
+
+// snapshot returns the registered collections sorted by name, so callers can
+// work through them without holding the lock
+func (api *API) snapshot() []*models.VectorCollection {
+	api.mu.RLock()
+	collections := make([]*models.VectorCollection, 0, len(api.collections))
+	for _, collection := range api.collections {
+		collections = append(collections, collection)
+	}
+	api.mu.RUnlock()
+	
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections
+}
+
+// SetReadOnly enables or disables read-only mode. While enabled, every
+// endpoint that modifies collections or vectors responds with 403.
+func (api *API) SetReadOnly(readOnly bool) {
+	var value int32
+	if readOnly {
+		value = 1
+	}
+	atomic.StoreInt32(&api.readOnly, value)
+}
+
+// ReadOnly reports whether the API is refusing writes
+func (api *API) ReadOnly() bool {
+	return atomic.LoadInt32(&api.readOnly) != 0
+}
+
+// SetMaxBodyBytes limits the size of request bodies. Larger requests are
+// rejected with 413. Zero or less disables the limit. It must be called
+// before SetupRoutes.
+func (api *API) SetMaxBodyBytes(n int64) {
+	api.maxBodyBytes = n
+}
+
+// SetMaxConcurrentSearches limits how many searches (queries,
+// recommendations, arithmetic, dedup and similarity matrices) run at once.
+// Searches beyond the limit are rejected with 503 and a Retry-After header
+// instead of queueing. Zero or less removes the limit. It must be called
+// before serving requests.
+func (api *API) SetMaxConcurrentSearches(n int) {
+	if n <= 0 {
+		api.searchSlots = nil
+		return
+	}
+	api.searchSlots = make(chan struct{}, n)
+}
+
+// searchRetryAfter is the Retry-After, in seconds, sent with searches
+// rejected by the concurrency limit
+const searchRetryAfter = 1
+
+// acquireSearch takes a search slot, responding with 503 if none is free.
+// It returns false if the caller must stop handling the request; otherwise
+// the caller must call release once the search is done.
+func (api *API) acquireSearch(w http.ResponseWriter) (release func(), ok bool) {
+	slots := api.searchSlots
+	if slots == nil {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(searchRetryAfter))
+		writeError(w, http.StatusServiceUnavailable, CodeOverloaded, "Too many concurrent searches")
+		return nil, false
+	}
+}
+
 // SetupRoutes configures HTTP routes for the API
 func (api *API) SetupRoutes(mux *http.ServeMux) {
 	// Collection management
-	mux.HandleFunc("/collections", api.handleCollections)
-	mux.HandleFunc("/collections/", api.handleCollectionOperations)
+	mux.HandleFunc("/collections", api.limitBody(api.handleCollections))
+	mux.HandleFunc("/collections/", api.limitBody(api.handleCollectionOperations))
+	
+	// Ad-hoc vector utilities
+	mux.HandleFunc("/similarity/matrix", api.limitBody(api.handleSimilarityMatrix))
+	
+	// Node administration
+	mux.HandleFunc("/admin/readonly", api.limitBody(api.handleReadOnly))
+	mux.HandleFunc("/version", api.handleVersion)
+	mux.HandleFunc("/admin/compact", api.handleCompact)
+	mux.HandleFunc("/stats", api.handleStats)
+}
+
+// limitBody enforces the maximum body size on a handler. Requests that
+// declare a larger Content-Length are rejected up front; others read through
+// http.MaxBytesReader, which fails once the limit is passed.
+func (api *API) limitBody(handler http.HandlerFunc) http.HandlerFunc {
+	limit := api.maxBodyBytes
+	if limit <= 0 {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		handler(w, r)
+	}
+}
+
+// errBodyTooLarge is the error message of http.MaxBytesReader
+const errBodyTooLarge = "http: request body too large"
+
+// invalidBody reports a request body that could not be decoded, using 413 if
+// it was cut off by the body size limit
+func invalidBody(w http.ResponseWriter, err error) {
+	if err != nil && strings.Contains(err.Error(), errBodyTooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Request body too large")
+		return
+	}
+	writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+}
+
+// handleReadOnly reports (GET) or toggles (POST) read-only mode
+func (api *API) handleReadOnly(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var request struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			invalidBody(w, err)
+			return
+		}
+		api.SetReadOnly(request.Enabled)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"read_only": api.ReadOnly(),
+		"status":    "ok",
+	})
+}
+
+// replicationFactor is the number of copies kept of each vector. Collections
+// live on a single node, which holds the only copy.
+const replicationFactor = 1
+
+// handleVersion reports the build of the running node and how its
+// collections are configured
+func (api *API) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	registered := api.snapshot()
+	collections := make([]map[string]interface{}, len(registered))
+	for i, collection := range registered {
+		collections[i] = map[string]interface{}{
+			"name":      collection.Name,
+			"dimension": collection.Dimension,
+			"metric":    vector.MetricName(collection.DistanceFunc),
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":            buildinfo.Version,
+		"commit":             buildinfo.Commit,
+		"build_time":         buildinfo.BuildTime,
+		"go_version":         runtime.Version(),
+		"collections":        collections,
+		"replication_factor": replicationFactor,
+		"status":             "ok",
+	})
+}
+
+// checkWritable rejects the request with 403 if the API is in read-only mode,
+// returning false if the caller must stop handling the request
+func (api *API) checkWritable(w http.ResponseWriter) bool {
+	if api.ReadOnly() {
+		writeError(w, http.StatusForbidden, CodeReadOnly, "Node is in read-only mode")
+		return false
+	}
+	return true
+}
+
+// maxMatrixVectors caps the number of vectors accepted by /similarity/matrix,
+// since the response grows quadratically with the input
+const maxMatrixVectors = 256
+
+// handleSimilarityMatrix computes the pairwise distance matrix of the posted vectors
+func (api *API) handleSimilarityMatrix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var request struct {
+		Vectors [][]float32 `json:"vectors"`
+		Metric  string      `json:"metric"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	if len(request.Vectors) == 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "At least one vector is required")
+		return
+	}
+	
+	if len(request.Vectors) > maxMatrixVectors {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Too many vectors: %d exceeds the limit of %d",
+			len(request.Vectors), maxMatrixVectors))
+		return
+	}
+	
+	metric := models.Cosine
+	if request.Metric != "" {
+		var ok bool
+		if metric, ok = parseMetric(request.Metric); !ok {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Unknown metric %s", request.Metric))
+			return
+		}
+	}
+	
+	release, ok := api.acquireSearch(w)
+	if !ok {
+		return
+	}
+	defer release()
+	
+	matrix, err := vector.DistanceMatrix(request.Vectors, metric)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matrix": matrix,
+		"metric": metric.String(),
+		"status": "ok",
+	})
 }
 
 // handleCollections handles requests to /collections
@@ -47,7 +345,7 @@ func (api *API) handleCollections(w http.ResponseWriter, r *http.Request) {
 		// Create a new collection
 		api.createCollection(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 	}
 }
 
@@ -58,14 +356,24 @@ func (api *API) handleCollectionOperations(w http.ResponseWriter, r *http.Reques
 	parts := strings.SplitN(path, "/", 2)
 	
 	if len(parts) == 0 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid path")
+		return
+	}
+	
+	// Bulk collection management
+	if len(parts) == 1 && parts[0] == "bulk" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+		api.bulkCreateCollections(w, r)
 		return
 	}
 	
 	collectionName := parts[0]
-	collection, exists := api.collections[collectionName]
+	collection, _, exists := api.lookup(collectionName)
 	if !exists {
-		http.Error(w, fmt.Sprintf("Collection %s not found", collectionName), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", collectionName))
 		return
 	}
 	
@@ -80,7 +388,7 @@ func (api *API) handleCollectionOperations(w http.ResponseWriter, r *http.Reques
 			// Delete collection
 			api.deleteCollection(w, r, collectionName)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		}
 		return
 	}
@@ -100,18 +408,61 @@ func (api *API) handleCollectionOperations(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	http.Error(w, "Resource not found", http.StatusNotFound)
+	// Faceting over a metadata field
+	if resource == "facets" {
+		api.facets(w, r, collection)
+		return
+	}
+	
+	// Counting the vectors that match a filter
+	if resource == "count" {
+		api.count(w, r, collection)
+		return
+	}
+	
+	// Numeric aggregation over a metadata field
+	if resource == "aggregate" {
+		api.aggregate(w, r, collection)
+		return
+	}
+	
+	// Fitting a PCA projection to reduce the stored dimension
+	if resource == "fit-pca" {
+		api.fitPCA(w, r, collection)
+		return
+	}
+	
+	// Finding (and optionally removing) near-duplicate vectors
+	if resource == "dedup" {
+		api.dedup(w, r, collection)
+		return
+	}
+	
+	// Reading or replacing the metadata schema
+	if resource == "schema" {
+		api.schema(w, r, collection)
+		return
+	}
+	
+	// Recommendation by examples
+	if resource == "recommend" {
+		api.recommend(w, r, collectionName)
+		return
+	}
+	
+	writeError(w, http.StatusNotFound, CodeNotFound, "Resource not found")
 }
 
 // listCollections returns a list of all collections
 func (api *API) listCollections(w http.ResponseWriter, r *http.Request) {
-	collections := make([]map[string]interface{}, 0, len(api.collections))
+	registered := api.snapshot()
+	collections := make([]map[string]interface{}, 0, len(registered))
 	
-	for name, coll := range api.collections {
+	for _, coll := range registered {
 		collections = append(collections, map[string]interface{}{
-			"name":      name,
+			"name":      coll.Name,
 			"dimension": coll.Dimension,
-			"metric":    coll.DistanceFunc.String(),
+			"metric":    vector.MetricName(coll.DistanceFunc),
 			"vectors":   coll.Size(),
 		})
 	}
@@ -123,94 +474,310 @@ func (api *API) listCollections(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// createCollection creates a new vector collection
-func (api *API) createCollection(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Name      string `json:"name"`
-		Dimension int    `json:"dimension"`
-		Metric    string `json:"metric"`
+// collectionSpec describes a collection to create
+type collectionSpec struct {
+	Name      string            `json:"name"`
+	Dimension int               `json:"dimension"`
+	Metric    string            `json:"metric"`
+	Schema    map[string]string `json:"schema"`  // Field name -> field type
+	Indexes   []indexSpec       `json:"indexes"` // Indexes to build on the collection
+	MaxVectors int              `json:"max_vectors"` // Capacity limit (0 = unlimited)
+	AutoGenerateID bool         `json:"auto_generate_id"` // Assign UUIDs to vectors without an ID
+	RejectZeroVectors bool      `json:"reject_zero_vectors"` // Refuse all-zero vectors
+	AllowedMetadataKeys []string `json:"allowed_metadata_keys"` // Accepted metadata keys (empty = any)
+	IndexedFields []string       `json:"indexed_fields"` // Metadata fields with an inverted index for filtering
+	MinScore float32             `json:"min_score"` // Default score floor for searches (0 = none)
+	IdempotencyTTLSeconds int    `json:"idempotency_ttl_seconds"` // How long bulk upsert keys are remembered (0 = default)
+	SearchCacheSize int         `json:"search_cache_size"` // Cached search results (0 = disabled)
+	RecallSampleRate float64    `json:"recall_sample_rate"` // Fraction of searches checked against an exact scan (0 = disabled)
+	DistancePrecision string    `json:"distance_precision"` // "float32" (default) or "float64" accumulation
+}
+
+// indexSpec describes an index to add to a new collection
+type indexSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // Currently only "linear"
+}
+
+// buildCollection validates a collection spec and constructs the collection
+// it describes, without registering it
+func buildCollection(spec collectionSpec) (*models.VectorCollection, error) {
+	if spec.Name == "" {
+		return nil, errors.New("Name is required")
 	}
 	
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	// Parse metric, defaulting to cosine when none is given
+	metric := models.Cosine
+	if spec.Metric != "" {
+		var ok bool
+		if metric, ok = parseMetric(spec.Metric); !ok {
+			return nil, fmt.Errorf("unknown metric %s", spec.Metric)
+		}
+	}
+	
+	if spec.MaxVectors < 0 {
+		return nil, errors.New("max_vectors cannot be negative")
+	}
+	if spec.RecallSampleRate < 0 || spec.RecallSampleRate > 1 {
+		return nil, errors.New("recall_sample_rate must be between 0 and 1")
+	}
+	if spec.MinScore < 0 || spec.MinScore > 1 {
+		return nil, errors.New("min_score must be between 0 and 1")
+	}
+	precision, err := vector.ParsePrecision(spec.DistancePrecision)
+	if err != nil {
+		return nil, err
+	}
+	
+	collection := models.NewVectorCollection(spec.Name, spec.Dimension, metric)
+	if err := collection.Validate(); err != nil {
+		return nil, err
+	}
+	collection.MaxVectors = spec.MaxVectors
+	collection.AutoGenerateID = spec.AutoGenerateID
+	collection.RejectZeroVectors = spec.RejectZeroVectors
+	collection.AllowedMetadataKeys = spec.AllowedMetadataKeys
+	collection.MinScore = spec.MinScore
+	if spec.IdempotencyTTLSeconds > 0 {
+		collection.SetIdempotencyTTL(time.Duration(spec.IdempotencyTTLSeconds) * time.Second)
+	}
+	collection.EnableSearchCache(spec.SearchCacheSize)
+	for _, field := range spec.IndexedFields {
+		if field == "" {
+			return nil, errors.New("indexed field names cannot be empty")
+		}
+	}
+	if err := collection.SetIndexedFields(spec.IndexedFields); err != nil {
+		return nil, err
+	}
+	
+	for field, typeName := range spec.Schema {
+		fieldType, err := models.ParseFieldType(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("schema field %s: %w", field, err)
+		}
+		collection.MetadataSchema.AddField(field, fieldType)
+	}
+	
+	for _, indexConfig := range spec.Indexes {
+		if indexConfig.Name == "" {
+			return nil, errors.New("Index name is required")
+		}
+		
+		var vectorIndex models.VectorIndex
+		switch strings.ToLower(indexConfig.Type) {
+		case "linear", "":
+			config := index.DefaultLinearIndexConfig()
+			config.Precision = precision
+			linearIndex, err := index.NewLinearIndexWithConfig(spec.Dimension, metric, config)
+			if err != nil {
+				return nil, err
+			}
+			vectorIndex = linearIndex
+		default:
+			return nil, fmt.Errorf("unsupported index type %s", indexConfig.Type)
+		}
+		
+		if err := collection.AddIndex(indexConfig.Name, vectorIndex); err != nil {
+			return nil, err
+		}
 	}
 	
-	// Validate request
-	if request.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	return collection, nil
+}
+
+// createCollection creates a new vector collection
+func (api *API) createCollection(w http.ResponseWriter, r *http.Request) {
+	if !api.checkWritable(w) {
 		return
 	}
 	
-	if request.Dimension <= 0 {
-		http.Error(w, "Dimension must be positive", http.StatusBadRequest)
+	var request collectionSpec
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
 		return
 	}
 	
 	// Check if collection already exists
-	if _, exists := api.collections[request.Name]; exists {
-		http.Error(w, fmt.Sprintf("Collection %s already exists", request.Name), http.StatusConflict)
-		return
-	}
-	
-	// Parse metric
-	var metric models.DistanceMetric
-	switch strings.ToLower(request.Metric) {
-	case "cosine":
-		metric = models.Cosine
-	case "dotproduct", "dot_product", "dot":
-		metric = models.DotProduct
-	case "euclidean", "euclid", "l2":
-		metric = models.Euclidean
-	case "manhattan", "taxicab", "cityblock", "l1":
-		metric = models.Manhattan
-	default:
-		metric = models.Cosine // Default to cosine
+	if _, _, exists := api.lookup(request.Name); exists {
+		writeError(w, http.StatusConflict, CodeConflict, fmt.Sprintf("Collection %s already exists", request.Name))
+		return
 	}
 	
 	// Create collection
-	collection := models.NewVectorCollection(request.Name, request.Dimension, metric)
-	api.RegisterCollection(collection)
+	collection, err := buildCollection(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if _, ok := api.registerNew([]*models.VectorCollection{collection}); !ok {
+		writeError(w, http.StatusConflict, CodeConflict, fmt.Sprintf("Collection %s already exists", request.Name))
+		return
+	}
+	api.monitorRecall(collection.Name, request.RecallSampleRate)
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"name":      collection.Name,
 		"dimension": collection.Dimension,
-		"metric":    collection.DistanceFunc.String(),
+		"metric":    vector.MetricName(collection.DistanceFunc),
 		"status":    "created",
 	})
 }
 
+// monitorRecall samples the given fraction of the collection's searches for
+// the search_recall gauge
+func (api *API) monitorRecall(name string, rate float64) {
+	if _, processor, exists := api.lookup(name); exists && rate > 0 {
+		processor.SetRecallMonitor(NewRecallMonitor(rate, DefaultRecallWindow))
+	}
+}
+
+// bulkCreateCollections creates several collections at once. Creation is
+// all-or-nothing: if any spec is invalid, none of the collections are created.
+func (api *API) bulkCreateCollections(w http.ResponseWriter, r *http.Request) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	var request struct {
+		Collections []collectionSpec `json:"collections"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	if len(request.Collections) == 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "At least one collection is required")
+		return
+	}
+	
+	// Validate and build everything before registering anything
+	built := make([]*models.VectorCollection, len(request.Collections))
+	statuses := make([]map[string]interface{}, len(request.Collections))
+	names := make(map[string]bool, len(request.Collections))
+	failed := false
+	
+	for i, spec := range request.Collections {
+		var err error
+		switch {
+		case names[spec.Name]:
+			err = fmt.Errorf("Collection %s is specified more than once", spec.Name)
+		case api.exists(spec.Name):
+			err = fmt.Errorf("Collection %s already exists", spec.Name)
+		default:
+			built[i], err = buildCollection(spec)
+		}
+		names[spec.Name] = true
+		
+		statuses[i] = map[string]interface{}{"name": spec.Name, "status": "valid"}
+		if err != nil {
+			statuses[i]["status"] = "invalid"
+			statuses[i]["error"] = err.Error()
+			failed = true
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	
+	if failed {
+		for _, status := range statuses {
+			if status["status"] == "valid" {
+				status["status"] = "skipped"
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": errorDetail{
+				Code:    CodeInvalidRequest,
+				Message: "One or more collection specs are invalid",
+			},
+			"collections": statuses,
+			"status":      "error",
+		})
+		return
+	}
+	
+	if taken, ok := api.registerNew(built); !ok {
+		writeError(w, http.StatusConflict, CodeConflict, fmt.Sprintf("Collection %s already exists", taken))
+		return
+	}
+	for i, collection := range built {
+		api.monitorRecall(collection.Name, request.Collections[i].RecallSampleRate)
+		statuses[i]["status"] = "created"
+	}
+	
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"collections": statuses,
+		"status":      "created",
+	})
+}
+
+// parseMetric resolves a distance metric from its user-facing name, including
+// any custom metrics registered with vector.RegisterMetric
+func parseMetric(name string) (models.DistanceMetric, bool) {
+	if metric, ok := vector.LookupMetric(name); ok {
+		return metric, true
+	}
+	return models.Cosine, false
+}
+
 // getCollection returns information about a collection
 func (api *API) getCollection(w http.ResponseWriter, r *http.Request, name string) {
-	collection, exists := api.collections[name]
+	collection, processor, exists := api.lookup(name)
 	if !exists {
-		http.Error(w, fmt.Sprintf("Collection %s not found", name), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", name))
 		return
 	}
 	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	info := map[string]interface{}{
 		"name":      collection.Name,
 		"dimension": collection.Dimension,
-		"metric":    collection.DistanceFunc.String(),
+		"index_dimension": collection.IndexDimension(),
+		"metric":    vector.MetricName(collection.DistanceFunc),
 		"vectors":   collection.Size(),
+		"indexes":   collection.IndexSizes(),
+		"max_vectors": collection.MaxVectors,
+		"min_score": collection.MinScore,
 		"status":    "ok",
-	})
+	}
+	if fields := collection.IndexedFields(); len(fields) > 0 {
+		info["indexed_fields"] = fields
+		info["indexed_filter_searches"] = collection.IndexedFilterSearches()
+	}
+	if monitor := processor.RecallMonitor(); monitor != nil {
+		recall, samples := monitor.Recall()
+		info["recall_samples"] = samples
+		if samples > 0 {
+			info["search_recall"] = recall
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
 }
 
 // deleteCollection removes a collection
 func (api *API) deleteCollection(w http.ResponseWriter, r *http.Request, name string) {
-	// Check if collection exists
-	if _, exists := api.collections[name]; !exists {
-		http.Error(w, fmt.Sprintf("Collection %s not found", name), http.StatusNotFound)
+	if !api.checkWritable(w) {
 		return
 	}
 	
-	// Delete collection
+	// Check if collection exists, and delete it
+	api.mu.Lock()
+	_, exists := api.collections[name]
 	delete(api.collections, name)
 	delete(api.processors, name)
+	api.mu.Unlock()
+	if !exists {
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", name))
+		return
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -228,21 +795,41 @@ func (api *API) handleVectorOperations(w http.ResponseWriter, r *http.Request, c
 		case http.MethodPost:
 			api.batchInsertVectors(w, r, collection)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		}
 		return
 	}
 	
+	// Handle idempotent bulk upserts
+	if len(parts) == 1 && parts[0] == "upsert" && r.Method == http.MethodPost {
+		api.bulkUpsertVectors(w, r, collection)
+		return
+	}
+	
+	// Handle batch deletion
+	if len(parts) == 1 && parts[0] == "delete" && r.Method == http.MethodPost {
+		api.deleteVectors(w, r, collection)
+		return
+	}
+	
+	// Handle vector arithmetic (analogy) searches
+	if len(parts) == 1 && parts[0] == "arithmetic" && r.Method == http.MethodPost {
+		api.arithmetic(w, r, collection)
+		return
+	}
+	
 	// Handle operations on a specific vector
 	if len(parts) == 1 && parts[0] != "" {
 		vectorID := parts[0]
 		switch r.Method {
 		case http.MethodGet:
 			api.getVector(w, r, collection, vectorID)
+		case http.MethodPatch:
+			api.updateVectorMetadata(w, r, collection, vectorID)
 		case http.MethodDelete:
 			api.deleteVector(w, r, collection, vectorID)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		}
 		return
 	}
@@ -253,25 +840,31 @@ func (api *API) handleVectorOperations(w http.ResponseWriter, r *http.Request, c
 		// List vectors (with pagination)
 		api.listVectors(w, r, collection)
 	case http.MethodPost, http.MethodPut:
-		// Add or update a vector
+		// Add (POST) or add-or-replace (PUT) a vector
 		api.upsertVector(w, r, collection)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 	}
 }
 
 // handleQueryOperations handles query operations
 func (api *API) handleQueryOperations(w http.ResponseWriter, r *http.Request, collectionName, path string) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 	
-	processor, exists := api.processors[collectionName]
+	_, processor, exists := api.lookup(collectionName)
 	if !exists {
-		http.Error(w, fmt.Sprintf("Collection %s not found", collectionName), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", collectionName))
+		return
+	}
+	
+	release, ok := api.acquireSearch(w)
+	if !ok {
 		return
 	}
+	defer release()
 	
 	parts := strings.Split(strings.Trim(path, "/"), "/")
 	
@@ -287,6 +880,12 @@ func (api *API) handleQueryOperations(w http.ResponseWriter, r *http.Request, co
 		return
 	}
 	
+	// Handle query plans
+	if len(parts) == 1 && parts[0] == "explain" {
+		api.explainQuery(w, r, processor)
+		return
+	}
+	
 	// Handle regular query
 	api.query(w, r, processor)
 }
@@ -296,23 +895,98 @@ func (api *API) query(w http.ResponseWriter, r *http.Request, processor *Process
 	var request models.QueryRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidBody(w, err)
 		return
 	}
 	
-	// Process the query
+	if timeout := r.URL.Query().Get("timeout_ms"); timeout != "" {
+		ms, err := strconv.Atoi(timeout)
+		if err != nil || ms <= 0 {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "timeout_ms must be a positive integer")
+			return
+		}
+		request.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	
+	// Process the query
 	results, err := processor.ProcessQuery(&request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeCollectionError(w, err)
+		return
+	}
+	partial := request.Partial
+	
+	// Write the results one per line if the client asked for it
+	if searchResults, ok := results.([]models.SearchResult); ok && wantsNDJSON(r) {
+		if partial {
+			w.Header().Set("X-Partial-Results", "true")
+		}
+		writeNDJSON(w, searchResults)
 		return
 	}
 	
 	// Return the results
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"result": results,
 		"status": "ok",
-	})
+	}
+	if partial {
+		response["partial"] = true
+	}
+	if request.Vector != nil {
+		response["total_scanned"] = request.Scanned
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// errorStatus maps an error from the collection layer to an HTTP status code
+// using the sentinel errors defined in models. Client errors wrap one of them
+// (ErrInvalidArgument if nothing more specific applies), so uncategorized
+// errors are server failures.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrVectorNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrDuplicateID):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, models.ErrCapacityExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, models.ErrSchemaViolation), errors.Is(err, models.ErrAlreadyProjected):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrDimensionMismatch), errors.Is(err, models.ErrInvalidID),
+		errors.Is(err, models.ErrZeroVector), errors.Is(err, models.ErrInvalidArgument),
+		errors.Is(err, models.ErrInvalidSearchParams):
+		return http.StatusBadRequest
+	case errors.Is(err, errNotImplemented):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ndjsonContentType is the media type for newline-delimited JSON
+const ndjsonContentType = "application/x-ndjson"
+
+// wantsNDJSON reports whether the client accepts newline-delimited JSON
+func wantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// writeNDJSON writes each search result as its own JSON line. The top k are
+// only known once the search completes, so the results are not streamed as
+// they are found; the format just lets clients decode them one at a time.
+func writeNDJSON(w http.ResponseWriter, results []models.SearchResult) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	encoder := json.NewEncoder(w)
+	
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return // Client went away
+		}
+	}
 }
 
 // batchQuery handles batch queries
@@ -322,19 +996,19 @@ func (api *API) batchQuery(w http.ResponseWriter, r *http.Request, processor *Pr
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidBody(w, err)
 		return
 	}
 	
-	// Process each query
-	results := make([]interface{}, len(request.Searches))
-	for i, search := range request.Searches {
-		result, err := processor.ProcessQuery(&search)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		results[i] = result
+	// Process the queries together, so batch searches share one scan
+	searches := make([]*models.QueryRequest, len(request.Searches))
+	for i := range request.Searches {
+		searches[i] = &request.Searches[i]
+	}
+	results, err := processor.ProcessBatchQuery(searches)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
 	}
 	
 	// Return the results
@@ -345,25 +1019,47 @@ func (api *API) batchQuery(w http.ResponseWriter, r *http.Request, processor *Pr
 	})
 }
 
+// explainQuery reports the plan a query would follow without running it
+func (api *API) explainQuery(w http.ResponseWriter, r *http.Request, processor *Processor) {
+	var request models.QueryRequest
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	plan, err := processor.Explain(&request)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plan":   plan,
+		"status": "ok",
+	})
+}
+
 // groupsQuery handles queries with grouping
 func (api *API) groupsQuery(w http.ResponseWriter, r *http.Request, processor *Processor) {
 	var request models.QueryRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidBody(w, err)
 		return
 	}
 	
 	// Ensure GroupBy is set
 	if request.GroupBy == "" {
-		http.Error(w, "GroupBy is required for group queries", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "GroupBy is required for group queries")
 		return
 	}
 	
 	// Process the query
 	results, err := processor.ProcessQuery(&request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeCollectionError(w, err)
 		return
 	}
 	
@@ -375,45 +1071,747 @@ func (api *API) groupsQuery(w http.ResponseWriter, r *http.Request, processor *P
 	})
 }
 
-// The following methods are stubs for vector operations - they would need to be implemented
-// in a real application
+// maxFacetValues caps the number of distinct values returned by /facets
+const maxFacetValues = 100
 
-func (api *API) upsertVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+// facets returns the distinct values of a metadata field with their counts,
+// most frequent first
+func (api *API) facets(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Field parameter is required")
+		return
+	}
+	
+	limit := maxFacetValues
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxFacetValues {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit parameter")
+			return
+		}
+	}
+	
+	counts, err := collection.DistinctValues(field)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	values := make([]map[string]interface{}, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, map[string]interface{}{
+			"value": value,
+			"count": count,
+		})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		ci, cj := values[i]["count"].(int), values[j]["count"].(int)
+		if ci != cj {
+			return ci > cj
+		}
+		return fmt.Sprint(values[i]["value"]) < fmt.Sprint(values[j]["value"])
+	})
+	
+	truncated := len(values) > limit
+	if truncated {
+		values = values[:limit]
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"field":     field,
+		"values":    values,
+		"truncated": truncated,
+		"status":    "ok",
+	})
+}
+
+// count returns the number of vectors matching an optional filter
+func (api *API) count(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var request struct {
+		Filter *models.MetadataFilter `json:"filter"`
+	}
+	
+	// An empty body counts every vector
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		invalidBody(w, err)
+		return
+	}
+	
+	count, err := collection.Count(request.Filter)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":  count,
+		"status": "ok",
+	})
+}
+
+// defaultPCASampleSize is the number of stored vectors PCA is fitted on when
+// the request does not say
+const defaultPCASampleSize = 10000
+
+// fitPCA fits a PCA projection to target_dim dimensions on a sample of the
+// stored vectors and rebuilds the collection's indexes in the reduced space
+func (api *API) fitPCA(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	targetDim, err := strconv.Atoi(r.URL.Query().Get("target_dim"))
+	if err != nil || targetDim <= 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "target_dim must be a positive integer")
+		return
+	}
+	sampleSize := defaultPCASampleSize
+	if raw := r.URL.Query().Get("sample_size"); raw != "" {
+		if sampleSize, err = strconv.Atoi(raw); err != nil || sampleSize <= 0 {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "sample_size must be a positive integer")
+			return
+		}
+	}
+	
+	if collection.Projected() {
+		writeError(w, http.StatusConflict, CodeConflict, fmt.Sprintf("Collection %s is already projected", collection.Name))
+		return
+	}
+	sample, err := collection.SampleValues(sampleSize)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	projection, err := models.FitPCA(sample, targetDim)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	// Rebuild each index with the same type and configuration in the
+	// reduced space
+	rebuild := func(name string, existing models.VectorIndex) (models.VectorIndex, error) {
+		linear, ok := existing.(*index.LinearIndex)
+		if !ok {
+			return nil, fmt.Errorf("index %s of type %T cannot be rebuilt for PCA: %w", name, existing, models.ErrInvalidArgument)
+		}
+		return index.NewLinearIndexWithConfig(targetDim, linear.Metric(), linear.Config())
+	}
+	if err := collection.ApplyProjection(projection, rebuild); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dimension":       collection.Dimension,
+		"index_dimension": targetDim,
+		"sample_size":     len(sample),
+		"status":          "ok",
+	})
+}
+
+// aggregate computes a numeric aggregation over a metadata field
+func (api *API) aggregate(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var request struct {
+		Field  string                 `json:"field"`
+		Op     string                 `json:"op"`
+		Filter *models.MetadataFilter `json:"filter"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	value, err := collection.Aggregate(request.Field, request.Op, request.Filter)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"field":  request.Field,
+		"op":     request.Op,
+		"value":  value,
+		"status": "ok",
+	})
+}
+
+// arithmetic searches for the neighbors of the sum of the "add" vectors minus
+// the sum of the "subtract" vectors, answering analogies such as
+// king - man + woman. The operands are never returned.
+func (api *API) arithmetic(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	var request struct {
+		Add      []string               `json:"add"`
+		Subtract []string               `json:"subtract"`
+		K        int                    `json:"k"`
+		Filter   *models.MetadataFilter `json:"filter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	if len(request.Add) == 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "At least one vector to add is required")
+		return
+	}
+	
+	var missing []string
+	for _, id := range append(append([]string(nil), request.Add...), request.Subtract...) {
+		if _, ok := collection.GetByID(id); !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		writeError(w, http.StatusNotFound, CodeVectorNotFound,
+			fmt.Sprintf("Vectors not found: %s", strings.Join(missing, ", ")))
+		return
+	}
+	
+	release, ok := api.acquireSearch(w)
+	if !ok {
+		return
+	}
+	defer release()
+	
+	_, processor, exists := api.lookup(collection.Name)
+	if !exists {
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", collection.Name))
+		return
+	}
+	results, err := processor.ProcessQuery(&models.QueryRequest{
+		Recommend: &models.RecommendParams{
+			Positive: request.Add,
+			Negative: request.Subtract,
+			Strategy: models.RecommendArithmetic,
+		},
+		Filter:      request.Filter,
+		Limit:       request.K,
+		WithPayload: true,
+	})
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"result": results,
+		"status": "ok",
+	})
+}
+
+// recommend handles recommendation queries built from positive and negative example IDs
+func (api *API) recommend(w http.ResponseWriter, r *http.Request, collectionName string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var request struct {
+		Positive []string               `json:"positive"`
+		Negative []string               `json:"negative"`
+		K        int                    `json:"k"`
+		Filter   *models.MetadataFilter `json:"filter"`
+		Strategy string                 `json:"strategy"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	release, ok := api.acquireSearch(w)
+	if !ok {
+		return
+	}
+	defer release()
+	
+	_, processor, exists := api.lookup(collectionName)
+	if !exists {
+		writeError(w, http.StatusNotFound, CodeCollectionNotFound, fmt.Sprintf("Collection %s not found", collectionName))
+		return
+	}
+	results, err := processor.ProcessQuery(&models.QueryRequest{
+		Recommend: &models.RecommendParams{
+			Positive: request.Positive,
+			Negative: request.Negative,
+			Strategy: request.Strategy,
+		},
+		Filter:      request.Filter,
+		Limit:       request.K,
+		WithPayload: true,
+	})
+	if err != nil {
+		// Missing examples are a fault of the request, not a missing resource
+		status := errorStatus(err)
+		if status == http.StatusNotFound {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, errorCode(err), err.Error())
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"result": results,
+		"status": "ok",
+	})
+}
+
+// vectorRequest is the JSON representation of a vector in write requests
+type vectorRequest struct {
+	ID       string                 `json:"id"`
+	Values   []float32              `json:"values"`
+	Metadata map[string]interface{} `json:"metadata"`
+	
+	// Sparse form: when indices are given, values holds the value at each
+	// index and the vector is densified to the collection's dimension. A
+	// non-zero dimension must match it.
+	Indices   []int `json:"indices"`
+	Dimension int   `json:"dimension"`
+}
+
+// vector builds the vector described by the request. The sparse form is
+// densified like InsertSparse, so a mismatched dimension is rejected before
+// anything is allocated.
+func (request vectorRequest) vector(collection *models.VectorCollection) (*models.Vector, error) {
+	if request.Indices == nil {
+		return models.NewVector(request.ID, request.Values, request.Metadata), nil
+	}
+	
+	sparse := models.NewSparseVector(request.ID, request.Indices, request.Values, request.Metadata)
+	sparse.Dimension = request.Dimension
+	if sparse.Dimension == 0 {
+		sparse.Dimension = collection.Dimension
+	}
+	return collection.Densify(sparse)
+}
+
+// vectorResponse renders a stored vector as JSON
+func vectorResponse(v *models.Vector) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        v.ID,
+		"values":    v.Values,
+		"metadata":  v.Metadata,
+		"timestamp": v.Timestamp,
+		"version":   v.Version,
+	}
+}
+
+// versionETag formats a vector version as a strong ETag
+func versionETag(version uint64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseIfMatch extracts the expected version from an If-Match header.
+// Returns 0 when the header is absent.
+func parseIfMatch(r *http.Request) (uint64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(strings.Trim(header, "\""), 10, 64)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid If-Match header %s: %w", header, models.ErrInvalidArgument)
+	}
+	return version, nil
+}
+
+// writeVersioned writes a vector response along with its version as the ETag
+func writeVersioned(w http.ResponseWriter, status int, v *models.Vector) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
+	w.Header().Set("ETag", versionETag(v.Version))
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Not implemented",
-		"status":  "error",
+		"result": vectorResponse(v),
+		"status": "ok",
 	})
 }
 
+// upsertVector inserts or replaces a vector. PUT (or POST with ?upsert=true)
+// replaces an existing vector, while a plain POST rejects duplicate IDs with
+// 409. An If-Match header makes the write conditional on the stored version.
+// The ID may be omitted if the collection auto-generates IDs.
+func (api *API) upsertVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	var request vectorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	
+	upsert := r.Method == http.MethodPut || r.URL.Query().Get("upsert") == "true"
+	
+	v, err := request.vector(collection)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	switch {
+	case expectedVersion != 0:
+		err = collection.InsertIfVersion(v, expectedVersion)
+	case upsert:
+		err = collection.Insert(v)
+	default:
+		err = collection.InsertNew(v)
+	}
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	writeVersioned(w, http.StatusOK, v)
+}
+
+// updateVectorMetadata replaces a vector's metadata. An If-Match header makes
+// the update conditional on the stored version.
+func (api *API) updateVectorMetadata(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection, id string) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	var request struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	
+	updated, err := collection.UpdateMetadata(id, request.Metadata, expectedVersion)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	writeVersioned(w, http.StatusOK, updated)
+}
+
+// batchInsertVectors is a stub that still needs to be implemented
 func (api *API) batchInsertVectors(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	writeError(w, http.StatusNotImplemented, CodeNotImplemented, "Not implemented")
+}
+
+// dedup finds clusters of near-duplicate vectors whose similarity score is at
+// least the requested threshold. With "remove" set, all but the lowest ID of
+// each cluster are deleted.
+func (api *API) dedup(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var request struct {
+		Threshold float32 `json:"threshold"`
+		Remove    bool    `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	if request.Remove && !api.checkWritable(w) {
+		return
+	}
+	
+	release, ok := api.acquireSearch(w)
+	if !ok {
+		return
+	}
+	defer release()
+	
+	var clusters [][]string
+	var err error
+	if request.Remove {
+		clusters, err = collection.DeduplicateKeepFirst(request.Threshold)
+	} else {
+		clusters, err = collection.FindDuplicates(request.Threshold)
+	}
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	removed := []string{}
+	if request.Remove {
+		for _, cluster := range clusters {
+			removed = append(removed, cluster[1:]...)
+		}
+	}
+	if clusters == nil {
+		clusters = [][]string{}
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Not implemented",
-		"status":  "error",
+		"clusters": clusters,
+		"removed":  removed,
+		"status":   "ok",
 	})
 }
 
-func (api *API) getVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection, id string) {
+// schemaField describes a metadata field in a schema document
+type schemaField struct {
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// maxReportedViolations bounds how many offending vector IDs a rejected
+// schema change lists in its error message
+const maxReportedViolations = 10
+
+// schema returns the collection's metadata schema on GET and replaces it on
+// PUT. A new schema is checked against the stored vectors first: if any of
+// them would violate it the change is rejected with 409, unless force=true is
+// given, in which case it is applied and the violating vectors are reported.
+func (api *API) schema(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	switch r.Method {
+	case http.MethodGet:
+		writeSchema(w, collection.Schema(), nil)
+		return
+	case http.MethodPut:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	force := false
+	if forceStr := r.URL.Query().Get("force"); forceStr != "" {
+		var err error
+		if force, err = strconv.ParseBool(forceStr); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid force parameter")
+			return
+		}
+	}
+	
+	var request struct {
+		Fields map[string]schemaField `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	schema := models.NewMetadataSchema()
+	for name, field := range request.Fields {
+		if name == "" {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Schema field names cannot be empty")
+			return
+		}
+		fieldType, err := models.ParseFieldType(field.Type)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Schema field %s: %v", name, err))
+			return
+		}
+		if field.Required {
+			schema.AddRequiredField(name, fieldType)
+		} else {
+			schema.AddField(name, fieldType)
+		}
+	}
+	
+	violations, err := collection.MigrateSchema(schema, !force)
+	if errors.Is(err, models.ErrSchemaViolation) {
+		ids := make([]string, 0, maxReportedViolations)
+		for i, violation := range violations {
+			if i == maxReportedViolations {
+				ids = append(ids, "...")
+				break
+			}
+			ids = append(ids, violation.VectorID)
+		}
+		writeError(w, http.StatusConflict, CodeSchemaViolation,
+			fmt.Sprintf("%v (vectors %s); retry with force=true to apply it anyway", err, strings.Join(ids, ", ")))
+		return
+	}
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	
+	writeSchema(w, collection.Schema(), violations)
+}
+
+// writeSchema responds with a schema document and the vectors found
+// violating it
+func writeSchema(w http.ResponseWriter, schema *models.MetadataSchema, violations []models.SchemaViolation) {
+	fields := make(map[string]schemaField)
+	if schema != nil {
+		for name, fieldType := range schema.Fields {
+			fields[name] = schemaField{Type: fieldType.String(), Required: schema.Required[name]}
+		}
+	}
+	
+	reported := make([]map[string]string, len(violations))
+	for i, violation := range violations {
+		reported[i] = map[string]string{
+			"id":    violation.VectorID,
+			"error": violation.Err.Error(),
+		}
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Not implemented",
-		"status":  "error",
+		"fields":     fields,
+		"violations": reported,
+		"status":     "ok",
 	})
 }
 
-func (api *API) deleteVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection, id string) {
+// bulkUpsertVectors inserts or replaces a batch of vectors. Vectors carrying
+// an idempotency key that was applied recently are skipped, so clients can
+// safely resend a batch after a failure.
+func (api *API) bulkUpsertVectors(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	var request struct {
+		Vectors []struct {
+			vectorRequest
+			IdempotencyKey string `json:"idempotency_key"`
+		} `json:"vectors"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	items := make([]models.UpsertItem, len(request.Vectors))
+	for i, v := range request.Vectors {
+		vec, err := v.vector(collection)
+		if err != nil {
+			writeCollectionError(w, fmt.Errorf("vector %d: %w", i, err))
+			return
+		}
+		items[i] = models.UpsertItem{
+			Vector:         vec,
+			IdempotencyKey: v.IdempotencyKey,
+		}
+	}
+	results := collection.BulkUpsert(items)
+	
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Not implemented",
-		"status":  "error",
+		"results":    results,
+		"applied":    counts[models.UpsertApplied],
+		"duplicates": counts[models.UpsertDuplicate],
+		"failed":     counts[models.UpsertFailed],
+		"status":     "ok",
 	})
 }
 
+// deleteVectors removes every vector listed in the request body, reporting
+// the IDs that did not exist
+func (api *API) deleteVectors(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	
+	deleted, notFound, err := collection.DeleteBatch(request.IDs)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	if notFound == nil {
+		notFound = []string{}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deleted":   deleted,
+		"not_found": notFound,
+		"status":    "ok",
+	})
+}
+
+// getVector returns a single vector, with its version as the ETag
+func (api *API) getVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection, id string) {
+	v, ok := collection.GetByID(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeVectorNotFound, fmt.Sprintf("Vector %s not found", id))
+		return
+	}
+	
+	writeVersioned(w, http.StatusOK, v)
+}
+
+// deleteVector is a stub that still needs to be implemented
+func (api *API) deleteVector(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection, id string) {
+	if !api.checkWritable(w) {
+		return
+	}
+	
+	writeError(w, http.StatusNotImplemented, CodeNotImplemented, "Not implemented")
+}
+
+// maxListPageSize caps the number of IDs returned by one page of
+// listVectors; larger limits are lowered to it
+const maxListPageSize = 1000
+
+// listVectors returns a page of the collection's vector IDs in sorted order,
+// with the total count. With include_metadata=true each entry also carries
+// the vector's metadata and version.
 func (api *API) listVectors(w http.ResponseWriter, r *http.Request, collection *models.VectorCollection) {
 	// Get pagination parameters
 	limitStr := r.URL.Query().Get("limit")
@@ -426,24 +1824,56 @@ func (api *API) listVectors(w http.ResponseWriter, r *http.Request, collection *
 		var err error
 		limit, err = strconv.Atoi(limitStr)
 		if err != nil || limit <= 0 {
-			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit parameter")
 			return
 		}
+		if limit > maxListPageSize {
+			limit = maxListPageSize
+		}
 	}
 	
 	if offsetStr != "" {
 		var err error
 		offset, err = strconv.Atoi(offsetStr)
 		if err != nil || offset < 0 {
-			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid offset parameter")
 			return
 		}
 	}
 	
+	includeMetadata := r.URL.Query().Get("include_metadata") == "true"
+	
+	// Page through the IDs in sorted order so consecutive pages are stable
+	ids := collection.IDs()
+	total := len(ids)
+	if offset > total {
+		offset = total
+	}
+	if limit < total-offset {
+		ids = ids[offset : offset+limit]
+	} else {
+		ids = ids[offset:]
+	}
+	
+	vectors := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		entry := map[string]interface{}{"id": id}
+		if includeMetadata {
+			// Vectors deleted since the IDs were listed are reported without metadata
+			if v, ok := collection.GetByID(id); ok {
+				entry["metadata"] = v.Metadata
+				entry["version"] = v.Version
+			}
+		}
+		vectors = append(vectors, entry)
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Not implemented",
-		"status":  "error",
+		"vectors": vectors,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+		"status":  "ok",
 	})
 }
\ No newline at end of file
@@ -0,0 +1,1481 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"course/models"
+	"course/vector"
+	"course/vector/index"
+)
+
+// newTestServer wires an API into a mux served by an httptest server
+func newTestServer(t *testing.T, api *API) *httptest.Server {
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// postJSON posts a JSON body to the server and decodes the JSON response
+func postJSON(t *testing.T, url string, body interface{}, out interface{}) *http.Response {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return resp
+}
+
+func TestSimilarityMatrix(t *testing.T) {
+	server := newTestServer(t, NewAPI())
+
+	var response struct {
+		Matrix [][]float32 `json:"matrix"`
+	}
+	resp := postJSON(t, server.URL+"/similarity/matrix", map[string]interface{}{
+		"vectors": [][]float32{{0, 0}, {3, 4}, {1, 0}, {0, 1}},
+		"metric":  "euclidean",
+	}, &response)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(response.Matrix) != 4 {
+		t.Fatalf("Expected a 4x4 matrix, got %d rows", len(response.Matrix))
+	}
+
+	for i := range response.Matrix {
+		if len(response.Matrix[i]) != 4 {
+			t.Fatalf("Row %d: expected 4 columns, got %d", i, len(response.Matrix[i]))
+		}
+		if response.Matrix[i][i] != 0 {
+			t.Errorf("Expected zero diagonal at %d, got %v", i, response.Matrix[i][i])
+		}
+		for j := range response.Matrix[i] {
+			if response.Matrix[i][j] != response.Matrix[j][i] {
+				t.Errorf("Matrix not symmetric at (%d,%d): %v vs %v",
+					i, j, response.Matrix[i][j], response.Matrix[j][i])
+			}
+		}
+	}
+
+	if response.Matrix[0][1] != 5 {
+		t.Errorf("Expected distance 5 between origin and (3,4), got %v", response.Matrix[0][1])
+	}
+}
+
+func TestSimilarityMatrixValidation(t *testing.T) {
+	server := newTestServer(t, NewAPI())
+
+	tooMany := make([][]float32, maxMatrixVectors+1)
+	for i := range tooMany {
+		tooMany[i] = []float32{1, 0}
+	}
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+	}{
+		{"Empty", map[string]interface{}{"vectors": [][]float32{}}},
+		{"DimensionMismatch", map[string]interface{}{"vectors": [][]float32{{1, 0}, {1, 0, 0}}}},
+		{"UnknownMetric", map[string]interface{}{"vectors": [][]float32{{1, 0}}, "metric": "hamming"}},
+		{"TooMany", map[string]interface{}{"vectors": tooMany}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := postJSON(t, server.URL+"/similarity/matrix", tc.body, nil)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRecommendEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Result []models.SearchResult `json:"result"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/recommend", map[string]interface{}{
+		"positive": []string{"b1", "b2"},
+		"negative": []string{"a1"},
+		"k":        1,
+	}, &response)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(response.Result) != 1 || response.Result[0].ID != "b3" {
+		t.Errorf("Expected b3 to be recommended, got %+v", response.Result)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/recommend", map[string]interface{}{
+		"positive": []string{"missing"},
+	}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a missing example, got %d", resp.StatusCode)
+	}
+}
+
+func TestFitPCAEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Euclidean, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	resp := postJSON(t, server.URL+"/collections/test/fit-pca", nil, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without target_dim, got %d", resp.StatusCode)
+	}
+
+	var fit struct {
+		IndexDimension int `json:"index_dimension"`
+		SampleSize     int `json:"sample_size"`
+	}
+	resp = postJSON(t, server.URL+"/collections/test/fit-pca?target_dim=2", nil, &fit)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if fit.IndexDimension != 2 || fit.SampleSize != 6 {
+		t.Errorf("Expected a 2-dimensional fit on 6 vectors, got %+v", fit)
+	}
+
+	// Searches and recommendations keep working in the reduced space
+	var response struct {
+		Result []models.SearchResult `json:"result"`
+	}
+	resp = postJSON(t, server.URL+"/collections/test/recommend", map[string]interface{}{
+		"positive": []string{"b1", "b2"},
+		"k":        1,
+	}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(response.Result) != 1 || response.Result[0].ID != "b3" {
+		t.Errorf("Expected b3 to be recommended, got %+v", response.Result)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/fit-pca?target_dim=1", nil, nil)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 for a second fit, got %d", resp.StatusCode)
+	}
+}
+
+func TestFitPCAKeepsIndexConfig(t *testing.T) {
+	collection := models.NewVectorCollection("test", 3, models.Euclidean)
+	config := index.DefaultLinearIndexConfig()
+	config.Workers = 3
+	config.ParallelThreshold = 10
+	config.PruneWithNorms = true
+	config.Precision = vector.Float64Accumulation
+	linear, err := index.NewLinearIndexWithConfig(3, models.Euclidean, config)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	if err := collection.AddIndex("linear", linear); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	for _, v := range labeledVectors() {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+	api := NewAPI()
+	api.RegisterCollection(collection)
+	server := newTestServer(t, api)
+
+	// Concurrent fits race for the collection; exactly one may win
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := http.Post(server.URL+"/collections/test/fit-pca?target_dim=2", "application/json", nil)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	counts := map[int]int{}
+	for i := 0; i < 2; i++ {
+		counts[<-statuses]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != 1 {
+		t.Errorf("Expected one fit to succeed and one to conflict, got %v", counts)
+	}
+
+	rebuilt, ok := collection.Indexes["linear"].(*index.LinearIndex)
+	if !ok || rebuilt.Dimension() != 2 {
+		t.Fatalf("Expected a 2-dimensional linear index, got %v", collection.Indexes["linear"])
+	}
+	if got := rebuilt.Config(); !reflect.DeepEqual(got, config) {
+		t.Errorf("Expected the rebuilt index to keep config %+v, got %+v", config, got)
+	}
+}
+
+func TestFacetsEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	resp, err := http.Get(server.URL + "/collections/test/facets?field=region&limit=1")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Values []struct {
+			Value string `json:"value"`
+			Count int    `json:"count"`
+		} `json:"values"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Values) != 1 || response.Values[0].Count != 3 {
+		t.Errorf("Expected a single facet with count 3, got %+v", response.Values)
+	}
+	if !response.Truncated {
+		t.Errorf("Expected truncation to be reported")
+	}
+}
+
+func TestAggregateEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine,
+		models.NewVector("v1", []float32{1, 0, 0}, map[string]interface{}{"category": "books", "price": 10.0}),
+		models.NewVector("v2", []float32{0, 1, 0}, map[string]interface{}{"category": "books", "price": 20.0}),
+		models.NewVector("v3", []float32{0, 0, 1}, map[string]interface{}{"category": "music", "price": 90.0}),
+	))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Value float64 `json:"value"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/aggregate", map[string]interface{}{
+		"field": "price",
+		"op":    "avg",
+		"filter": map[string]interface{}{
+			"conditions": []map[string]interface{}{
+				{"field": "category", "operator": "eq", "value": "books"},
+			},
+		},
+	}, &response)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if response.Value != 15 {
+		t.Errorf("Expected avg price 15, got %v", response.Value)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/aggregate", map[string]interface{}{
+		"field": "price",
+		"op":    "median",
+	}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown op, got %d", resp.StatusCode)
+	}
+}
+
+func TestCountEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Count int `json:"count"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/count", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"conditions": []map[string]interface{}{
+				{"field": "region", "operator": "eq", "value": "A"},
+			},
+		},
+	}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if response.Count != 3 {
+		t.Errorf("Expected 3 vectors in region A, got %d", response.Count)
+	}
+
+	postJSON(t, server.URL+"/collections/test/count", map[string]interface{}{}, &response)
+	if response.Count != 6 {
+		t.Errorf("Expected 6 vectors without a filter, got %d", response.Count)
+	}
+}
+
+func TestDeleteVectorsEndpoint(t *testing.T) {
+	api := NewAPI()
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	api.RegisterCollection(collection)
+	server := newTestServer(t, api)
+
+	var response struct {
+		Deleted  int      `json:"deleted"`
+		NotFound []string `json:"not_found"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/vectors/delete", map[string]interface{}{
+		"ids": []string{"a1", "b2", "zz"},
+	}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if response.Deleted != 2 || len(response.NotFound) != 1 || response.NotFound[0] != "zz" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if size := collection.Size(); size != 4 {
+		t.Errorf("Expected 4 vectors left, got %d", size)
+	}
+}
+
+func TestBulkUpsertEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine))
+	server := newTestServer(t, api)
+
+	body := map[string]interface{}{
+		"vectors": []map[string]interface{}{
+			{"id": "v1", "values": []float32{1, 0, 0}, "idempotency_key": "batch-1/0"},
+			{"id": "v2", "values": []float32{0, 1, 0}, "idempotency_key": "batch-1/1"},
+		},
+	}
+	var response struct {
+		Results    []models.UpsertResult `json:"results"`
+		Applied    int                   `json:"applied"`
+		Duplicates int                   `json:"duplicates"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/vectors/upsert", body, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if response.Applied != 2 || response.Duplicates != 0 {
+		t.Errorf("Expected 2 applied, got %+v", response)
+	}
+
+	response.Applied, response.Duplicates = 0, 0
+	postJSON(t, server.URL+"/collections/test/vectors/upsert", body, &response)
+	if response.Applied != 0 || response.Duplicates != 2 {
+		t.Errorf("Expected the resent batch to be all duplicates, got %+v", response)
+	}
+	for _, result := range response.Results {
+		if result.Status != models.UpsertDuplicate {
+			t.Errorf("Expected %s to be a duplicate, got %s", result.ID, result.Status)
+		}
+	}
+	if v, _ := api.collections["test"].GetByID("v1"); v.Version != 1 {
+		t.Errorf("Expected v1 to stay at version 1, got %d", v.Version)
+	}
+}
+
+func TestNDJSONQuery(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"vector": []float32{1, 0, 0},
+		"limit":  5,
+	})
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/collections/test/query", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected ndjson content type, got %s", ct)
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result models.SearchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("Line %d is not a search result: %v", count, err)
+		}
+		if result.ID == "" {
+			t.Errorf("Line %d is missing an ID", count)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed reading response: %v", err)
+	}
+
+	if count != 5 {
+		t.Errorf("Expected 5 result lines, got %d", count)
+	}
+}
+
+func TestBulkCreateCollections(t *testing.T) {
+	api := NewAPI()
+	server := newTestServer(t, api)
+
+	var response struct {
+		Collections []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"collections"`
+	}
+
+	resp := postJSON(t, server.URL+"/collections/bulk", map[string]interface{}{
+		"collections": []map[string]interface{}{
+			{
+				"name":      "products",
+				"dimension": 3,
+				"metric":    "cosine",
+				"schema":    map[string]string{"price": "number"},
+				"indexes":   []map[string]string{{"name": "linear", "type": "linear"}},
+			},
+			{"name": "images", "dimension": 8, "metric": "euclidean"},
+		},
+	}, &response)
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+	for _, status := range response.Collections {
+		if status.Status != "created" {
+			t.Errorf("Expected %s to be created, got %s", status.Name, status.Status)
+		}
+	}
+	products := api.collections["products"]
+	if products == nil || api.collections["images"] == nil {
+		t.Fatalf("Expected both collections to be registered")
+	}
+	if products.MetadataSchema.Fields["price"] != models.NumberField {
+		t.Errorf("Expected schema to be applied to products")
+	}
+	if len(products.Indexes) != 1 {
+		t.Errorf("Expected products to have one index, got %d", len(products.Indexes))
+	}
+}
+
+func TestBulkCreateCollectionsIsAtomic(t *testing.T) {
+	api := NewAPI()
+	server := newTestServer(t, api)
+
+	var response struct {
+		Collections []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"collections"`
+	}
+
+	resp := postJSON(t, server.URL+"/collections/bulk", map[string]interface{}{
+		"collections": []map[string]interface{}{
+			{"name": "good", "dimension": 3},
+			{"name": "bad", "dimension": 0},
+			{"name": "typo", "dimension": 3, "schema": map[string]string{"price": "money"}},
+		},
+	}, &response)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+	if len(api.collections) != 0 {
+		t.Errorf("Expected no collections to be created, got %d", len(api.collections))
+	}
+
+	expected := map[string]string{"good": "skipped", "bad": "invalid", "typo": "invalid"}
+	for _, status := range response.Collections {
+		if status.Status != expected[status.Name] {
+			t.Errorf("Expected %s to be %s, got %s", status.Name, expected[status.Name], status.Status)
+		}
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	setReadOnly := func(enabled bool) {
+		var response struct {
+			ReadOnly bool `json:"read_only"`
+		}
+		postJSON(t, server.URL+"/admin/readonly", map[string]bool{"enabled": enabled}, &response)
+		if response.ReadOnly != enabled {
+			t.Fatalf("Expected read_only=%v after toggle, got %v", enabled, response.ReadOnly)
+		}
+	}
+	createCollection := func(name string) int {
+		resp := postJSON(t, server.URL+"/collections", map[string]interface{}{
+			"name":      name,
+			"dimension": 3,
+		}, nil)
+		return resp.StatusCode
+	}
+
+	setReadOnly(true)
+
+	if status := createCollection("blocked"); status != http.StatusForbidden {
+		t.Errorf("Expected create to be rejected with 403, got %d", status)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/collections/test", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Delete request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected delete to be rejected with 403, got %d", resp.StatusCode)
+	}
+
+	// Reads keep working
+	resp = postJSON(t, server.URL+"/collections/test/query", map[string]interface{}{
+		"vector": []float32{1, 0, 0},
+	}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected query to succeed in read-only mode, got %d", resp.StatusCode)
+	}
+
+	setReadOnly(false)
+
+	if status := createCollection("allowed"); status != http.StatusCreated {
+		t.Errorf("Expected create to succeed after disabling read-only mode, got %d", status)
+	}
+}
+
+func TestVersionedVectorUpdates(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine))
+	server := newTestServer(t, api)
+
+	do := func(method, path, ifMatch string, body interface{}) *http.Response {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(payload))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := do(http.MethodPut, "/collections/test/vectors", "", map[string]interface{}{
+		"id": "v1", "values": []float32{1, 0, 0}, "metadata": map[string]interface{}{"rev": "a"},
+	})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"1"` {
+		t.Fatalf("Expected 200 with ETag \"1\", got %d %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	resp, err := http.Get(server.URL + "/collections/test/vectors/v1")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("ETag") != `"1"` {
+		t.Errorf("Expected GET to expose ETag \"1\", got %s", resp.Header.Get("ETag"))
+	}
+
+	resp = do(http.MethodPatch, "/collections/test/vectors/v1", `"1"`, map[string]interface{}{
+		"metadata": map[string]interface{}{"rev": "b"},
+	})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"2"` {
+		t.Errorf("Expected versioned PATCH to succeed with ETag \"2\", got %d %s",
+			resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// Replaying the same precondition now conflicts
+	resp = do(http.MethodPut, "/collections/test/vectors", `"1"`, map[string]interface{}{
+		"id": "v1", "values": []float32{0, 1, 0},
+	})
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a conflicting PUT, got %d", resp.StatusCode)
+	}
+}
+
+func TestCustomMetric(t *testing.T) {
+	chebyshev := func(a, b []float32) float32 {
+		var max float32
+		for i := range a {
+			diff := a[i] - b[i]
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > max {
+				max = diff
+			}
+		}
+		return max
+	}
+	// The registry is global, so only register once per test binary run
+	if _, ok := vector.LookupMetric("chebyshev"); !ok {
+		if _, err := vector.RegisterMetric(vector.MetricDefinition{
+			Name: "chebyshev", Aliases: []string{"linf"}, Func: chebyshev,
+		}); err != nil {
+			t.Fatalf("Failed to register metric: %v", err)
+		}
+	}
+	if _, err := vector.RegisterMetric(vector.MetricDefinition{Name: "LInf", Func: chebyshev}); err == nil {
+		t.Errorf("Expected duplicate metric name to be rejected")
+	}
+
+	api := NewAPI()
+	server := newTestServer(t, api)
+
+	resp := postJSON(t, server.URL+"/collections", map[string]interface{}{
+		"name":      "custom",
+		"dimension": 2,
+		"metric":    "chebyshev",
+		"indexes":   []map[string]string{{"name": "linear", "type": "linear"}},
+	}, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+
+	collection := api.collections["custom"]
+	for id, values := range map[string][]float32{"near": {1, 1}, "far": {5, 0}} {
+		if err := collection.Insert(&models.Vector{ID: id, Values: values}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	results, err := collection.Search([]float32{0, 0}, 2, nil, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "near" || results[0].Distance != 1 {
+		t.Errorf("Expected near at distance 1 first, got %+v", results)
+	}
+
+	var info map[string]interface{}
+	getResp, err := http.Get(server.URL + "/collections/custom")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer getResp.Body.Close()
+	json.NewDecoder(getResp.Body).Decode(&info)
+	if info["metric"] != "chebyshev" {
+		t.Errorf("Expected metric chebyshev, got %v", info["metric"])
+	}
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	api := NewAPI()
+	collection := newTestCollection(t, 3, models.Cosine)
+	collection.MaxVectors = 1
+	api.RegisterCollection(collection)
+	server := newTestServer(t, api)
+
+	do := func(method, path, ifMatch string, body interface{}) int {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(payload))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := do(http.MethodPut, "/collections/test/vectors", "", map[string]interface{}{
+		"id": "v1", "values": []float32{1, 0, 0},
+	}); status != http.StatusOK {
+		t.Fatalf("Expected initial insert to succeed, got %d", status)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		ifMatch  string
+		body     interface{}
+		expected int
+	}{
+		{"DimensionMismatch", http.MethodPut, "/collections/test/vectors", "",
+			map[string]interface{}{"id": "v1", "values": []float32{1, 0}}, http.StatusBadRequest},
+		{"NotFound", http.MethodPatch, "/collections/test/vectors/missing", "",
+			map[string]interface{}{"metadata": map[string]interface{}{}}, http.StatusNotFound},
+		{"VersionConflict", http.MethodPut, "/collections/test/vectors", `"7"`,
+			map[string]interface{}{"id": "v1", "values": []float32{0, 1, 0}}, http.StatusPreconditionFailed},
+		{"CapacityExceeded", http.MethodPut, "/collections/test/vectors", "",
+			map[string]interface{}{"id": "v2", "values": []float32{0, 1, 0}}, http.StatusInsufficientStorage},
+		{"QueryDimensionMismatch", http.MethodPost, "/collections/test/query", "",
+			map[string]interface{}{"Vector": []float32{1}}, http.StatusBadRequest},
+		{"InvalidFilter", http.MethodPost, "/collections/test/query", "",
+			map[string]interface{}{"Vector": []float32{1, 0, 0}, "Filter": map[string]interface{}{"Operator": 7}}, http.StatusBadRequest},
+		{"NotImplemented", http.MethodPost, "/collections/test/query", "",
+			map[string]interface{}{"PointID": "v1"}, http.StatusNotImplemented},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := do(tc.method, tc.path, tc.ifMatch, tc.body); status != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, status)
+			}
+		})
+	}
+
+	// Errors wrapping no sentinel are server failures
+	unexpected := errors.New("disk on fire")
+	if status, code := errorStatus(unexpected), errorCode(unexpected); status != http.StatusInternalServerError || code != CodeInternal {
+		t.Errorf("Expected 500 %s for an uncategorized error, got %d %s", CodeInternal, status, code)
+	}
+}
+
+func TestCreateCollectionValidation(t *testing.T) {
+	server := newTestServer(t, NewAPI())
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+	}{
+		{"ZeroDimension", map[string]interface{}{"name": "c", "dimension": 0}},
+		{"UnknownMetric", map[string]interface{}{"name": "c", "dimension": 3, "metric": "hamming"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := postJSON(t, server.URL+"/collections", tc.body, nil)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// plantedDuplicates returns random vectors plus near-copies of two of them:
+// 20 copies of "base0" (more than one neighbor search returns) and one of
+// "base1", along with the clusters they should form
+func plantedDuplicates() ([]*models.Vector, [][]string) {
+	rng := rand.New(rand.NewSource(3))
+	var vectors []*models.Vector
+	bases := make([][]float32, 30)
+	for i := range bases {
+		bases[i] = make([]float32, 16)
+		for j := range bases[i] {
+			bases[i][j] = float32(rng.NormFloat64())
+		}
+		vectors = append(vectors, models.NewVector(fmt.Sprintf("base%d", i), bases[i], nil))
+	}
+
+	nearCopy := func(values []float32) []float32 {
+		copied := make([]float32, len(values))
+		for j, val := range values {
+			copied[j] = val + float32(rng.NormFloat64())*0.001
+		}
+		return copied
+	}
+	first := []string{"base0"}
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("copy0-%02d", i)
+		vectors = append(vectors, models.NewVector(id, nearCopy(bases[0]), nil))
+		first = append(first, id)
+	}
+	vectors = append(vectors, models.NewVector("copy1", nearCopy(bases[1]), nil))
+
+	return vectors, [][]string{first, {"base1", "copy1"}}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	vectors, expected := plantedDuplicates()
+	collection := newTestCollection(t, 16, models.Cosine, vectors...)
+
+	clusters, err := collection.FindDuplicates(0.995)
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("Expected clusters %v, got %v", expected, clusters)
+	}
+
+	if _, err := collection.FindDuplicates(0); err == nil {
+		t.Error("Expected a zero threshold to be rejected")
+	}
+}
+
+func TestDedupEndpoint(t *testing.T) {
+	vectors, expected := plantedDuplicates()
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 16, models.Cosine, vectors...))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Clusters [][]string `json:"clusters"`
+		Removed  []string   `json:"removed"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/dedup", map[string]interface{}{"threshold": 0.995}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if !reflect.DeepEqual(response.Clusters, expected) || len(response.Removed) != 0 {
+		t.Errorf("Expected clusters %v and nothing removed, got %+v", expected, response)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/dedup", map[string]interface{}{"threshold": 0.995, "remove": true}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(response.Removed) != 21 {
+		t.Errorf("Expected 21 duplicates removed, got %d", len(response.Removed))
+	}
+	collection := api.collections["test"]
+	if size := collection.Size(); size != 30 {
+		t.Errorf("Expected the 30 originals to remain, got %d vectors", size)
+	}
+	if clusters, _ := collection.FindDuplicates(0.995); len(clusters) != 0 {
+		t.Errorf("Expected no duplicates left, got %v", clusters)
+	}
+}
+
+func TestListVectorsPagination(t *testing.T) {
+	var vectors []*models.Vector
+	for i := 0; i < 15; i++ {
+		vectors = append(vectors, models.NewVector(fmt.Sprintf("v%02d", 14-i), []float32{float32(i), 1, 0},
+			map[string]interface{}{"n": i}))
+	}
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, vectors...))
+	server := newTestServer(t, api)
+
+	var listed []string
+	for _, offset := range []int{0, 8} {
+		resp, err := http.Get(fmt.Sprintf("%s/collections/test/vectors?limit=8&offset=%d&include_metadata=true", server.URL, offset))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var page struct {
+			Vectors []struct {
+				ID       string                 `json:"id"`
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"vectors"`
+			Total int `json:"total"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		if page.Total != 15 {
+			t.Errorf("Expected a total of 15, got %d", page.Total)
+		}
+		for _, v := range page.Vectors {
+			if v.Metadata["n"] == nil {
+				t.Errorf("Expected metadata for %s", v.ID)
+			}
+			listed = append(listed, v.ID)
+		}
+	}
+
+	if len(listed) != 15 {
+		t.Fatalf("Expected the two pages to cover 15 IDs, got %d: %v", len(listed), listed)
+	}
+	for i, id := range listed {
+		if expected := fmt.Sprintf("v%02d", i); id != expected {
+			t.Errorf("Position %d: expected %s, got %s", i, expected, id)
+		}
+	}
+
+	// Huge limits are capped rather than overflowing the page bounds
+	resp, err := http.Get(fmt.Sprintf("%s/collections/test/vectors?offset=1&limit=%d", server.URL, math.MaxInt64))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var page struct {
+		Vectors []struct {
+			ID string `json:"id"`
+		} `json:"vectors"`
+		Limit int `json:"limit"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%v)", resp.StatusCode, err)
+	}
+	if len(page.Vectors) != 14 || page.Vectors[0].ID != "v01" || page.Limit != maxListPageSize {
+		t.Errorf("Expected the 14 IDs after the first with a capped limit, got %d (limit %d)", len(page.Vectors), page.Limit)
+	}
+}
+
+func TestExplainEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Plan models.QueryPlan `json:"plan"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/query/explain", map[string]interface{}{
+		"vector": []float32{1, 0, 0},
+	}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if response.Plan.Index != "linear" || !response.Plan.Exact || response.Plan.EstimatedCandidates != 6 {
+		t.Errorf("Expected an exact plan on the linear index over 6 vectors, got %+v", response.Plan)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/query/explain", map[string]interface{}{}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a request without a query, got %d", resp.StatusCode)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	decodeError := func(resp *http.Response) errorDetail {
+		t.Helper()
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected a JSON error, got content type %q", ct)
+		}
+		var body errorBody
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode error response: %v", err)
+		}
+		return body.Error
+	}
+
+	resp, err := http.Get(server.URL + "/collections/test/vectors/missing")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+	if detail := decodeError(resp); detail.Code != CodeVectorNotFound || detail.Message == "" {
+		t.Errorf("Expected code %s with a message, got %+v", CodeVectorNotFound, detail)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{"id": "bad", "values": []float32{1, 2}})
+	resp, err = http.Post(server.URL+"/collections/test/vectors", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+	if detail := decodeError(resp); detail.Code != CodeBadDimension {
+		t.Errorf("Expected code %s, got %+v", CodeBadDimension, detail)
+	}
+
+	resp, err = http.Get(server.URL + "/collections/nope")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if detail := decodeError(resp); resp.StatusCode != http.StatusNotFound || detail.Code != CodeCollectionNotFound {
+		t.Errorf("Expected 404 %s, got %d %+v", CodeCollectionNotFound, resp.StatusCode, detail)
+	}
+}
+
+func TestIndexedFieldsFilterPath(t *testing.T) {
+	api := NewAPI()
+	server := newTestServer(t, api)
+
+	resp := postJSON(t, server.URL+"/collections", map[string]interface{}{
+		"name":           "test",
+		"dimension":      3,
+		"metric":         "cosine",
+		"indexes":        []map[string]string{{"name": "linear", "type": "linear"}},
+		"indexed_fields": []string{"region"},
+	}, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+	collection := api.collections["test"]
+	if fields := collection.IndexedFields(); !reflect.DeepEqual(fields, []string{"region"}) {
+		t.Fatalf("Expected region to be indexed, got %v", fields)
+	}
+	for _, v := range labeledVectors() {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+
+	search := func(field string, value interface{}) []string {
+		var response struct {
+			Result []models.SearchResult `json:"result"`
+		}
+		resp := postJSON(t, server.URL+"/collections/test/query", map[string]interface{}{
+			"vector": []float32{1, 1, 0},
+			"limit":  10,
+			"filter": map[string]interface{}{
+				"conditions": []map[string]interface{}{
+					{"field": field, "operator": "eq", "value": value},
+				},
+			},
+		}, &response)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+		var ids []string
+		for _, res := range response.Result {
+			ids = append(ids, res.ID)
+		}
+		return ids
+	}
+
+	ids := search("region", "B")
+	if len(ids) != 3 || ids[0][0] != 'b' || ids[1][0] != 'b' || ids[2][0] != 'b' {
+		t.Errorf("Expected the three region B vectors, got %v", ids)
+	}
+	if probes := collection.IndexedFilterSearches(); probes != 1 {
+		t.Errorf("Expected the filter on an indexed field to use the index, got %d probes", probes)
+	}
+
+	// Filters on other fields fall back to a scan
+	if ids := search("color", "red"); len(ids) != 0 {
+		t.Errorf("Expected no results for an unknown field, got %v", ids)
+	}
+	if probes := collection.IndexedFilterSearches(); probes != 1 {
+		t.Errorf("Expected the filter on an unindexed field to scan, got %d probes", probes)
+	}
+
+	resp = postJSON(t, server.URL+"/collections", map[string]interface{}{
+		"name":           "invalid",
+		"dimension":      3,
+		"indexed_fields": []string{""},
+	}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty indexed field, got %d", resp.StatusCode)
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	resp, err := http.Get(server.URL + "/version")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Version     string `json:"version"`
+		Commit      string `json:"commit"`
+		BuildTime   string `json:"build_time"`
+		Collections []struct {
+			Name      string `json:"name"`
+			Dimension int    `json:"dimension"`
+			Metric    string `json:"metric"`
+		} `json:"collections"`
+		ReplicationFactor int `json:"replication_factor"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Tests are not built with -ldflags, so the placeholders are reported
+	if response.Version != "dev" || response.Commit != "unknown" || response.BuildTime != "unknown" {
+		t.Errorf("Expected placeholder build info, got %+v", response)
+	}
+	if len(response.Collections) != 1 || response.Collections[0].Dimension != 3 || response.Collections[0].Metric != vector.MetricName(models.Cosine) {
+		t.Errorf("Expected the test collection's configuration, got %+v", response.Collections)
+	}
+	if response.ReplicationFactor != 1 {
+		t.Errorf("Expected a replication factor of 1, got %d", response.ReplicationFactor)
+	}
+
+	if resp := postJSON(t, server.URL+"/version", map[string]interface{}{}, nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", resp.StatusCode)
+	}
+}
+
+func TestInsertSparseVector(t *testing.T) {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	server := newTestServer(t, api)
+
+	resp := postJSON(t, server.URL+"/collections/test/vectors", map[string]interface{}{
+		"id":       "s1",
+		"indices":  []int{2},
+		"values":   []float32{4},
+		"metadata": map[string]interface{}{"region": "C"},
+	}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	// The sparse vector is found as its dense equivalent
+	var response struct {
+		Result []models.SearchResult `json:"result"`
+	}
+	postJSON(t, server.URL+"/collections/test/query", map[string]interface{}{
+		"vector": []float32{0, 0, 1},
+		"limit":  1,
+	}, &response)
+	if len(response.Result) != 1 || response.Result[0].ID != "s1" || response.Result[0].Score < 0.999 {
+		t.Errorf("Expected s1 to match its dense equivalent exactly, got %+v", response.Result)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/vectors/upsert", map[string]interface{}{
+		"vectors": []map[string]interface{}{
+			{"id": "s2", "indices": []int{5}, "values": []float32{1}},
+		},
+	}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an index past the dimension, got %d", resp.StatusCode)
+	}
+
+	// A dimension other than the collection's is rejected before densifying
+	for _, dimension := range []int{2, 300000000} {
+		resp = postJSON(t, server.URL+"/collections/test/vectors", map[string]interface{}{
+			"id":        "s3",
+			"indices":   []int{0},
+			"values":    []float32{1},
+			"dimension": dimension,
+		}, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for dimension %d, got %d", dimension, resp.StatusCode)
+		}
+	}
+}
+
+func TestArithmeticEndpoint(t *testing.T) {
+	// Dimensions: royalty, gender, fruitiness, humanity
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 4, models.Euclidean,
+		models.NewVector("king", []float32{1, 1, 0, 1}, nil),
+		models.NewVector("queen", []float32{1, -1, 0, 1}, nil),
+		models.NewVector("man", []float32{0, 1, 0, 1}, nil),
+		models.NewVector("woman", []float32{0, -1, 0, 1}, nil),
+		models.NewVector("prince", []float32{0.8, 1, 0, 1}, nil),
+		models.NewVector("apple", []float32{0, 0, 1, 0}, nil),
+	))
+	server := newTestServer(t, api)
+
+	var response struct {
+		Result []models.SearchResult `json:"result"`
+	}
+	resp := postJSON(t, server.URL+"/collections/test/vectors/arithmetic", map[string]interface{}{
+		"add":      []string{"king", "woman"},
+		"subtract": []string{"man"},
+		"k":        2,
+	}, &response)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(response.Result) != 2 || response.Result[0].ID != "queen" {
+		t.Fatalf("Expected queen to answer king - man + woman, got %+v", response.Result)
+	}
+	for _, res := range response.Result {
+		if res.ID == "king" || res.ID == "man" || res.ID == "woman" {
+			t.Errorf("Expected the operands to be excluded, got %s", res.ID)
+		}
+	}
+
+	var failure errorBody
+	resp = postJSON(t, server.URL+"/collections/test/vectors/arithmetic", map[string]interface{}{
+		"add":      []string{"king", "duke"},
+		"subtract": []string{"earl"},
+	}, &failure)
+	if resp.StatusCode != http.StatusNotFound || failure.Error.Code != CodeVectorNotFound {
+		t.Errorf("Expected 404 VECTOR_NOT_FOUND for missing operands, got %d %+v", resp.StatusCode, failure)
+	}
+	if msg := failure.Error.Message; !strings.Contains(msg, "duke") || !strings.Contains(msg, "earl") {
+		t.Errorf("Expected every missing operand to be reported, got %q", msg)
+	}
+
+	resp = postJSON(t, server.URL+"/collections/test/vectors/arithmetic", map[string]interface{}{
+		"subtract": []string{"man"},
+	}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without vectors to add, got %d", resp.StatusCode)
+	}
+}
+
+// compactionStats fetches the compaction totals reported by /stats
+func compactionStats(t *testing.T, url string) (vectors int, runs int, purged int) {
+	resp, err := http.Get(url + "/stats")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Vectors    int `json:"vectors"`
+		Compaction struct {
+			Runs             int `json:"runs"`
+			TombstonesPurged int `json:"tombstones_purged"`
+		} `json:"compaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response.Vectors, response.Compaction.Runs, response.Compaction.TombstonesPurged
+}
+
+func TestManualCompaction(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	if err := collection.Delete("a1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	api := NewAPI()
+	api.RegisterCollection(collection)
+	server := newTestServer(t, api)
+
+	// The tombstone is within the default retention window
+	var response struct {
+		TombstonesPurged int   `json:"tombstones_purged"`
+		BytesReclaimed   int64 `json:"bytes_reclaimed"`
+	}
+	postJSON(t, server.URL+"/admin/compact", map[string]interface{}{}, &response)
+	if response.TombstonesPurged != 0 {
+		t.Errorf("Expected no tombstones purged within retention, got %+v", response)
+	}
+
+	api.SetCompactionRetention(0)
+	postJSON(t, server.URL+"/admin/compact", map[string]interface{}{}, &response)
+	if response.TombstonesPurged != 1 || response.BytesReclaimed <= 0 {
+		t.Errorf("Expected one tombstone purged, got %+v", response)
+	}
+
+	vectors, runs, purged := compactionStats(t, server.URL)
+	if vectors != 5 || runs != 2 || purged != 1 {
+		t.Errorf("Expected 5 vectors after 2 runs purging 1 tombstone, got %d, %d, %d", vectors, runs, purged)
+	}
+
+	// Live vectors still answer searches
+	results, err := collection.Search([]float32{1, 0.1, 0}, 10, nil, nil)
+	if err != nil || len(results) != 5 {
+		t.Errorf("Expected 5 live results, got %d (%v)", len(results), err)
+	}
+}
+
+func TestScheduledCompaction(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	if err := collection.Delete("b1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	api := NewAPI()
+	api.RegisterCollection(collection)
+	api.SetCompactionRetention(0)
+	server := newTestServer(t, api)
+
+	stop := api.StartCompaction(10 * time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, runs, purged := compactionStats(t, server.URL)
+		if runs > 0 && purged == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a scheduled compaction to purge the tombstone, got %d runs purging %d", runs, purged)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	if v, ok := collection.GetByID("b2"); !ok || v.ID != "b2" {
+		t.Errorf("Expected live vectors to be untouched, got %+v", v)
+	}
+}
+
+func TestSchemaEndpoint(t *testing.T) {
+	api := NewAPI()
+	collection := newTestCollection(t, 3, models.Cosine,
+		models.NewVector("v1", []float32{1, 0, 0}, map[string]interface{}{"category": "books", "price": 12.5}),
+		models.NewVector("v2", []float32{0, 1, 0}, map[string]interface{}{"category": "music", "price": "cheap"}),
+	)
+	api.RegisterCollection(collection)
+	server := newTestServer(t, api)
+
+	put := func(query string, fields map[string]interface{}, out interface{}) int {
+		payload, _ := json.Marshal(map[string]interface{}{"fields": fields})
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/collections/test/schema"+query, bytes.NewReader(payload))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	type schemaResponse struct {
+		Fields     map[string]schemaField `json:"fields"`
+		Violations []struct {
+			ID string `json:"id"`
+		} `json:"violations"`
+	}
+
+	// A schema every stored vector conforms to is applied
+	var applied schemaResponse
+	status := put("", map[string]interface{}{
+		"category": map[string]interface{}{"type": "string", "required": true},
+	}, &applied)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 for a compatible schema, got %d", status)
+	}
+	if field := applied.Fields["category"]; field.Type != "string" || !field.Required || len(applied.Violations) != 0 {
+		t.Errorf("Expected the applied schema without violations, got %+v", applied)
+	}
+	if !collection.Schema().Required["category"] {
+		t.Errorf("Expected category to be required by the collection's schema")
+	}
+
+	// Typing price as a number would invalidate v2
+	incompatible := map[string]interface{}{
+		"category": map[string]interface{}{"type": "string", "required": true},
+		"price":    map[string]interface{}{"type": "number"},
+	}
+	var rejected errorBody
+	if status := put("", incompatible, &rejected); status != http.StatusConflict {
+		t.Fatalf("Expected status 409 for an incompatible schema, got %d", status)
+	}
+	if rejected.Error.Code != CodeSchemaViolation || !strings.Contains(rejected.Error.Message, "v2") {
+		t.Errorf("Expected a schema violation naming v2, got %+v", rejected.Error)
+	}
+	if _, declared := collection.Schema().Fields["price"]; declared {
+		t.Errorf("Expected the rejected schema not to be applied")
+	}
+
+	// Forcing applies it and reports the violating vector
+	var forced schemaResponse
+	if status := put("?force=true", incompatible, &forced); status != http.StatusOK {
+		t.Fatalf("Expected status 200 for a forced schema, got %d", status)
+	}
+	if len(forced.Violations) != 1 || forced.Violations[0].ID != "v2" {
+		t.Errorf("Expected v2 reported as violating, got %+v", forced.Violations)
+	}
+	if collection.Schema().Fields["price"] != models.NumberField || collection.Size() != 2 {
+		t.Errorf("Expected price typed as a number with both vectors kept")
+	}
+
+	// The schema reads back and is enforced on new writes
+	resp, err := http.Get(server.URL + "/collections/test/schema")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var current schemaResponse
+	json.NewDecoder(resp.Body).Decode(&current)
+	resp.Body.Close()
+	if len(current.Fields) != 2 || current.Fields["price"].Type != "number" {
+		t.Errorf("Expected the forced schema to read back, got %+v", current.Fields)
+	}
+	if err := collection.Insert(models.NewVector("v3", []float32{0, 0, 1}, map[string]interface{}{"price": 3.0})); err == nil {
+		t.Errorf("Expected an insert missing the required field to fail")
+	}
+
+	if status := put("", map[string]interface{}{"tags": map[string]interface{}{"type": "blob"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown field type, got %d", status)
+	}
+	if status := put("?force=maybe", incompatible, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid force parameter, got %d", status)
+	}
+
+	// Reads of the schema may run while a migration replaces it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				payload, _ := json.Marshal(map[string]interface{}{"fields": incompatible})
+				req, _ := http.NewRequest(http.MethodPut, server.URL+"/collections/test/schema?force=true", bytes.NewReader(payload))
+				if resp, err := http.DefaultClient.Do(req); err == nil {
+					resp.Body.Close()
+				}
+				return
+			}
+			if resp, err := http.Get(server.URL + "/collections/test/schema"); err == nil {
+				resp.Body.Close()
+			}
+			processor := NewProcessor(collection)
+			processor.ProcessQuery(&models.QueryRequest{
+				Vector: []float32{1, 0, 0},
+				Boosts: map[string]float64{"price": 1},
+			})
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCompactionWithConcurrentCollectionChanges(t *testing.T) {
+	api := NewAPI()
+	api.SetCompactionRetention(0)
+	server := newTestServer(t, api)
+
+	stop := api.StartCompaction(time.Millisecond)
+	defer stop()
+
+	// Register and delete collections while compactions and stats run
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("c%d", i%5)
+		collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+		collection.Name = name
+		if err := collection.Delete("a1"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		api.RegisterCollection(collection)
+		api.Compact()
+
+		req, _ := http.NewRequest(http.MethodDelete, server.URL+"/collections/"+name, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		compactionStats(t, server.URL)
+	}
+	stop()
+
+	if _, runs, purged := compactionStats(t, server.URL); runs < 50 || purged < 50 {
+		t.Errorf("Expected every registered collection to be compacted, got %d runs purging %d", runs, purged)
+	}
+}
@@ -0,0 +1,187 @@
+package query
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"course/models"
+	"course/vector"
+)
+
+// ProcessBatchQuery runs several queries and returns their results in order.
+// Vector searches using the BatchSearch strategy share a single exact scan:
+// the collection's vectors are loaded once and every such query is computed
+// against them together, which is cheaper than searching for each query in
+// turn. Other queries, and batch searches overriding the metric or asking
+// for a timeout or an explanation, run as in ProcessQuery. The first failing
+// query fails the batch.
+func (p *Processor) ProcessBatchQuery(requests []*models.QueryRequest) ([]interface{}, error) {
+	results := make([]interface{}, len(requests))
+
+	var batched []int
+	for i, request := range requests {
+		if err := p.prepareRequest(request); err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+		if p.batchable(request) {
+			batched = append(batched, i)
+			continue
+		}
+
+		result, err := p.dispatch(request)
+		if err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+		results[i] = result
+	}
+	if len(batched) == 0 {
+		return results, nil
+	}
+
+	batch := make([]*models.QueryRequest, len(batched))
+	for j, i := range batched {
+		batch[j] = requests[i]
+	}
+	ranked, err := p.scanBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range batched {
+		if results[i], err = p.finishVectorSearch(requests[i], ranked[j]); err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
+
+// batchable reports whether a prepared request can join the shared scan
+func (p *Processor) batchable(request *models.QueryRequest) bool {
+	params := request.Params
+	return request.Vector != nil &&
+		params.SearchStrategy == models.BatchSearch &&
+		(params.Metric == nil || *params.Metric == p.collection.DistanceFunc) &&
+		params.Timeout == 0 &&
+		!params.Explain
+}
+
+// scanBatch ranks the collection's vectors for every request with one pass
+// over them, returning each request's results best first
+func (p *Processor) scanBatch(requests []*models.QueryRequest) ([][]models.SearchResult, error) {
+	collection := p.collection
+	metric := collection.DistanceFunc
+
+	queries := make([][]float32, len(requests))
+	for i, request := range requests {
+		queries[i] = p.prepareQuery(request)
+	}
+
+	var candidates []*models.Vector
+	var values [][]float32
+	queries, err := collection.ScanQueries(queries, func(v *models.Vector) bool {
+		candidates = append(candidates, v)
+		values = append(values, v.Values)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	distances, err := vector.BatchDistanceMany(queries, values, metric)
+	if err != nil {
+		return nil, err
+	}
+
+	higherIsBetter := vector.IsHigherBetter(metric)
+	ranked := make([][]models.SearchResult, len(requests))
+	for i, request := range requests {
+		_, limit := searchLimits(request)
+		threshold := collection.ScoreFloor(request.Params)
+		if limit <= 0 {
+			continue
+		}
+
+		best := &resultHeap{higherIsBetter: higherIsBetter}
+		for c, candidate := range candidates {
+			if len(candidate.Values) != len(queries[i]) {
+				continue
+			}
+			distance := distances[i][c]
+			if best.Len() == limit && !best.beats(distance, candidate.ID) {
+				continue
+			}
+			if request.Filter != nil && !request.Filter.MatchVector(candidate) {
+				continue
+			}
+			score := vector.NormalizeScore(distance, metric)
+			if threshold > 0 && score < threshold {
+				continue
+			}
+			best.add(models.SearchResult{
+				ID:       candidate.ID,
+				Distance: distance,
+				Vector:   candidate,
+				Score:    score,
+			}, limit)
+		}
+
+		results := best.results
+		sort.Slice(results, func(a, b int) bool {
+			return best.better(results[a].Distance, results[a].ID, results[b].Distance, results[b].ID)
+		})
+		ranked[i] = results
+	}
+	return ranked, nil
+}
+
+// resultHeap holds the best results seen so far with the worst of them on
+// top. Ties in distance are broken by ID, so rankings are deterministic.
+type resultHeap struct {
+	results        []models.SearchResult
+	higherIsBetter bool
+}
+
+// better reports whether the first result ranks ahead of the second
+func (h *resultHeap) better(distance float32, id string, otherDistance float32, otherID string) bool {
+	if distance != otherDistance {
+		if h.higherIsBetter {
+			return distance > otherDistance
+		}
+		return distance < otherDistance
+	}
+	return id < otherID
+}
+
+// beats reports whether a result would rank ahead of the current worst
+func (h *resultHeap) beats(distance float32, id string) bool {
+	worst := h.results[0]
+	return h.better(distance, id, worst.Distance, worst.ID)
+}
+
+func (h *resultHeap) Len() int { return len(h.results) }
+
+func (h *resultHeap) Less(i, j int) bool {
+	return h.better(h.results[j].Distance, h.results[j].ID, h.results[i].Distance, h.results[i].ID)
+}
+
+func (h *resultHeap) Swap(i, j int) { h.results[i], h.results[j] = h.results[j], h.results[i] }
+
+func (h *resultHeap) Push(x interface{}) { h.results = append(h.results, x.(models.SearchResult)) }
+
+func (h *resultHeap) Pop() interface{} {
+	last := h.results[len(h.results)-1]
+	h.results = h.results[:len(h.results)-1]
+	return last
+}
+
+// add records a result, keeping only the k best
+func (h *resultHeap) add(result models.SearchResult, k int) {
+	if h.Len() < k {
+		heap.Push(h, result)
+		return
+	}
+	if h.beats(result.Distance, result.ID) {
+		h.results[0] = result
+		heap.Fix(h, 0)
+	}
+}
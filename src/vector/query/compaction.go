@@ -0,0 +1,147 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"course/models"
+)
+
+// DefaultCompactionRetention is how long tombstones of deleted vectors are
+// kept before compaction may purge them
+const DefaultCompactionRetention = 10 * time.Minute
+
+// compactionState holds the compaction settings and the totals reported
+// under /stats
+type compactionState struct {
+	mu        sync.Mutex
+	retention time.Duration          // Age a tombstone must reach to be purged
+	runs      int                    // Compactions completed, scheduled or manual
+	total     models.CompactionStats // Purged across every run
+	lastRun   time.Time              // Completion time of the latest run
+}
+
+// SetCompactionRetention sets how long tombstones of deleted vectors are
+// kept before compaction purges them. Negative values are treated as zero.
+func (api *API) SetCompactionRetention(retention time.Duration) {
+	if retention < 0 {
+		retention = 0
+	}
+	api.compaction.mu.Lock()
+	api.compaction.retention = retention
+	api.compaction.mu.Unlock()
+}
+
+// Compact purges expired tombstones from every collection, returning what
+// this run purged. Each index compacts in bounded chunks, so searches are
+// only held off briefly.
+func (api *API) Compact() models.CompactionStats {
+	api.compaction.mu.Lock()
+	retention := api.compaction.retention
+	api.compaction.mu.Unlock()
+
+	var stats models.CompactionStats
+	for _, collection := range api.snapshot() {
+		purged, err := collection.Compact(retention)
+		if err != nil {
+			api.logf(context.Background(), "compaction of collection %s failed: %v", collection.Name, err)
+			continue
+		}
+		stats.Add(purged)
+	}
+
+	api.compaction.mu.Lock()
+	api.compaction.runs++
+	api.compaction.total.Add(stats)
+	api.compaction.lastRun = time.Now()
+	api.compaction.mu.Unlock()
+	return stats
+}
+
+// StartCompaction compacts every collection each interval in a background
+// goroutine until the returned stop function is called. A non-positive
+// interval disables scheduled compaction.
+func (api *API) StartCompaction(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				api.Compact()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+// handleCompact runs a compaction immediately and reports what it purged
+func (api *API) handleCompact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	stats := api.Compact()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tombstones_purged": stats.TombstonesPurged,
+		"bytes_reclaimed":   stats.BytesReclaimed,
+		"status":            "ok",
+	})
+}
+
+// handleStats reports the size of the node's collections and the totals of
+// the compactions run so far
+func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	collections := api.snapshot()
+	vectors := 0
+	for _, collection := range collections {
+		vectors += collection.Size()
+	}
+
+	api.compaction.mu.Lock()
+	compaction := map[string]interface{}{
+		"runs":              api.compaction.runs,
+		"tombstones_purged": api.compaction.total.TombstonesPurged,
+		"bytes_reclaimed":   api.compaction.total.BytesReclaimed,
+		"retention_seconds": api.compaction.retention.Seconds(),
+	}
+	if !api.compaction.lastRun.IsZero() {
+		compaction["last_run"] = api.compaction.lastRun.UTC().Format(time.RFC3339)
+	}
+	api.compaction.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"collections": len(collections),
+		"vectors":     vectors,
+		"compaction":  compaction,
+		"status":      "ok",
+	})
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"course/models"
+)
+
+// Stable error codes reported in error responses. Clients should branch on
+// these rather than on messages, which may change.
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"      // Malformed or invalid parameters
+	CodeInvalidBody        = "INVALID_BODY"         // Request body could not be decoded
+	CodeBodyTooLarge       = "BODY_TOO_LARGE"       // Request body exceeds the configured limit
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"   // Endpoint does not support the method
+	CodeNotFound           = "NOT_FOUND"            // No such endpoint
+	CodeCollectionNotFound = "COLLECTION_NOT_FOUND" // No collection with the given name
+	CodeVectorNotFound     = "VECTOR_NOT_FOUND"     // No live vector with the given ID
+	CodeBadDimension       = "BAD_DIMENSION"        // Vector or query has the wrong dimension
+	CodeInvalidID          = "INVALID_ID"           // Vector ID is empty or too long
+	CodeZeroVector         = "ZERO_VECTOR"          // Zero vector refused by the collection
+	CodeDuplicateID        = "DUPLICATE_ID"         // Vector ID already in use
+	CodeVersionConflict    = "VERSION_CONFLICT"     // Optimistic concurrency check failed
+	CodeCapacityExceeded   = "CAPACITY_EXCEEDED"    // Collection is at its vector limit
+	CodeConflict           = "CONFLICT"             // Resource already exists or is in the wrong state
+	CodeSchemaViolation    = "SCHEMA_VIOLATION"     // Existing vectors do not conform to a new schema
+	CodeReadOnly           = "READ_ONLY"            // Node is in read-only mode
+	CodeOverloaded         = "OVERLOADED"           // Node is at its concurrent search limit; retry later
+	CodeNotImplemented     = "NOT_IMPLEMENTED"      // Endpoint is not implemented yet
+	CodeInternal           = "INTERNAL"             // Unexpected server-side failure
+)
+
+// errNotImplemented is wrapped by errors for query features that are not
+// implemented yet
+var errNotImplemented = errors.New("not implemented")
+
+// errorBody is the JSON envelope of every error response
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+// errorDetail describes an error with a stable code and a readable message
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError responds with the given status and a JSON error envelope:
+// {"error": {"code": ..., "message": ...}}
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeCollectionError responds with an error from the collection layer,
+// choosing the status and code from the sentinel error it wraps. Errors that
+// wrap none are unexpected failures and reported as 500 INTERNAL.
+func writeCollectionError(w http.ResponseWriter, err error) {
+	writeError(w, errorStatus(err), errorCode(err), err.Error())
+}
+
+// errorCode maps an error from the collection layer to an error code
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, models.ErrVectorNotFound):
+		return CodeVectorNotFound
+	case errors.Is(err, models.ErrDimensionMismatch):
+		return CodeBadDimension
+	case errors.Is(err, models.ErrInvalidID):
+		return CodeInvalidID
+	case errors.Is(err, models.ErrZeroVector):
+		return CodeZeroVector
+	case errors.Is(err, models.ErrDuplicateID):
+		return CodeDuplicateID
+	case errors.Is(err, models.ErrVersionConflict):
+		return CodeVersionConflict
+	case errors.Is(err, models.ErrCapacityExceeded):
+		return CodeCapacityExceeded
+	case errors.Is(err, models.ErrSchemaViolation):
+		return CodeSchemaViolation
+	case errors.Is(err, models.ErrAlreadyProjected):
+		return CodeConflict
+	case errors.Is(err, models.ErrInvalidArgument), errors.Is(err, models.ErrInvalidSearchParams):
+		return CodeInvalidRequest
+	case errors.Is(err, errNotImplemented):
+		return CodeNotImplemented
+	default:
+		return CodeInternal
+	}
+}
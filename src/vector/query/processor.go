@@ -1,15 +1,19 @@
 package query
 
 import (
-	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 
 	"course/models"
+	"course/vector"
 )
 
 // Processor handles vector search queries with different strategies
 type Processor struct {
 	collection *models.VectorCollection
+	recall     *RecallMonitor // Optional; samples vector searches for recall
 }
 
 // NewProcessor creates a new query processor for a vector collection
@@ -19,22 +23,79 @@ func NewProcessor(collection *models.VectorCollection) *Processor {
 	}
 }
 
+// SetRecallMonitor makes the processor re-run a sample of its vector searches
+// exactly to track recall. A nil monitor disables sampling.
+func (p *Processor) SetRecallMonitor(monitor *RecallMonitor) {
+	p.recall = monitor
+}
+
+// RecallMonitor returns the processor's recall monitor, or nil if none is set
+func (p *Processor) RecallMonitor() *RecallMonitor {
+	return p.recall
+}
+
+// Explain validates the request and returns the plan the collection would
+// follow for it, after applying the request's search strategy, without
+// running the search
+func (p *Processor) Explain(request *models.QueryRequest) (*models.QueryPlan, error) {
+	if err := p.validateRequest(request); err != nil {
+		return nil, err
+	}
+
+	params := models.SearchParams{SearchStrategy: models.Default}
+	if request.Params != nil {
+		params = *request.Params
+	}
+	if request.Metric != nil {
+		params.Metric = request.Metric
+	}
+	p.adjustSearchParams(&params)
+
+	planned := *request
+	planned.Params = &params
+	return p.collection.Explain(&planned)
+}
+
 // ProcessQuery handles a unified query request, dispatching it to the appropriate handler
 func (p *Processor) ProcessQuery(request *models.QueryRequest) (interface{}, error) {
+	if err := p.prepareRequest(request); err != nil {
+		return nil, err
+	}
+	return p.dispatch(request)
+}
+
+// prepareRequest validates a request and fills in its search parameters
+func (p *Processor) prepareRequest(request *models.QueryRequest) error {
 	// Validate request
 	if err := p.validateRequest(request); err != nil {
-		return nil, err
+		return err
 	}
 
-	// Initialize search parameters if not provided
+	// Initialize search parameters if not provided. Given params may be a
+	// shared preset, so the request's own settings go into a copy.
 	if request.Params == nil {
 		request.Params = &models.SearchParams{
 			SearchStrategy: models.Default,
-			HnswEf:        100,
+			HnswEf:        models.DefaultHnswEf,
 		}
+	} else {
+		params := *request.Params
+		request.Params = &params
+	}
+	if request.Explain {
+		request.Params.Explain = true
+	}
+	if request.Metric != nil {
+		request.Params.Metric = request.Metric
 	}
+	if request.Timeout > 0 {
+		request.Params.Timeout = request.Timeout
+	}
+	return nil
+}
 
-	// Determine which operation to perform based on query type
+// dispatch runs a prepared request with the handler for its query type
+func (p *Processor) dispatch(request *models.QueryRequest) (interface{}, error) {
 	switch {
 	case request.Vector != nil:
 		// Vector similarity search (kNN)
@@ -52,20 +113,29 @@ func (p *Processor) ProcessQuery(request *models.QueryRequest) (interface{}, err
 		// Random sampling
 		return p.processSample(request)
 	default:
-		return nil, errors.New("invalid query: no query type specified")
+		return nil, fmt.Errorf("invalid query: no query type specified: %w", models.ErrInvalidArgument)
 	}
 }
 
 // validateRequest checks if the query request is valid
 func (p *Processor) validateRequest(request *models.QueryRequest) error {
 	if request == nil {
-		return errors.New("request cannot be nil")
+		return fmt.Errorf("request cannot be nil: %w", models.ErrInvalidArgument)
 	}
 
 	// Check for valid limit
 	if request.Limit <= 0 {
 		request.Limit = 10 // Default limit
 	}
+	
+	// Every page requires ranking all the results before it, so deep pages
+	// are as expensive as a search with a huge limit
+	if request.Offset < 0 {
+		return fmt.Errorf("offset cannot be negative: %w", models.ErrInvalidArgument)
+	}
+	if request.Offset > maxQueryOffset {
+		return fmt.Errorf("offset %d exceeds the maximum of %d: %w", request.Offset, maxQueryOffset, models.ErrInvalidArgument)
+	}
 
 	// Check that exactly one query type is specified
 	queryTypes := 0
@@ -86,16 +156,35 @@ func (p *Processor) validateRequest(request *models.QueryRequest) error {
 	}
 
 	if queryTypes == 0 {
-		return errors.New("no query type specified")
+		return fmt.Errorf("no query type specified: %w", models.ErrInvalidArgument)
 	}
 	if queryTypes > 1 {
-		return errors.New("multiple query types specified, only one is allowed")
+		return fmt.Errorf("multiple query types specified, only one is allowed: %w", models.ErrInvalidArgument)
 	}
 
 	// Validate specific query types
 	if request.Vector != nil && len(request.Vector) != p.collection.Dimension {
-		return fmt.Errorf("query vector dimension %d does not match collection dimension %d", 
-			len(request.Vector), p.collection.Dimension)
+		return fmt.Errorf("query vector dimension %d does not match collection dimension %d: %w", 
+			len(request.Vector), p.collection.Dimension, models.ErrDimensionMismatch)
+	}
+
+	if err := request.Filter.Validate(); err != nil {
+		return err
+	}
+
+	// Validate the metric override, if any
+	metric := request.Metric
+	if metric == nil && request.Params != nil {
+		metric = request.Params.Metric
+	}
+	if metric != nil {
+		if _, err := vector.GetDistanceFunc(*metric); err != nil {
+			return fmt.Errorf("invalid metric override: %v: %w", err, models.ErrInvalidArgument)
+		}
+	}
+
+	if err := p.validateBoosts(request.Boosts); err != nil {
+		return err
 	}
 
 	if request.GroupBy != "" && (request.GroupSize <= 0 || request.GroupLimit <= 0) {
@@ -110,17 +199,62 @@ func (p *Processor) validateRequest(request *models.QueryRequest) error {
 func (p *Processor) processVectorSearch(request *models.QueryRequest) (interface{}, error) {
 	// Adjust search parameters based on strategy
 	p.adjustSearchParams(request.Params)
+	query := p.prepareQuery(request)
+
+	pageEnd, limit := searchLimits(request)
 
 	// Perform the search
-	results, err := p.collection.Search(
-		request.Vector,
-		request.Limit,
+	results, partial, err := p.collection.SearchPartial(
+		query,
+		limit,
 		request.Filter,
 		request.Params,
 	)
 	if err != nil {
 		return nil, err
 	}
+	request.Partial = partial
+	if p.recall != nil {
+		served := results
+		if len(served) > pageEnd {
+			served = served[:pageEnd]
+		}
+		p.recall.observe(p.collection, query, pageEnd, request.Filter, request.Params, partial, served)
+	}
+
+	return p.finishVectorSearch(request, results)
+}
+
+// searchLimits returns the end of the requested page and the number of
+// results to rank. Every result up to the end of the page is ranked,
+// over-fetching when deduplicating or boosting so enough distinct items
+// survive and boosted items from beyond the page can move into it.
+func searchLimits(request *models.QueryRequest) (pageEnd, limit int) {
+	pageEnd = request.Offset + request.Limit
+	limit = pageEnd
+	if request.DedupBy != "" || len(request.Boosts) > 0 {
+		limit *= dedupOversample
+	}
+	return pageEnd, limit
+}
+
+// finishVectorSearch turns the ranked results of a vector search into the
+// response: boosting, deduplicating, paging and grouping them as requested
+func (p *Processor) finishVectorSearch(request *models.QueryRequest, results []models.SearchResult) (interface{}, error) {
+	pageEnd, _ := searchLimits(request)
+	var err error
+	if len(request.Boosts) > 0 {
+		if results, err = boostResults(results, request.Boosts); err != nil {
+			return nil, err
+		}
+	}
+	if request.DedupBy != "" {
+		results = dedupResults(results, request.DedupBy)
+	}
+	if len(results) > pageEnd {
+		results = results[:pageEnd]
+	}
+	request.Scanned = len(results)
 
 	// Handle grouping if requested
 	if request.GroupBy != "" {
@@ -131,6 +265,25 @@ func (p *Processor) processVectorSearch(request *models.QueryRequest) (interface
 	return p.postProcessResults(results, request)
 }
 
+// prepareQuery returns the query vector to search with, normalized to unit
+// length if requested. Cosine queries are left as they are, since the
+// collection and its indexes normalize them wherever the ranking depends on
+// it, including before a projection. For other metrics normalizing is off by
+// default since it changes the ranking.
+func (p *Processor) prepareQuery(request *models.QueryRequest) []float32 {
+	metric := p.collection.DistanceFunc
+	if request.Params.Metric != nil {
+		metric = *request.Params.Metric
+	}
+	if metric == models.Cosine || request.Normalize == nil || !*request.Normalize {
+		return request.Vector
+	}
+	
+	query := append([]float32(nil), request.Vector...)
+	vector.NormalizeVector(query)
+	return query
+}
+
 // processPointIDSearch handles search by existing point ID
 func (p *Processor) processPointIDSearch(request *models.QueryRequest) (interface{}, error) {
 	// This is a stub implementation
@@ -138,18 +291,108 @@ func (p *Processor) processPointIDSearch(request *models.QueryRequest) (interfac
 	// 1. Retrieve the vector with the given ID
 	// 2. Use that vector as a query for a similarity search
 	
-	return nil, errors.New("search by point ID not implemented yet")
+	return nil, fmt.Errorf("search by point ID: %w", errNotImplemented)
 }
 
 // processRecommendation handles recommendation by examples
 func (p *Processor) processRecommendation(request *models.QueryRequest) (interface{}, error) {
-	// This is a stub implementation
-	// In a real implementation, we would:
-	// 1. Retrieve the vectors for the positive and negative examples
-	// 2. Combine them according to the recommendation strategy
-	// 3. Use the combined vector as a query for a similarity search
+	recommend := request.Recommend
+	if len(recommend.Positive) == 0 {
+		return nil, fmt.Errorf("recommendation requires at least one positive example: %w", models.ErrInvalidArgument)
+	}
+	
+	// Build the query as the positive centroid minus the negative centroid,
+	// or for arithmetic (analogies such as king - man + woman) as the sum of
+	// the positive examples minus the sum of the negative ones
+	combine := p.centroid
+	switch recommend.Strategy {
+	case "", models.RecommendAverage:
+	case models.RecommendArithmetic:
+		combine = p.sum
+	default:
+		return nil, fmt.Errorf("unsupported recommendation strategy %s: %w", recommend.Strategy, models.ErrInvalidArgument)
+	}
+	query, err := combine(recommend.Positive)
+	if err != nil {
+		return nil, err
+	}
+	if len(recommend.Negative) > 0 {
+		negative, err := combine(recommend.Negative)
+		if err != nil {
+			return nil, err
+		}
+		for i := range query {
+			query[i] -= negative[i]
+		}
+	}
+	
+	// The examples themselves are never recommended
+	exclude := make(map[string]bool, len(recommend.Positive)+len(recommend.Negative))
+	for _, id := range recommend.Positive {
+		exclude[id] = true
+	}
+	for _, id := range recommend.Negative {
+		exclude[id] = true
+	}
+	
+	p.adjustSearchParams(request.Params)
+	pageEnd := request.Offset + request.Limit
+	limit := pageEnd
+	if len(request.Boosts) > 0 {
+		limit *= dedupOversample
+	}
+	results, err := p.collection.Search(query, limit+len(exclude), request.Filter, request.Params)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Boosts) > 0 {
+		if results, err = boostResults(results, request.Boosts); err != nil {
+			return nil, err
+		}
+	}
+	
+	filtered := make([]models.SearchResult, 0, len(results))
+	for _, result := range results {
+		if !exclude[result.ID] {
+			filtered = append(filtered, result)
+		}
+	}
+	if len(filtered) > pageEnd {
+		filtered = filtered[:pageEnd]
+	}
+	request.Scanned = len(filtered)
 	
-	return nil, errors.New("recommendation search not implemented yet")
+	return p.postProcessResults(filtered, request)
+}
+
+// sum returns the sum of the stored vectors with the given IDs, in the space
+// the vectors are stored in (reduced for projected collections)
+func (p *Processor) sum(ids []string) ([]float32, error) {
+	sum := make([]float32, p.collection.IndexDimension())
+	for _, id := range ids {
+		vector, ok := p.collection.GetByID(id)
+		if !ok {
+			return nil, fmt.Errorf("vector %s: %w", id, models.ErrVectorNotFound)
+		}
+		for i, val := range vector.Values {
+			sum[i] += val
+		}
+	}
+	return sum, nil
+}
+
+// centroid returns the mean of the stored vectors with the given IDs, in the
+// space the vectors are stored in (reduced for projected collections)
+func (p *Processor) centroid(ids []string) ([]float32, error) {
+	centroid, err := p.sum(ids)
+	if err != nil {
+		return nil, err
+	}
+	
+	for i := range centroid {
+		centroid[i] /= float32(len(ids))
+	}
+	return centroid, nil
 }
 
 // processScroll handles pagination through all points
@@ -159,7 +402,7 @@ func (p *Processor) processScroll(request *models.QueryRequest) (interface{}, er
 	// 1. Use the offset as a cursor to determine where to start
 	// 2. Return a page of results and a new cursor
 	
-	return nil, errors.New("scroll not implemented yet")
+	return nil, fmt.Errorf("scroll: %w", errNotImplemented)
 }
 
 // processSample handles random sampling
@@ -169,7 +412,7 @@ func (p *Processor) processSample(request *models.QueryRequest) (interface{}, er
 	// 1. Randomly select 'limit' vectors from the collection
 	// 2. Apply filters if provided
 	
-	return nil, errors.New("random sampling not implemented yet")
+	return nil, fmt.Errorf("random sampling: %w", errNotImplemented)
 }
 
 // adjustSearchParams modifies search parameters based on the search strategy
@@ -181,35 +424,38 @@ func (p *Processor) adjustSearchParams(params *models.SearchParams) {
 	case models.FastSearch:
 		params.Exact = false
 		if params.HnswEf == 0 {
-			params.HnswEf = 40 // Lower ef for faster search
+			params.HnswEf = models.FastHnswEf // Lower ef for faster search
 		}
 	case models.PreciseSearch:
 		params.Exact = false
 		if params.HnswEf == 0 {
-			params.HnswEf = 300 // Higher ef for more accurate search
+			params.HnswEf = models.PreciseHnswEf // Higher ef for more accurate search
 		}
 	case models.BatchSearch:
-		// BatchSearch is handled differently, no special params
+		// No special params: ProcessBatchQuery serves batches of these
+		// searches from a single shared scan
 	default: // Default strategy
 		params.Exact = false
 		if params.HnswEf == 0 {
-			params.HnswEf = 100 // Default ef value
+			params.HnswEf = models.DefaultHnswEf // Default ef value
 		}
 	}
 }
 
 // postProcessResults applies post-processing to search results
 func (p *Processor) postProcessResults(results []models.SearchResult, request *models.QueryRequest) (interface{}, error) {
-	// Apply offset if provided
-	if request.Offset > 0 && request.Offset < len(results) {
+	// Apply offset if provided; a page past the last result is empty
+	if request.Offset >= len(results) {
+		results = results[:0]
+	} else if request.Offset > 0 {
 		results = results[request.Offset:]
 	}
 
 	// Filter results by score threshold if provided
-	if request.Params != nil && request.Params.ScoreThreshold > 0 {
+	if floor := p.collection.ScoreFloor(request.Params); floor > 0 {
 		filteredResults := make([]models.SearchResult, 0, len(results))
 		for _, result := range results {
-			if result.Score >= request.Params.ScoreThreshold {
+			if result.Score >= floor {
 				filteredResults = append(filteredResults, result)
 			}
 		}
@@ -238,6 +484,121 @@ func (p *Processor) postProcessResults(results []models.SearchResult, request *m
 	return results, nil
 }
 
+// maxQueryOffset is the deepest page offset a query may request
+const maxQueryOffset = 10000
+
+// dedupOversample is how many candidates per requested result are fetched
+// when deduplicating, since collapsed duplicates don't count towards the
+// limit, or boosting, since boosts can promote results from beyond the page
+const dedupOversample = 4
+
+// dedupResults collapses results that share the same value of a metadata field,
+// keeping the best-ranked one of each. Results are assumed to be sorted best
+// first; results without the field are always kept.
+func dedupResults(results []models.SearchResult, field string) []models.SearchResult {
+	path := strings.Split(field, ".")
+	seen := make(map[string]bool)
+	deduped := make([]models.SearchResult, 0, len(results))
+	
+	for _, result := range results {
+		if result.Vector != nil {
+			if value, ok := lookupField(result.Vector.Metadata, path); ok {
+				key := fmt.Sprintf("%T:%v", value, value)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+		}
+		deduped = append(deduped, result)
+	}
+	
+	return deduped
+}
+
+// validateBoosts checks that boost fields are named, weights are finite, and
+// fields declared in the collection's schema are numeric
+func (p *Processor) validateBoosts(boosts map[string]float64) error {
+	if len(boosts) == 0 {
+		return nil
+	}
+	schema := p.collection.Schema()
+	for field, weight := range boosts {
+		if field == "" {
+			return fmt.Errorf("boost field name is required: %w", models.ErrInvalidArgument)
+		}
+		if math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("boost weight for field %s must be finite: %w", field, models.ErrInvalidArgument)
+		}
+		if schema != nil {
+			if fieldType, declared := schema.Fields[field]; declared && fieldType != models.NumberField {
+				return fmt.Errorf("boost field %s is not numeric: %w", field, models.ErrInvalidArgument)
+			}
+		}
+	}
+	return nil
+}
+
+// boostResults re-ranks results by their similarity score plus the weighted
+// values of the boost fields in their metadata. Missing fields contribute
+// nothing; non-numeric values are an error. Ties keep the similarity order.
+func boostResults(results []models.SearchResult, boosts map[string]float64) ([]models.SearchResult, error) {
+	paths := make(map[string][]string, len(boosts))
+	for field := range boosts {
+		paths[field] = strings.Split(field, ".")
+	}
+	
+	for i := range results {
+		var boost float64
+		if results[i].Vector != nil {
+			for field, weight := range boosts {
+				value, ok := lookupField(results[i].Vector.Metadata, paths[field])
+				if !ok {
+					continue
+				}
+				number, ok := models.NumericValue(value)
+				if !ok {
+					return nil, fmt.Errorf("boost field %s of vector %s is not numeric: %w", field, results[i].ID, models.ErrInvalidArgument)
+				}
+				boost += weight * number
+			}
+		}
+		if boost == 0 {
+			continue
+		}
+		
+		if results[i].Explain != nil {
+			explain := make(map[string]interface{}, len(results[i].Explain)+1)
+			for key, value := range results[i].Explain {
+				explain[key] = value
+			}
+			explain["boost"] = boost
+			results[i].Explain = explain
+		}
+		results[i].Score += float32(boost)
+	}
+	
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	return results, nil
+}
+
+// lookupField retrieves a (possibly nested) metadata value
+func lookupField(metadata map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = metadata
+	for _, part := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
 // groupResults groups search results by a metadata field
 func (p *Processor) groupResults(results []models.SearchResult, request *models.QueryRequest) (interface{}, error) {
 	// This is a stub implementation for grouping
@@ -246,7 +607,7 @@ func (p *Processor) groupResults(results []models.SearchResult, request *models.
 	// 2. Apply group size and limit constraints
 	// 3. Sort groups by the best result in each group
 	
-	return nil, errors.New("grouping not implemented yet")
+	return nil, fmt.Errorf("grouping: %w", errNotImplemented)
 }
 
 // isWithPayload determines if payload (metadata) should be included in results
@@ -0,0 +1,606 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"course/models"
+	"course/vector/index"
+)
+
+// newTestCollection creates a collection backed by a linear index and seeded with vectors
+func newTestCollection(t *testing.T, dimension int, metric models.DistanceMetric, vectors ...*models.Vector) *models.VectorCollection {
+	collection := models.NewVectorCollection("test", dimension, metric)
+
+	linearIndex, err := index.NewLinearIndex(dimension, metric)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	if err := collection.AddIndex("linear", linearIndex); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	for _, v := range vectors {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+	return collection
+}
+
+// labeledVectors returns vectors clustered in two regions, labeled by region
+func labeledVectors() []*models.Vector {
+	return []*models.Vector{
+		models.NewVector("a1", []float32{1, 0.1, 0}, map[string]interface{}{"region": "A"}),
+		models.NewVector("a2", []float32{0.9, 0.2, 0}, map[string]interface{}{"region": "A"}),
+		models.NewVector("a3", []float32{1, 0, 0.1}, map[string]interface{}{"region": "A"}),
+		models.NewVector("b1", []float32{0.1, 1, 0}, map[string]interface{}{"region": "B"}),
+		models.NewVector("b2", []float32{0.2, 0.9, 0}, map[string]interface{}{"region": "B"}),
+		models.NewVector("b3", []float32{0, 1, 0.1}, map[string]interface{}{"region": "B"}),
+	}
+}
+
+func TestRecommendation(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	processor := NewProcessor(collection)
+
+	result, err := processor.ProcessQuery(&models.QueryRequest{
+		Recommend: &models.RecommendParams{
+			Positive: []string{"a1"},
+			Negative: []string{"b1"},
+		},
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatalf("Recommendation failed: %v", err)
+	}
+
+	results := result.([]models.SearchResult)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 recommendations, got %d", len(results))
+	}
+	for _, res := range results {
+		if res.ID == "a1" || res.ID == "b1" {
+			t.Errorf("Example %s should be excluded from recommendations", res.ID)
+		}
+		if !strings.HasPrefix(res.ID, "a") {
+			t.Errorf("Expected recommendations from the positive region, got %s", res.ID)
+		}
+	}
+}
+
+func TestRecommendationMissingExample(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	processor := NewProcessor(collection)
+
+	_, err := processor.ProcessQuery(&models.QueryRequest{
+		Recommend: &models.RecommendParams{Positive: []string{"missing"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not-found error for a missing example, got %v", err)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	processor := NewProcessor(collection)
+	filter := models.NewAndFilter(models.NewEqualsCondition("region", "A"))
+
+	result, err := processor.ProcessQuery(&models.QueryRequest{
+		Vector: []float32{1, 0, 0},
+		Limit:  3,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, res := range result.([]models.SearchResult) {
+		if res.Explain != nil {
+			t.Errorf("Expected no explain payload by default, got %v", res.Explain)
+		}
+	}
+
+	result, err = processor.ProcessQuery(&models.QueryRequest{
+		Vector:  []float32{1, 0, 0},
+		Limit:   3,
+		Filter:  filter,
+		Explain: true,
+	})
+	if err != nil {
+		t.Fatalf("Search with explain failed: %v", err)
+	}
+
+	results := result.([]models.SearchResult)
+	if len(results) == 0 {
+		t.Fatalf("Expected results")
+	}
+	for _, res := range results {
+		if res.Explain == nil {
+			t.Fatalf("Expected explain payload for %s", res.ID)
+		}
+		if res.Explain["distance"] != res.Distance || res.Explain["score"] != res.Score {
+			t.Errorf("Explain payload does not match result: %v", res.Explain)
+		}
+		if res.Explain["index"] != "linear" {
+			t.Errorf("Expected index linear, got %v", res.Explain["index"])
+		}
+		if res.Explain["filter_evaluated"] != true {
+			t.Errorf("Expected filter_evaluated to be true, got %v", res.Explain["filter_evaluated"])
+		}
+	}
+}
+
+func TestMetricOverride(t *testing.T) {
+	collection := newTestCollection(t, 2, models.Cosine,
+		models.NewVector("far", []float32{10, 0}, nil),
+		models.NewVector("near", []float32{1, 0.5}, nil),
+	)
+	processor := NewProcessor(collection)
+
+	search := func(metric *models.DistanceMetric) []models.SearchResult {
+		result, err := processor.ProcessQuery(&models.QueryRequest{
+			Vector: []float32{1, 0},
+			Limit:  2,
+			Metric: metric,
+		})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return result.([]models.SearchResult)
+	}
+
+	// Cosine only looks at direction, so the distant but aligned vector wins
+	if results := search(nil); results[0].ID != "far" {
+		t.Errorf("Expected far to rank first under cosine, got %s", results[0].ID)
+	}
+
+	// Euclidean looks at the raw vectors, so the nearby vector wins
+	euclidean := models.Euclidean
+	results := search(&euclidean)
+	if results[0].ID != "near" {
+		t.Errorf("Expected near to rank first under euclidean override, got %s", results[0].ID)
+	}
+	if results[1].Distance != 9 {
+		t.Errorf("Expected raw euclidean distance 9 for far, got %v", results[1].Distance)
+	}
+
+	invalid := models.DistanceMetric(42)
+	_, err := processor.ProcessQuery(&models.QueryRequest{
+		Vector: []float32{1, 0},
+		Metric: &invalid,
+	})
+	if err == nil {
+		t.Errorf("Expected an unsupported metric override to be rejected")
+	}
+}
+
+func TestDedupBy(t *testing.T) {
+	collection := newTestCollection(t, 2, models.Cosine,
+		models.NewVector("doc1-a", []float32{1, 0}, map[string]interface{}{"doc": "doc1"}),
+		models.NewVector("doc1-b", []float32{0.99, 0.05}, map[string]interface{}{"doc": "doc1"}),
+		models.NewVector("doc1-c", []float32{0.98, 0.1}, map[string]interface{}{"doc": "doc1"}),
+		models.NewVector("doc2-a", []float32{0.9, 0.3}, map[string]interface{}{"doc": "doc2"}),
+		models.NewVector("doc2-b", []float32{0.85, 0.35}, map[string]interface{}{"doc": "doc2"}),
+		models.NewVector("doc3-a", []float32{0.5, 0.5}, map[string]interface{}{"doc": "doc3"}),
+	)
+	processor := NewProcessor(collection)
+
+	result, err := processor.ProcessQuery(&models.QueryRequest{
+		Vector:      []float32{1, 0},
+		Limit:       3,
+		DedupBy:     "doc",
+		WithVectors: true,
+		WithPayload: true,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	results := result.([]models.SearchResult)
+	expected := []string{"doc1-a", "doc2-a", "doc3-a"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d deduplicated results, got %d", len(expected), len(results))
+	}
+	for i, res := range results {
+		if res.ID != expected[i] {
+			t.Errorf("Result %d: expected %s, got %s", i, expected[i], res.ID)
+		}
+	}
+}
+
+func TestBoosts(t *testing.T) {
+	collection := newTestCollection(t, 2, models.Cosine,
+		models.NewVector("close", []float32{1, 0}, map[string]interface{}{"rating": 1.0, "label": "x"}),
+		models.NewVector("near", []float32{0.9, 0.3}, map[string]interface{}{"rating": 2.0}),
+		models.NewVector("far", []float32{0.5, 0.5}, map[string]interface{}{"rating": 5.0}),
+	)
+	collection.MetadataSchema.AddField("label", models.StringField)
+	processor := NewProcessor(collection)
+
+	search := func(boosts map[string]float64) ([]string, error) {
+		result, err := processor.ProcessQuery(&models.QueryRequest{
+			Vector:      []float32{1, 0},
+			Limit:       3,
+			Boosts:      boosts,
+			WithPayload: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, res := range result.([]models.SearchResult) {
+			ids = append(ids, res.ID)
+		}
+		return ids, nil
+	}
+
+	plain, err := search(nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if fmt.Sprint(plain) != "[close near far]" {
+		t.Fatalf("Expected pure similarity order [close near far], got %v", plain)
+	}
+
+	boosted, err := search(map[string]float64{"rating": 0.1})
+	if err != nil {
+		t.Fatalf("Boosted search failed: %v", err)
+	}
+	if fmt.Sprint(boosted) != "[far near close]" {
+		t.Errorf("Expected the rating boost to reorder results to [far near close], got %v", boosted)
+	}
+
+	if _, err := search(map[string]float64{"label": 1}); err == nil {
+		t.Errorf("Expected an error boosting a string field declared in the schema")
+	}
+	if _, err := search(map[string]float64{"rating": math.Inf(1)}); err == nil {
+		t.Errorf("Expected an error for an infinite weight")
+	}
+
+	// Values are checked when the field isn't declared in the schema
+	if err := collection.Insert(models.NewVector("odd", []float32{1, 0.1}, map[string]interface{}{"rating": "high"})); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := search(map[string]float64{"rating": 0.1}); err == nil {
+		t.Errorf("Expected an error for a non-numeric boost value")
+	}
+}
+
+func TestPagination(t *testing.T) {
+	vectors := make([]*models.Vector, 25)
+	for i := range vectors {
+		vectors[i] = models.NewVector(fmt.Sprintf("v%02d", i), []float32{float32(i), 0}, nil)
+	}
+	processor := NewProcessor(newTestCollection(t, 2, models.Euclidean, vectors...))
+
+	page := func(offset int) []models.SearchResult {
+		request := &models.QueryRequest{Vector: []float32{0, 0}, Limit: 10, Offset: offset}
+		result, err := processor.ProcessQuery(request)
+		if err != nil {
+			t.Fatalf("Search with offset %d failed: %v", offset, err)
+		}
+		if expected := offset + 10; expected <= len(vectors) && request.Scanned != expected {
+			t.Errorf("Expected %d results scanned, got %d", expected, request.Scanned)
+		}
+		return result.([]models.SearchResult)
+	}
+
+	first, second := page(0), page(10)
+	if len(first) != 10 || len(second) != 10 {
+		t.Fatalf("Expected two full pages, got %d and %d results", len(first), len(second))
+	}
+	for i, res := range append(first, second...) {
+		if expected := fmt.Sprintf("v%02d", i); res.ID != expected {
+			t.Errorf("Position %d: expected %s, got %s", i, expected, res.ID)
+		}
+	}
+
+	if last := page(20); len(last) != 5 {
+		t.Errorf("Expected a final page of 5 results, got %d", len(last))
+	}
+	if beyond := page(30); len(beyond) != 0 {
+		t.Errorf("Expected an empty page past the end, got %d results", len(beyond))
+	}
+
+	for _, offset := range []int{-1, maxQueryOffset + 1} {
+		_, err := processor.ProcessQuery(&models.QueryRequest{Vector: []float32{0, 0}, Offset: offset})
+		if err == nil {
+			t.Errorf("Expected offset %d to be rejected", offset)
+		}
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	search := func(processor *Processor, query []float32, normalize *bool) []models.SearchResult {
+		result, err := processor.ProcessQuery(&models.QueryRequest{
+			Vector:    query,
+			Limit:     6,
+			Normalize: normalize,
+		})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return result.([]models.SearchResult)
+	}
+	on, off := true, false
+
+	// Cosine is scale-invariant, so the flag must not change the results
+	cosine := NewProcessor(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	raw := []float32{3, 1.5, 0.6}
+	normalized := search(cosine, raw, &on)
+	for _, results := range [][]models.SearchResult{search(cosine, raw, &off), search(cosine, raw, nil)} {
+		if len(results) != len(normalized) {
+			t.Fatalf("Expected %d results, got %d", len(normalized), len(results))
+		}
+		for i := range results {
+			if results[i].ID != normalized[i].ID || math.Abs(float64(results[i].Score-normalized[i].Score)) > 1e-5 {
+				t.Errorf("Result %d differs: %+v vs %+v", i, results[i], normalized[i])
+			}
+		}
+	}
+
+	// For other metrics normalization is opt-in and changes distances
+	euclidean := NewProcessor(newTestCollection(t, 3, models.Euclidean, labeledVectors()...))
+	query := []float32{10, 0, 0}
+	if d := search(euclidean, query, nil)[0].Distance; math.Abs(float64(d)-9) > 1e-2 {
+		t.Errorf("Expected the raw query to be used by default, got distance %f", d)
+	}
+	if d := search(euclidean, query, &on)[0].Distance; d > 0.2 {
+		t.Errorf("Expected the normalized query to be close to a1, got distance %f", d)
+	}
+}
+
+func TestProjectedCosineQueryScale(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Cosine, labeledVectors()...)
+	reduced, err := index.NewLinearIndex(2, models.Cosine)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	// The mean is subtracted before projecting, so scaling a query changes
+	// the direction of its projection unless it is normalized afterwards
+	projection := &models.PCAProjection{
+		Mean:       []float32{0.5, 0.5, 0},
+		Components: [][]float32{{1, 0, 0}, {0, 1, 0}},
+	}
+	rebuild := func(string, models.VectorIndex) (models.VectorIndex, error) { return reduced, nil }
+	if err := collection.ApplyProjection(projection, rebuild); err != nil {
+		t.Fatalf("ApplyProjection failed: %v", err)
+	}
+	processor := NewProcessor(collection)
+
+	search := func(query []float32, normalize *bool) []models.SearchResult {
+		result, err := processor.ProcessQuery(&models.QueryRequest{Vector: query, Limit: 6, Normalize: normalize})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return result.([]models.SearchResult)
+	}
+	on, off := true, false
+	small := search([]float32{1, 0.4, 0}, nil)
+	for _, large := range [][]models.SearchResult{
+		search([]float32{10, 4, 0}, nil),
+		search([]float32{10, 4, 0}, &on),
+		search([]float32{10, 4, 0}, &off),
+	} {
+		if len(small) != len(large) {
+			t.Fatalf("Expected %d results, got %d", len(small), len(large))
+		}
+		for i := range small {
+			if small[i].ID != large[i].ID || math.Abs(float64(small[i].Score-large[i].Score)) > 1e-5 {
+				t.Errorf("Result %d differs with the query scale: %+v vs %+v", i, small[i], large[i])
+			}
+		}
+	}
+}
+
+func TestExplainPlan(t *testing.T) {
+	collection := models.NewVectorCollection("test", 3, models.Cosine)
+	linearIndex, _ := index.NewLinearIndex(3, models.Cosine)
+	lshIndex, err := index.NewLSHIndex(3, models.Cosine, 4, 4)
+	if err != nil {
+		t.Fatalf("Failed to create LSH index: %v", err)
+	}
+	collection.AddIndex("exact", linearIndex)
+	collection.AddIndex("lsh", lshIndex)
+	for _, v := range labeledVectors() {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+	processor := NewProcessor(collection)
+
+	plan, err := processor.Explain(&models.QueryRequest{
+		Vector: []float32{1, 0, 0},
+		Filter: models.NewAndFilter(models.NewEqualsCondition("region", "A")),
+	})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if plan.Index != "lsh" || plan.Exact {
+		t.Errorf("Expected the approximate index by default, got %+v", plan)
+	}
+	if plan.FilterConditions != 1 || plan.FilterSelectivity != 0.5 || plan.EstimatedCandidates != 3 {
+		t.Errorf("Expected half of the 6 vectors to pass the filter, got %+v", plan)
+	}
+	if plan.Rerank {
+		t.Errorf("Expected no re-ranking without boosts")
+	}
+
+	plan, err = processor.Explain(&models.QueryRequest{
+		Vector: []float32{1, 0, 0},
+		Params: &models.SearchParams{SearchStrategy: models.ExactSearch},
+		Boosts: map[string]float64{"rank": 0.1},
+	})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if plan.Index != "exact" || !plan.Exact {
+		t.Errorf("Expected an exact search to use the linear index, got %+v", plan)
+	}
+	if plan.FilterSelectivity != 1 || plan.EstimatedCandidates != 6 || !plan.Rerank {
+		t.Errorf("Expected an unfiltered, boosted plan over 6 vectors, got %+v", plan)
+	}
+
+	// Searches follow the plan
+	results, err := collection.Search([]float32{1, 0, 0}, 3, nil, &models.SearchParams{Exact: true})
+	if err != nil || len(results) != 3 {
+		t.Errorf("Expected the exact index to serve exact searches, got %v (%v)", results, err)
+	}
+}
+
+// randomCollection returns a processor over n random labeled vectors, and
+// random queries for it
+func randomCollection(tb testing.TB, n, queries, dimension int) (*Processor, [][]float32) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []float32 {
+		values := make([]float32, dimension)
+		for i := range values {
+			values[i] = rng.Float32()*2 - 1
+		}
+		return values
+	}
+
+	collection := models.NewVectorCollection("test", dimension, models.Euclidean)
+	linearIndex, _ := index.NewLinearIndex(dimension, models.Euclidean)
+	collection.AddIndex("linear", linearIndex)
+	for i := 0; i < n; i++ {
+		metadata := map[string]interface{}{"parity": i % 2}
+		if err := collection.Insert(models.NewVector(fmt.Sprintf("v%d", i), random(), metadata)); err != nil {
+			tb.Fatalf("Failed to insert vector: %v", err)
+		}
+	}
+
+	query := make([][]float32, queries)
+	for i := range query {
+		query[i] = random()
+	}
+	return NewProcessor(collection), query
+}
+
+func TestProcessBatchQuery(t *testing.T) {
+	processor, queries := randomCollection(t, 500, 8, 16)
+
+	requests := make([]*models.QueryRequest, len(queries))
+	for i, query := range queries {
+		requests[i] = &models.QueryRequest{
+			Vector: query,
+			Limit:  5,
+			Offset: i % 3,
+			Params: &models.SearchParams{SearchStrategy: models.BatchSearch},
+		}
+		if i%2 == 1 {
+			requests[i].Filter = models.NewAndFilter(models.NewEqualsCondition("parity", 1))
+		}
+	}
+	// Other queries in the batch are served as usual
+	requests = append(requests, &models.QueryRequest{Vector: queries[0], Limit: 5})
+
+	results, err := processor.ProcessBatchQuery(requests)
+	if err != nil {
+		t.Fatalf("Batch query failed: %v", err)
+	}
+	if len(results) != len(requests) {
+		t.Fatalf("Expected %d results, got %d", len(requests), len(results))
+	}
+
+	for i, request := range requests {
+		expected, err := processor.ProcessQuery(&models.QueryRequest{
+			Vector: request.Vector,
+			Limit:  request.Limit,
+			Offset: request.Offset,
+			Filter: request.Filter,
+			Params: &models.SearchParams{SearchStrategy: models.ExactSearch},
+		})
+		if err != nil {
+			t.Fatalf("Query %d failed: %v", i, err)
+		}
+		got, want := results[i].([]models.SearchResult), expected.([]models.SearchResult)
+		if len(got) != len(want) {
+			t.Fatalf("Query %d: expected %d results, got %d", i, len(want), len(got))
+		}
+		for j := range got {
+			if got[j].ID != want[j].ID || math.Abs(float64(got[j].Distance-want[j].Distance)) > 1e-5 {
+				t.Errorf("Query %d, result %d: expected %s (%f), got %s (%f)", i, j,
+					want[j].ID, want[j].Distance, got[j].ID, got[j].Distance)
+			}
+		}
+	}
+
+	_, err = processor.ProcessBatchQuery([]*models.QueryRequest{
+		{Vector: queries[0], Params: &models.SearchParams{SearchStrategy: models.BatchSearch}},
+		{Vector: []float32{1, 2}, Params: &models.SearchParams{SearchStrategy: models.BatchSearch}},
+	})
+	if err == nil {
+		t.Errorf("Expected a query of the wrong dimension to fail the batch")
+	}
+}
+
+func BenchmarkBatchQuery(b *testing.B) {
+	processor, queries := randomCollection(b, 20000, 32, 128)
+	requests := func(strategy models.SearchStrategy) []*models.QueryRequest {
+		requests := make([]*models.QueryRequest, len(queries))
+		for i, query := range queries {
+			requests[i] = &models.QueryRequest{
+				Vector: query,
+				Limit:  10,
+				Params: &models.SearchParams{SearchStrategy: strategy},
+			}
+		}
+		return requests
+	}
+
+	b.Run("Batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			processor.ProcessBatchQuery(requests(models.BatchSearch))
+		}
+	})
+	b.Run("Independent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, request := range requests(models.ExactSearch) {
+				processor.ProcessQuery(request)
+			}
+		}
+	})
+}
+
+func TestCollectionScoreFloor(t *testing.T) {
+	collection := newTestCollection(t, 3, models.Euclidean, labeledVectors()...)
+	collection.MinScore = 0.5
+	processor := NewProcessor(collection)
+
+	search := func(query []float32, params *models.SearchParams) []models.SearchResult {
+		result, err := processor.ProcessQuery(&models.QueryRequest{Vector: query, Limit: 5, Params: params})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return result.([]models.SearchResult)
+	}
+
+	// Only region A is close enough to a query next to it
+	results := search([]float32{1, 0.1, 0}, nil)
+	if len(results) != 3 {
+		t.Fatalf("Expected the 3 region A vectors above the floor, got %d", len(results))
+	}
+	for _, res := range results {
+		if res.ID[0] != 'a' || res.Score < 0.5 {
+			t.Errorf("Expected only close region A matches, got %s (score %f)", res.ID, res.Score)
+		}
+	}
+
+	// An out-of-distribution query matches nothing well enough
+	far := []float32{10, -10, 10}
+	if results := search(far, nil); len(results) != 0 {
+		t.Errorf("Expected no results for an out-of-distribution query, got %d", len(results))
+	}
+	batch := &models.SearchParams{SearchStrategy: models.BatchSearch}
+	if results, err := processor.ProcessBatchQuery([]*models.QueryRequest{{Vector: far, Limit: 5, Params: batch}}); err != nil || len(results[0].([]models.SearchResult)) != 0 {
+		t.Errorf("Expected batched searches to apply the floor, got %v (%v)", results, err)
+	}
+
+	// Queries can lower or disable the floor
+	if results := search(far, &models.SearchParams{ScoreThreshold: -1}); len(results) != 5 {
+		t.Errorf("Expected 5 results with the floor disabled, got %d", len(results))
+	}
+}
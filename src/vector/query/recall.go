@@ -0,0 +1,173 @@
+package query
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"course/models"
+	"course/vector"
+)
+
+// DefaultRecallWindow is the number of sampled searches the recall gauge
+// averages over
+const DefaultRecallWindow = 100
+
+// RecallMonitor estimates the recall of a collection's searches in
+// production. A random fraction of searches is re-run as an exact scan and
+// the served results are compared against it; the gauge is the average
+// recall@k over the most recent samples.
+type RecallMonitor struct {
+	rate float64
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	recalls []float64 // Ring buffer of the most recent samples
+	next    int
+	filled  int
+}
+
+// NewRecallMonitor creates a monitor that samples the given fraction (0 to 1)
+// of searches and averages over the last window samples
+func NewRecallMonitor(rate float64, window int) *RecallMonitor {
+	if window <= 0 {
+		window = DefaultRecallWindow
+	}
+	return &RecallMonitor{
+		rate:    rate,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		recalls: make([]float64, window),
+	}
+}
+
+// sample decides whether the next search is re-run exactly
+func (m *RecallMonitor) sample() bool {
+	if m.rate <= 0 {
+		return false
+	}
+	if m.rate >= 1 {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rng.Float64() < m.rate
+}
+
+// record adds the recall of one sampled search to the window
+func (m *RecallMonitor) record(recall float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recalls[m.next] = recall
+	m.next = (m.next + 1) % len(m.recalls)
+	if m.filled < len(m.recalls) {
+		m.filled++
+	}
+}
+
+// Recall returns the rolling average recall and the number of samples it
+// covers. It reports 0 samples until a search has been sampled.
+func (m *RecallMonitor) Recall() (recall float64, samples int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.filled == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, r := range m.recalls[:m.filled] {
+		sum += r
+	}
+	return sum / float64(m.filled), m.filled
+}
+
+// observe compares the served results of a search against an exact scan of
+// the collection, if the search is sampled. Searches using a metric other
+// than the collection's are skipped, since stored vectors may have been
+// normalized for the collection's metric, as are searches with a score floor,
+// which may rightly return fewer than k results, and partial searches cut
+// short by their timeout.
+func (m *RecallMonitor) observe(collection *models.VectorCollection, query []float32, k int, filter *models.MetadataFilter, params *models.SearchParams, partial bool, served []models.SearchResult) {
+	if params != nil && params.Metric != nil && *params.Metric != collection.DistanceFunc {
+		return
+	}
+	if partial {
+		return
+	}
+	if collection.ScoreFloor(params) > 0 {
+		return
+	}
+	if !m.sample() {
+		return
+	}
+
+	exact, err := exactSearch(collection, query, k, filter)
+	if err != nil || len(exact) == 0 {
+		return
+	}
+	ids := make([]string, len(served))
+	for i, res := range served {
+		ids[i] = res.ID
+	}
+	m.record(vector.RecallAtK([][]string{ids}, [][]string{exact}, k))
+}
+
+// exactSearch returns the IDs of the true top-k matches of the query by
+// scanning every live vector of the collection. Ties in distance are broken
+// by ID, as the indexes do.
+func exactSearch(collection *models.VectorCollection, query []float32, k int, filter *models.MetadataFilter) ([]string, error) {
+	distance, err := vector.GetDistanceFunc(collection.DistanceFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The projected query is only known once the scan is done, so matches
+	// are scored afterwards
+	type match struct {
+		id       string
+		values   []float32
+		distance float32
+	}
+	var matches []match
+	queries, err := collection.ScanQueries([][]float32{query}, func(v *models.Vector) bool {
+		if filter == nil || filter.MatchVector(v) {
+			matches = append(matches, match{id: v.ID, values: v.Values})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	query = queries[0]
+
+	scored := matches[:0]
+	for _, m := range matches {
+		if len(m.values) == len(query) {
+			m.distance = distance(query, m.values)
+			scored = append(scored, m)
+		}
+	}
+	matches = scored
+
+	higherBetter := vector.IsHigherBetter(collection.DistanceFunc)
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			if higherBetter {
+				return matches[i].distance > matches[j].distance
+			}
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].id < matches[j].id
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.id
+	}
+	return ids, nil
+}
@@ -0,0 +1,175 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"course/models"
+	"course/vector/index"
+)
+
+// lossyIndex is a linear index that drops every other search result,
+// simulating an approximate index with a recall of 0.5
+type lossyIndex struct {
+	*index.LinearIndex
+}
+
+func (l *lossyIndex) Search(query []float32, k int, filter *models.MetadataFilter, params *models.SearchParams) ([]models.SearchResult, error) {
+	results, err := l.LinearIndex.Search(query, k, filter, params)
+	if err != nil {
+		return nil, err
+	}
+	kept := results[:0]
+	for i := 0; i < len(results); i += 2 {
+		kept = append(kept, results[i])
+	}
+	return kept, nil
+}
+
+// newRecallCollection creates a collection of random vectors behind the given index
+func newRecallCollection(t *testing.T, idx models.VectorIndex) *models.VectorCollection {
+	collection := models.NewVectorCollection("recall", 8, models.Euclidean)
+	if err := collection.AddIndex("main", idx); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		values := make([]float32, 8)
+		for j := range values {
+			values[j] = rng.Float32()
+		}
+		if err := collection.Insert(models.NewVector(fmt.Sprintf("v%d", i), values, nil)); err != nil {
+			t.Fatalf("Failed to insert vector: %v", err)
+		}
+	}
+	return collection
+}
+
+// runRecallQueries runs random vector searches through the processor
+func runRecallQueries(t *testing.T, processor *Processor, n int) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < n; i++ {
+		query := make([]float32, 8)
+		for j := range query {
+			query[j] = rng.Float32()
+		}
+		if _, err := processor.ProcessQuery(&models.QueryRequest{Vector: query, Limit: 10}); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+}
+
+func TestRecallMonitorReflectsLoss(t *testing.T) {
+	linear, err := index.NewLinearIndex(8, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	processor := NewProcessor(newRecallCollection(t, &lossyIndex{linear}))
+	monitor := NewRecallMonitor(1, 20)
+	processor.SetRecallMonitor(monitor)
+
+	runRecallQueries(t, processor, 50)
+
+	recall, samples := monitor.Recall()
+	if samples != 20 {
+		t.Errorf("Expected the window to hold 20 samples, got %d", samples)
+	}
+	if math.Abs(recall-0.5) > 1e-9 {
+		t.Errorf("Expected recall 0.5 for an index dropping half its results, got %v", recall)
+	}
+}
+
+func TestRecallMonitorExactIndex(t *testing.T) {
+	linear, err := index.NewLinearIndex(8, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	processor := NewProcessor(newRecallCollection(t, linear))
+	monitor := NewRecallMonitor(1, 0)
+	processor.SetRecallMonitor(monitor)
+
+	runRecallQueries(t, processor, 10)
+
+	if recall, samples := monitor.Recall(); samples != 10 || recall != 1 {
+		t.Errorf("Expected recall 1 over 10 samples for an exact index, got %v over %d", recall, samples)
+	}
+}
+
+func TestRecallMonitorSampleRate(t *testing.T) {
+	linear, err := index.NewLinearIndex(8, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	processor := NewProcessor(newRecallCollection(t, linear))
+	monitor := NewRecallMonitor(0.1, 1000)
+	processor.SetRecallMonitor(monitor)
+
+	runRecallQueries(t, processor, 500)
+
+	// 50 samples are expected; allow for chance
+	if _, samples := monitor.Recall(); samples < 20 || samples > 90 {
+		t.Errorf("Expected about 50 of 500 searches to be sampled, got %d", samples)
+	}
+}
+
+// partialIndex is a linear index whose searches always report that they
+// were cut short, returning only the best result
+type partialIndex struct {
+	*index.LinearIndex
+}
+
+func (p *partialIndex) Search(query []float32, k int, filter *models.MetadataFilter, params *models.SearchParams) ([]models.SearchResult, error) {
+	results, err := p.LinearIndex.Search(query, k, filter, params)
+	if err != nil {
+		return nil, err
+	}
+	params.Partial = true
+	return results[:1], nil
+}
+
+func TestRecallMonitorSkipsPartialSearches(t *testing.T) {
+	linear, err := index.NewLinearIndex(8, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	processor := NewProcessor(newRecallCollection(t, &partialIndex{linear}))
+	monitor := NewRecallMonitor(1, 0)
+	processor.SetRecallMonitor(monitor)
+
+	runRecallQueries(t, processor, 10)
+
+	if _, samples := monitor.Recall(); samples != 0 {
+		t.Errorf("Expected partial searches not to be sampled, got %d samples", samples)
+	}
+}
+
+func TestRecallMonitorTies(t *testing.T) {
+	linear, err := index.NewLinearIndex(2, models.Euclidean)
+	if err != nil {
+		t.Fatalf("Failed to create linear index: %v", err)
+	}
+	collection := models.NewVectorCollection("ties", 2, models.Euclidean)
+	if err := collection.AddIndex("main", linear); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := collection.Insert(models.NewVector(fmt.Sprintf("v%02d", i), []float32{1, 1}, nil)); err != nil {
+			t.Fatalf("Failed to insert vector: %v", err)
+		}
+	}
+	processor := NewProcessor(collection)
+	monitor := NewRecallMonitor(1, 0)
+	processor.SetRecallMonitor(monitor)
+
+	// Every vector ties, so the exact ranking must pick the same IDs as the index
+	for i := 0; i < 5; i++ {
+		if _, err := processor.ProcessQuery(&models.QueryRequest{Vector: []float32{0, 0}, Limit: 3}); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if recall, samples := monitor.Recall(); samples != 5 || recall != 1 {
+		t.Errorf("Expected recall 1 over 5 samples with tied distances, got %v over %d", recall, samples)
+	}
+}
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader carries the correlation ID of a request, both from the
+// client and back in the response
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied IDs so they can't bloat log lines
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the correlation ID of the request being
+// served, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID generates a random 128-bit ID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// SetLogger sets where the API writes its logs. The default is the standard
+// logger.
+func (api *API) SetLogger(logger *log.Logger) {
+	api.logger = logger
+}
+
+// logf writes a log line prefixed with the request ID from ctx, so every
+// line logged while serving a request can be correlated
+func (api *API) logf(ctx context.Context, format string, args ...interface{}) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	if api.logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	api.logger.Printf(format, args...)
+}
+
+// withRequestID tags every request with a correlation ID, taken from the
+// X-Request-ID header or generated, returns it in the response header and
+// logs the request once it completes
+func (api *API) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		api.logf(ctx, "%s %s %d %v", r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through so streamed responses keep working
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
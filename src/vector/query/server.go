@@ -0,0 +1,62 @@
+package query
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// ServerConfig bounds how long a client may hold a connection and how much
+// it may send, protecting the node from slow or oversized requests, and
+// which debugging endpoints are exposed
+type ServerConfig struct {
+	ReadTimeout           time.Duration // Time allowed to read a whole request, including the body
+	WriteTimeout          time.Duration // Time allowed to write a response
+	IdleTimeout           time.Duration // Time a keep-alive connection may sit idle
+	MaxHeaderBytes        int           // Largest accepted request header
+	MaxRequestBodyBytes   int64         // Largest accepted request body (0 = unlimited)
+	EnablePprof           bool          // Serve net/http/pprof under /debug/pprof/
+	MaxConcurrentSearches int           // Searches allowed to run at once; more get 503 (0 = unlimited)
+}
+
+// DefaultServerConfig returns the limits used when none are configured
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadTimeout:         30 * time.Second,
+		WriteTimeout:        60 * time.Second,
+		IdleTimeout:         120 * time.Second,
+		MaxHeaderBytes:      1 << 20,  // 1 MiB
+		MaxRequestBodyBytes: 32 << 20, // 32 MiB
+	}
+}
+
+// NewServer builds an HTTP server for the API listening on addr
+func NewServer(addr string, api *API, config ServerConfig) *http.Server {
+	api.SetMaxBodyBytes(config.MaxRequestBodyBytes)
+	api.SetMaxConcurrentSearches(config.MaxConcurrentSearches)
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	if config.EnablePprof {
+		mountPprof(mux)
+	}
+
+	return &http.Server{
+		Addr:           addr,
+		Handler:        api.withRequestID(mux),
+		ReadTimeout:    config.ReadTimeout,
+		WriteTimeout:   config.WriteTimeout,
+		IdleTimeout:    config.IdleTimeout,
+		MaxHeaderBytes: config.MaxHeaderBytes,
+	}
+}
+
+// mountPprof registers the profiling handlers on mux. Importing
+// net/http/pprof also registers them on http.DefaultServeMux, which is never
+// served here, so they are only reachable when enabled.
+func mountPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
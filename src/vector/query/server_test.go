@@ -0,0 +1,267 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"course/models"
+	"course/vector/index"
+)
+
+// startServer serves a test API on a local port using the given limits
+func startServer(t *testing.T, config ServerConfig) string {
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	return startAPIServer(t, api, config)
+}
+
+// startAPIServer serves api on a local port using the given limits
+func startAPIServer(t *testing.T, api *API, config ServerConfig) string {
+	server := NewServer("", api, config)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+func TestServerRejectsOversizedBodies(t *testing.T) {
+	config := DefaultServerConfig()
+	config.MaxRequestBodyBytes = 1024
+	addr := startServer(t, config)
+
+	padding := strings.Repeat(" ", 2048)
+	body := `{"vector": [1, 0, 0], "limit": 1}` + padding
+
+	// Declared length over the limit
+	resp, err := http.Post("http://"+addr+"/collections/test/query", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large Content-Length, got %d", resp.StatusCode)
+	}
+
+	// Chunked body with no declared length
+	resp, err = http.Post("http://"+addr+"/collections", "application/json",
+		io.MultiReader(strings.NewReader(`{"name": "big", "metric": "`+padding), strings.NewReader(`"}`)))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large chunked body, got %d", resp.StatusCode)
+	}
+
+	// Small bodies still go through
+	resp, err = http.Post("http://"+addr+"/collections/test/query", "application/json",
+		strings.NewReader(`{"vector": [1, 0, 0], "limit": 1}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for a small body, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerTimesOutSlowRequests(t *testing.T) {
+	config := DefaultServerConfig()
+	config.ReadTimeout = 100 * time.Millisecond
+	addr := startServer(t, config)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Send the headers but only part of the promised body
+	fmt.Fprintf(conn, "POST /collections/test/query HTTP/1.1\r\nHost: %s\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"vec", addr)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Fatalf("Expected the incomplete request to fail, got 200")
+		}
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Fatalf("Server kept the slow connection open past the read timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Slow request took %v to be cut off", elapsed)
+	}
+}
+
+func TestServerPprofToggle(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		config := DefaultServerConfig()
+		config.EnablePprof = enabled
+		addr := startServer(t, config)
+
+		resp, err := http.Get("http://" + addr + "/debug/pprof/")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		expected := http.StatusNotFound
+		if enabled {
+			expected = http.StatusOK
+		}
+		if resp.StatusCode != expected {
+			t.Errorf("pprof enabled=%v: expected status %d, got %d", enabled, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var logs bytes.Buffer
+	api := NewAPI()
+	api.RegisterCollection(newTestCollection(t, 3, models.Cosine, labeledVectors()...))
+	api.SetLogger(log.New(&logs, "", 0))
+	addr := startAPIServer(t, api, DefaultServerConfig())
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/collections/test", nil)
+	req.Header.Set("X-Request-ID", "trace-1234")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if id := resp.Header.Get("X-Request-ID"); id != "trace-1234" {
+		t.Errorf("Expected the request ID to be echoed, got %q", id)
+	}
+	if !strings.Contains(logs.String(), "[trace-1234] GET /collections/test 200") {
+		t.Errorf("Expected the request ID in the logs, got %q", logs.String())
+	}
+
+	// Requests without an ID get a generated one
+	resp, err = http.Get("http://" + addr + "/collections/test")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if id := resp.Header.Get("X-Request-ID"); len(id) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+}
+
+// blockingIndex is a linear index whose searches wait until released,
+// signalling on started as each one begins
+type blockingIndex struct {
+	*index.LinearIndex
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingIndex) Search(query []float32, k int, filter *models.MetadataFilter, params *models.SearchParams) ([]models.SearchResult, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.LinearIndex.Search(query, k, filter, params)
+}
+
+func TestServerLimitsConcurrentSearches(t *testing.T) {
+	const limit = 2
+	linear, _ := index.NewLinearIndex(3, models.Cosine)
+	blocking := &blockingIndex{
+		LinearIndex: linear,
+		started:     make(chan struct{}, limit),
+		release:     make(chan struct{}),
+	}
+	collection := models.NewVectorCollection("test", 3, models.Cosine)
+	collection.AddIndex("blocking", blocking)
+	for _, v := range labeledVectors() {
+		if err := collection.Insert(v); err != nil {
+			t.Fatalf("Failed to insert vector %s: %v", v.ID, err)
+		}
+	}
+	api := NewAPI()
+	api.RegisterCollection(collection)
+	config := DefaultServerConfig()
+	config.MaxConcurrentSearches = limit
+	addr := startAPIServer(t, api, config)
+
+	search := func() (*http.Response, error) {
+		resp, err := http.Post("http://"+addr+"/collections/test/query", "application/json",
+			strings.NewReader(`{"vector": [1, 0, 0], "limit": 1}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// Fill every slot with a search that blocks in the index
+	statuses := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			resp, err := search()
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		select {
+		case <-blocking.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Only %d of %d searches started", i, limit)
+		}
+	}
+
+	resp, err := search()
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 past the limit, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header on the rejected search")
+	}
+
+	// Other search-heavy endpoints share the same slots
+	for _, path := range []string{"/collections/test/dedup", "/similarity/matrix"} {
+		body := `{"threshold": 0.9}`
+		if path == "/similarity/matrix" {
+			body = `{"vectors": [[1, 0, 0], [0, 1, 0]]}`
+		}
+		resp, err := http.Post("http://"+addr+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request to %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 from %s past the limit, got %d", path, resp.StatusCode)
+		}
+	}
+
+	close(blocking.release)
+	for i := 0; i < limit; i++ {
+		if status := <-statuses; status != http.StatusOK {
+			t.Errorf("Expected in-flight searches to complete with 200, got %d", status)
+		}
+	}
+
+	// Freed slots accept searches again
+	if resp, err := search(); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a search after the flood to succeed, got %v (%v)", resp, err)
+	}
+}
@@ -0,0 +1,153 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"course/models"
+)
+
+// MetricDefinition describes a distance metric that can be registered by name
+type MetricDefinition struct {
+	Name           string                    // Canonical name, e.g. "cosine"
+	Aliases        []string                  // Alternative names accepted when parsing
+	Func           DistanceFunc              // Computes the raw distance/similarity
+	HigherIsBetter bool                      // True for similarities, false for distances
+	Score          func(raw float32) float32 // Maps a raw value to [0,1]; optional
+}
+
+// DistanceRegistry maps metric identifiers and names to their definitions.
+// Built-in metrics keep their models.DistanceMetric constants; custom metrics
+// are assigned new identifiers at registration time.
+type DistanceRegistry struct {
+	mu      sync.RWMutex
+	metrics map[models.DistanceMetric]MetricDefinition
+	names   map[string]models.DistanceMetric
+	next    models.DistanceMetric
+}
+
+// NewDistanceRegistry creates a registry pre-populated with the built-in metrics
+func NewDistanceRegistry() *DistanceRegistry {
+	r := &DistanceRegistry{
+		metrics: make(map[models.DistanceMetric]MetricDefinition),
+		names:   make(map[string]models.DistanceMetric),
+		next:    models.Manhattan + 1,
+	}
+
+	builtins := []struct {
+		metric models.DistanceMetric
+		def    MetricDefinition
+	}{
+		{models.Cosine, MetricDefinition{Name: "cosine", Func: CosineSimilarity, HigherIsBetter: true}},
+		{models.DotProduct, MetricDefinition{Name: "dotproduct", Aliases: []string{"dot_product", "dot"},
+			Func: DotProduct, HigherIsBetter: true}},
+		{models.Euclidean, MetricDefinition{Name: "euclidean", Aliases: []string{"euclid", "l2"},
+			Func: EuclideanDistance}},
+		{models.Manhattan, MetricDefinition{Name: "manhattan", Aliases: []string{"taxicab", "cityblock", "l1"},
+			Func: ManhattanDistance}},
+	}
+	for _, builtin := range builtins {
+		metric := builtin.metric
+		builtin.def.Score = func(raw float32) float32 {
+			return models.DistanceToScore(raw, metric)
+		}
+		r.add(metric, builtin.def)
+	}
+
+	return r
+}
+
+// DefaultRegistry is the registry consulted by GetDistanceFunc, IsHigherBetter,
+// NormalizeScore and the HTTP API when resolving metrics
+var DefaultRegistry = NewDistanceRegistry()
+
+// Register adds a custom metric and returns the identifier assigned to it
+func (r *DistanceRegistry) Register(def MetricDefinition) (models.DistanceMetric, error) {
+	if def.Name == "" {
+		return 0, fmt.Errorf("metric name is required")
+	}
+	if def.Func == nil {
+		return 0, fmt.Errorf("metric %s has no distance function", def.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range append([]string{def.Name}, def.Aliases...) {
+		if _, exists := r.names[strings.ToLower(name)]; exists {
+			return 0, fmt.Errorf("metric %s is already registered", name)
+		}
+	}
+
+	metric := r.next
+	r.next++
+	r.add(metric, def)
+	models.RegisterMetricName(metric, def.Name)
+	return metric, nil
+}
+
+// add stores a definition under the given identifier. Callers must hold the
+// lock (or own the registry exclusively).
+func (r *DistanceRegistry) add(metric models.DistanceMetric, def MetricDefinition) {
+	if def.Score == nil {
+		def.Score = defaultScore(def.HigherIsBetter)
+	}
+
+	r.metrics[metric] = def
+	r.names[strings.ToLower(def.Name)] = metric
+	for _, alias := range def.Aliases {
+		r.names[strings.ToLower(alias)] = metric
+	}
+}
+
+// Lookup resolves a metric by name or alias (case-insensitive)
+func (r *DistanceRegistry) Lookup(name string) (models.DistanceMetric, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metric, ok := r.names[strings.ToLower(name)]
+	return metric, ok
+}
+
+// Get returns the definition of a registered metric
+func (r *DistanceRegistry) Get(metric models.DistanceMetric) (MetricDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.metrics[metric]
+	return def, ok
+}
+
+// defaultScore is used for custom metrics registered without a score function:
+// similarities are clamped to [0,1], distances decay exponentially
+func defaultScore(higherIsBetter bool) func(float32) float32 {
+	if higherIsBetter {
+		return func(raw float32) float32 {
+			return float32(math.Max(0, math.Min(1, float64(raw))))
+		}
+	}
+	return func(raw float32) float32 {
+		return float32(math.Exp(-float64(raw)))
+	}
+}
+
+// RegisterMetric adds a custom metric to the default registry
+func RegisterMetric(def MetricDefinition) (models.DistanceMetric, error) {
+	return DefaultRegistry.Register(def)
+}
+
+// LookupMetric resolves a metric by name in the default registry
+func LookupMetric(name string) (models.DistanceMetric, bool) {
+	return DefaultRegistry.Lookup(name)
+}
+
+// MetricName returns the registered name of a metric, falling back to the
+// built-in String representation
+func MetricName(metric models.DistanceMetric) string {
+	if def, ok := DefaultRegistry.Get(metric); ok && metric > models.Manhattan {
+		return def.Name
+	}
+	return metric.String()
+}
@@ -0,0 +1,29 @@
+// Package storage defines where indexes persist their data. Indexes write
+// through a PersistenceBackend so the durable store (local files, object
+// storage, ...) can be swapped without touching the index code.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrKeyNotFound is returned by Read and Delete when a key does not exist
+var ErrKeyNotFound = errors.New("key not found")
+
+// PersistenceBackend is a minimal key/value blob store. Keys are
+// slash-separated paths such as "collections/docs/linear".
+type PersistenceBackend interface {
+	// Write stores everything read from r under key, replacing any existing
+	// value. A failed write must not leave a partial value behind.
+	Write(key string, r io.Reader) error
+
+	// Read opens the value stored under key. The caller must close it.
+	Read(key string) (io.ReadCloser, error)
+
+	// List returns the keys starting with prefix, in lexical order
+	List(prefix string) ([]string, error)
+
+	// Delete removes the value stored under key
+	Delete(key string) error
+}
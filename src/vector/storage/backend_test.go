@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testBackend runs the behavior every PersistenceBackend must provide
+func testBackend(t *testing.T, backend PersistenceBackend) {
+	read := func(key string) string {
+		rc, err := backend.Read(key)
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", key, err)
+		}
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("Reading %s failed: %v", key, err)
+		}
+		return string(data)
+	}
+
+	for key, value := range map[string]string{
+		"collections/a/linear": "first",
+		"collections/b/linear": "second",
+		"other":                "third",
+	} {
+		if err := backend.Write(key, strings.NewReader(value)); err != nil {
+			t.Fatalf("Write %s failed: %v", key, err)
+		}
+	}
+	if got := read("collections/a/linear"); got != "first" {
+		t.Errorf("Expected first, got %q", got)
+	}
+
+	// Writes replace existing values
+	if err := backend.Write("collections/a/linear", strings.NewReader("updated")); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+	if got := read("collections/a/linear"); got != "updated" {
+		t.Errorf("Expected updated, got %q", got)
+	}
+
+	keys, err := backend.List("collections/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "collections/a/linear" || keys[1] != "collections/b/linear" {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if err := backend.Delete("collections/a/linear"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := backend.Read("collections/a/linear"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after delete, got %v", err)
+	}
+	if err := backend.Delete("collections/a/linear"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound deleting a missing key, got %v", err)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend())
+}
+
+func TestFileBackend(t *testing.T) {
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	testBackend(t, backend)
+
+	for _, key := range []string{"", "../escape", "/absolute", "a/../b"} {
+		if err := backend.Write(key, strings.NewReader("x")); err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
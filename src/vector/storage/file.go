@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileBackend stores each key as a file below a root directory
+type FileBackend struct {
+	root string
+}
+
+// NewFileBackend creates a backend rooted at dir, creating it if needed
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FileBackend{root: dir}, nil
+}
+
+// pathFor maps a key to a file path, rejecting keys that would escape the root
+func (b *FileBackend) pathFor(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(key)), nil
+}
+
+// Write stores the value in a temporary file and renames it into place, so
+// readers never observe a partially written value
+func (b *FileBackend) Write(key string, r io.Reader) error {
+	filename, err := b.pathFor(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Read opens the file holding key
+func (b *FileBackend) Read(key string) (io.ReadCloser, error) {
+	filename, err := b.pathFor(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	return f, err
+}
+
+// List walks the root directory for keys starting with prefix
+func (b *FileBackend) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(b.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes the file holding key
+func (b *FileBackend) Delete(key string) error {
+	filename, err := b.pathFor(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	return err
+}
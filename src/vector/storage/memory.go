@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend keeps values in memory. It is useful for tests and for
+// running without durable storage.
+type MemoryBackend struct {
+	values map[string][]byte
+	mu     sync.RWMutex
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{values: make(map[string][]byte)}
+}
+
+// Write buffers the whole value before storing it
+func (b *MemoryBackend) Write(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = data
+	return nil
+}
+
+// Read returns a reader over a snapshot of the value
+func (b *MemoryBackend) Read(key string) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, ok := b.values[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// List returns the stored keys starting with prefix
+func (b *MemoryBackend) List(prefix string) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var keys []string
+	for key := range b.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes a stored value
+func (b *MemoryBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.values[key]; !ok {
+		return fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+	}
+	delete(b.values, key)
+	return nil
+}